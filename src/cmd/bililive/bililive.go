@@ -3,7 +3,6 @@ package main
 import (
 	"context"
 	"fmt"
-	"net/url"
 	"os"
 	"os/signal"
 	"path/filepath"
@@ -14,11 +13,11 @@ import (
 
 	_ "github.com/hr3lxphr6j/bililive-go/src/cmd/bililive/internal"
 	"github.com/hr3lxphr6j/bililive-go/src/cmd/bililive/internal/flag"
+	"github.com/hr3lxphr6j/bililive-go/src/cmd/bililive/internal/lives"
 	"github.com/hr3lxphr6j/bililive-go/src/configs"
 	"github.com/hr3lxphr6j/bililive-go/src/consts"
 	"github.com/hr3lxphr6j/bililive-go/src/instance"
 	"github.com/hr3lxphr6j/bililive-go/src/listeners"
-	"github.com/hr3lxphr6j/bililive-go/src/live"
 	"github.com/hr3lxphr6j/bililive-go/src/log"
 	"github.com/hr3lxphr6j/bililive-go/src/metrics"
 	"github.com/hr3lxphr6j/bililive-go/src/pkg/events"
@@ -76,7 +75,7 @@ func main() {
 	ctx := context.WithValue(context.Background(), instance.Key, inst)
 
 	logger := log.New(ctx)
-	logger.Infof("%s Version: %s Link Start", consts.AppName, consts.AppVersion)
+	logger.Infof("%s Version: %s Link Start", consts.AppName, consts.AppInfo.AppVersion)
 	if config.File != "" {
 		logger.Debugf("config path: %s.", config.File)
 		logger.Debugf("other flags have been ignored.")
@@ -93,33 +92,7 @@ func main() {
 
 	events.NewDispatcher(ctx)
 
-	inst.Lives = make(map[live.ID]live.Live)
-	for index, _ := range inst.Config.LiveRooms {
-		room := &inst.Config.LiveRooms[index]
-		u, err := url.Parse(room.Url)
-		if err != nil {
-			logger.WithField("url", room).Error(err)
-			continue
-		}
-		opts := make([]live.Option, 0)
-		if v, ok := inst.Config.Cookies[u.Host]; ok {
-			opts = append(opts, live.WithKVStringCookies(u, v))
-		}
-		opts = append(opts, live.WithQuality(room.Quality))
-		opts = append(opts, live.WithAudioOnly(room.AudioOnly))
-
-		l, err := live.New(u, inst.Cache, opts...)
-		if err != nil {
-			logger.WithField("url", room).Error(err.Error())
-			continue
-		}
-		if _, ok := inst.Lives[l.GetLiveId()]; ok {
-			logger.Errorf("%s is exist!", room)
-			continue
-		}
-		inst.Lives[l.GetLiveId()] = l
-		room.LiveId = l.GetLiveId()
-	}
+	lives.Init(ctx)
 
 	if inst.Config.RPC.Enable {
 		if err := servers.NewServer(ctx).Start(ctx); err != nil {
@@ -135,6 +108,11 @@ func main() {
 		logger.Fatalf("failed to init recorder manager, error: %s", err)
 	}
 
+	dm := utils.NewDiskSpaceManager(ctx)
+	if err := dm.Start(ctx); err != nil {
+		logger.Fatalf("failed to init disk space manager, error: %s", err)
+	}
+
 	if err = metrics.NewCollector(ctx).Start(ctx); err != nil {
 		logger.Fatalf("failed to init metrics collector, error: %s", err)
 	}
@@ -160,6 +138,7 @@ func main() {
 		if inst.Config.RPC.Enable {
 			inst.Server.Close(ctx)
 		}
+		inst.DiskSpaceManager.Close(ctx)
 		inst.ListenerManager.Close(ctx)
 		inst.RecorderManager.Close(ctx)
 	}()
@@ -12,7 +12,7 @@ import (
 )
 
 var (
-	app = kingpin.New(consts.AppName, "A command-line live stream save tools.").Version(consts.AppVersion)
+	app = kingpin.New(consts.AppName, "A command-line live stream save tools.").Version(consts.AppInfo.AppVersion)
 
 	Debug           = app.Flag("debug", "Enable debug mode.").Default("false").Bool()
 	Interval        = app.Flag("interval", "Interval of query live status").Default("20").Short('t').Int()
@@ -34,10 +34,8 @@ func init() {
 // GenConfigFromFlags generates configuration by parsing command line parameters.
 func GenConfigFromFlags() *configs.Config {
 	cfg := configs.NewConfig()
-	cfg.RPC = configs.RPC{
-		Enable: *RPC,
-		Bind:   *RPCBind,
-	}
+	cfg.RPC.Enable = *RPC
+	cfg.RPC.Bind = *RPCBind
 	cfg.Debug = *Debug
 	cfg.Interval = *Interval
 	cfg.OutPutPath = *Output
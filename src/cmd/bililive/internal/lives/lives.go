@@ -0,0 +1,76 @@
+package lives
+
+import (
+	"context"
+	"net/url"
+	"sync"
+
+	"github.com/hr3lxphr6j/bililive-go/src/configs"
+	"github.com/hr3lxphr6j/bililive-go/src/instance"
+	"github.com/hr3lxphr6j/bililive-go/src/live"
+)
+
+func newLive(ctx context.Context, room *configs.LiveRoom) (live.Live, error) {
+	inst := instance.GetInstance(ctx)
+	u, err := url.Parse(room.Url)
+	if err != nil {
+		return nil, err
+	}
+	opts := make([]live.Option, 0)
+	if v, ok := inst.Config.Cookies[u.Host]; ok {
+		opts = append(opts, live.WithKVStringCookies(u, v))
+	}
+	if v, ok := inst.Config.Headers[u.Host]; ok {
+		opts = append(opts, live.WithHeaders(v))
+	}
+	opts = append(opts, live.WithQuality(room.Quality))
+	opts = append(opts, live.WithAudioOnly(room.AudioOnly))
+	opts = append(opts, live.WithNickName(room.NickName))
+	opts = append(opts, live.WithInitRetry(inst.Config.LiveInit.RetryCount, inst.Config.LiveInit.RetryInterval))
+	opts = append(opts, live.WithMaxInflight(inst.Config.MaxInflightInfoRequests[u.Host]))
+	return live.New(ctx, u, inst.Cache, opts...)
+}
+
+// Init creates the lives of the rooms in config, one by one in sequential mode,
+// or concurrently limited by MaxInitConcurrency in fast mode. It blocks until every room
+// is initialized in both modes, as the listeners and the servers started afterwards read
+// inst.Lives without a lock, fast mode only shortens the wait. A room never takes longer
+// than the live_init retries, it's added as an initializing live when they all fail.
+func Init(ctx context.Context) {
+	inst := instance.GetInstance(ctx)
+	inst.Lives = make(map[live.ID]live.Live)
+	concurrency := 1
+	if inst.Config.StartupGraceMode == configs.StartupGraceModeFast && inst.Config.MaxInitConcurrency > 0 {
+		concurrency = inst.Config.MaxInitConcurrency
+	}
+	var (
+		wg    sync.WaitGroup
+		lock  sync.Mutex
+		slots = make(chan struct{}, concurrency)
+	)
+	for index := range inst.Config.LiveRooms {
+		room := &inst.Config.LiveRooms[index]
+		slots <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			l, err := newLive(ctx, room)
+			if err != nil {
+				inst.Logger.WithField("url", room.Url).Error(err.Error())
+				return
+			}
+			lock.Lock()
+			defer lock.Unlock()
+			if _, ok := inst.Lives[l.GetLiveId()]; ok {
+				inst.Logger.Errorf("%s is exist!", room.Url)
+				return
+			}
+			inst.Lives[l.GetLiveId()] = l
+			room.LiveId = l.GetLiveId()
+		}()
+	}
+	wg.Wait()
+}
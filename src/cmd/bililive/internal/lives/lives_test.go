@@ -0,0 +1,80 @@
+package lives
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bluele/gcache"
+	"github.com/golang/mock/gomock"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hr3lxphr6j/bililive-go/src/configs"
+	"github.com/hr3lxphr6j/bililive-go/src/instance"
+	"github.com/hr3lxphr6j/bililive-go/src/interfaces"
+	"github.com/hr3lxphr6j/bililive-go/src/live"
+	"github.com/hr3lxphr6j/bililive-go/src/live/mock"
+)
+
+const testDomain = "test.bililive-go.local"
+
+type testBuilder struct {
+	ctrl              *gomock.Controller
+	running, maxCount int32
+}
+
+func (b *testBuilder) Build(u *url.URL, opts ...live.Option) (live.Live, error) {
+	l := mock.NewMockLive(b.ctrl)
+	l.EXPECT().GetLiveId().Return(live.ID(u.Path)).AnyTimes()
+	l.EXPECT().GetInfo().DoAndReturn(func() (*live.Info, error) {
+		running := atomic.AddInt32(&b.running, 1)
+		defer atomic.AddInt32(&b.running, -1)
+		for {
+			max := atomic.LoadInt32(&b.maxCount)
+			if running <= max || atomic.CompareAndSwapInt32(&b.maxCount, max, running) {
+				break
+			}
+		}
+		time.Sleep(50 * time.Millisecond)
+		return &live.Info{Live: l}, nil
+	})
+	return l, nil
+}
+
+func testInitLives(t *testing.T, mode string, concurrency int) int32 {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	b := &testBuilder{ctrl: ctrl}
+	live.Register(testDomain, b)
+
+	config := configs.NewConfig()
+	config.StartupGraceMode = mode
+	config.MaxInitConcurrency = concurrency
+	for i := 0; i < 6; i++ {
+		config.LiveRooms = append(config.LiveRooms, configs.LiveRoom{Url: fmt.Sprintf("https://%s/%d", testDomain, i)})
+	}
+	inst := &instance.Instance{
+		Config: config,
+		Logger: &interfaces.Logger{Logger: logrus.New()},
+		Cache:  gcache.New(16).LRU().Build(),
+	}
+	Init(context.WithValue(context.Background(), instance.Key, inst))
+
+	assert.Len(t, inst.Lives, 6)
+	for _, room := range config.LiveRooms {
+		assert.NotEmpty(t, room.LiveId)
+	}
+	return atomic.LoadInt32(&b.maxCount)
+}
+
+func TestInitLivesFast(t *testing.T) {
+	assert.EqualValues(t, 2, testInitLives(t, configs.StartupGraceModeFast, 2))
+}
+
+func TestInitLivesSequential(t *testing.T) {
+	assert.EqualValues(t, 1, testInitLives(t, configs.StartupGraceModeSequential, 2))
+}
@@ -5,7 +5,12 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net"
+	"net/url"
 	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/hr3lxphr6j/bililive-go/src/live"
@@ -14,13 +19,41 @@ import (
 
 // RPC info.
 type RPC struct {
-	Enable bool   `yaml:"enable"`
-	Bind   string `yaml:"bind"`
+	Enable    bool      `yaml:"enable"`
+	Bind      string    `yaml:"bind"`
+	RateLimit RateLimit `yaml:"rate_limit"`
+	OSRPToken string    `yaml:"osrp_token"` // bearer token of the /osrp endpoints, empty means no auth
+	// interval of the keepalive comments of the server-sent events, 0 means disabled
+	SSEHeartbeatInterval time.Duration `yaml:"sse_heartbeat_interval"`
+	StreamProxy          StreamProxy   `yaml:"stream_proxy"`
 }
 
+// StreamProxy serves the upstream streams of the lives to the external players, it's
+// disabled when Token is empty, as the stream may carry the cookies of the account.
+type StreamProxy struct {
+	Token      string `yaml:"token"`
+	MaxClients int    `yaml:"max_clients"` // the players watching at the same time
+}
+
+// RateLimit limits the api requests per client ip, 0 RequestsPerMinute means disabled.
+type RateLimit struct {
+	RequestsPerMinute int  `yaml:"requests_per_minute"`
+	BurstSize         int  `yaml:"burst_size"` // RequestsPerMinute is used when <= 0
+	ExemptLocalhost   bool `yaml:"exempt_localhost"`
+}
+
+var bitrateRegexp = regexp.MustCompile(`^[1-9][0-9]*[kKmM]?$`)
+
 var defaultRPC = RPC{
 	Enable: true,
 	Bind:   "127.0.0.1:8080",
+	RateLimit: RateLimit{
+		ExemptLocalhost: true,
+	},
+	SSEHeartbeatInterval: 15 * time.Second,
+	StreamProxy: StreamProxy{
+		MaxClients: 2,
+	},
 }
 
 func (r *RPC) verify() error {
@@ -38,8 +71,21 @@ func (r *RPC) verify() error {
 
 // Feature info.
 type Feature struct {
-	UseNativeFlvParser         bool `yaml:"use_native_flv_parser"`
-	RemoveSymbolOtherCharacter bool `yaml:"remove_symbol_other_character"`
+	UseNativeFlvParser         bool   `yaml:"use_native_flv_parser"`
+	RemoveSymbolOtherCharacter bool   `yaml:"remove_symbol_other_character"`
+	HWAccel                    string `yaml:"hw_accel"` // "auto", "cuda", "vaapi", "videotoolbox" or "none"
+	WriteFlvKeyframeIndex      bool   `yaml:"write_flv_keyframe_index"`
+	FfmpegCPUAffinity          string `yaml:"ffmpeg_cpu_affinity"` // cpu list for taskset, e.g. "0-3,6", linux only
+	FfmpegNice                 int    `yaml:"ffmpeg_nice"`         // -20 to 19, 0 means unchanged
+	FfmpegIOPriority           string `yaml:"ffmpeg_io_priority"`  // "idle" or "best-effort", linux only
+	// overrides the priority of the ffmpeg processes of the platform, keyed by domain
+	FfmpegPriorities map[string]ProcessPriority `yaml:"ffmpeg_priorities,omitempty"`
+}
+
+// ProcessPriority overrides Feature.FfmpegNice and Feature.FfmpegIOPriority, unset means inherited.
+type ProcessPriority struct {
+	Nice       *int    `yaml:"nice,omitempty"`
+	IOPriority *string `yaml:"io_priority,omitempty"`
 }
 
 // VideoSplitStrategies info.
@@ -51,24 +97,116 @@ type VideoSplitStrategies struct {
 
 // On record finished actions.
 type OnRecordFinished struct {
-	ConvertToMp4          bool   `yaml:"convert_to_mp4"`
-	DeleteFlvAfterConvert bool   `yaml:"delete_flv_after_convert"`
-	CustomCommandline     string `yaml:"custom_commandline"`
+	ConvertToMp4          bool              `yaml:"convert_to_mp4"`
+	DeleteFlvAfterConvert bool              `yaml:"delete_flv_after_convert"`
+	CustomCommandline     string            `yaml:"custom_commandline"`
+	EmbedMetadata         bool              `yaml:"embed_metadata"`
+	Metadata              map[string]string `yaml:"metadata"`         // templates of the mp4 metadata, keyed by name
+	VerifyRecording       bool              `yaml:"verify_recording"` // decode the finished file to catch the corrupt ones
+	Dedup                 FragmentDedup     `yaml:"dedup"`
+}
+
+// Actions of FragmentDedup on the duplicates.
+const (
+	DedupActionRemove   = "remove"   // delete the duplicate
+	DedupActionHardlink = "hardlink" // replace the duplicate with a hard link of the earlier file
+)
+
+// FragmentDedup cleans up the fragments left by the reconnects of a recording session before
+// the other steps of on_record_finished, a file is a duplicate only when its whole content is
+// the same as an earlier file of the session.
+type FragmentDedup struct {
+	Enable    bool   `yaml:"enable"`
+	Action    string `yaml:"action"`      // on the duplicates, remove by default
+	MinSizeKB int    `yaml:"min_size_kb"` // the smaller files are removed as well, 0 means only the empty ones
+}
+
+// Startup grace modes, which control how the rooms are initialized at startup.
+const (
+	StartupGraceModeSequential = "sequential" // initialize the rooms one by one
+	StartupGraceModeFast       = "fast"       // initialize the rooms concurrently
+)
+
+// the values of Feature.HWAccel besides the accelerators of ffmpeg
+const (
+	HWAccelAuto = "auto" // the best one supported by the ffmpeg binary
+	HWAccelNone = "none"
+)
+
+// the accelerators of `ffmpeg -hwaccels` which Feature.HWAccel accepts
+var hwAccels = map[string]bool{
+	"cuda": true, "vaapi": true, "videotoolbox": true, "qsv": true, "d3d11va": true,
+	"dxva2": true, "vdpau": true, "vulkan": true, "opencl": true, "drm": true, "mediacodec": true,
 }
 
+// IO priorities of the ffmpeg processes.
+const (
+	IOPriorityIdle       = "idle"        // only gets disk time when no other process needs it
+	IOPriorityBestEffort = "best-effort" // the default of the processes
+)
+
+// the scheduling classes of ioprio_set on linux, which ionice -c takes
+var ioPriorityClasses = map[string]int{
+	IOPriorityIdle:       3,
+	IOPriorityBestEffort: 2,
+}
+
+// Policies of DiskSpace.WriteFailPolicy, empty means retrying as the other failures.
+const (
+	WriteFailPolicyStop     = "stop"      // stop recording the room
+	WriteFailPolicyPauseAll = "pause-all" // pause all the recorders as the disk is full
+	WriteFailPolicySwitch   = "switch"    // record into FallbackOutputPath since then
+)
+
+// Containers of the recorded files.
+const (
+	ContainerFlv  = "flv"  // follow the stream, flv or ts
+	ContainerMp4  = "mp4"  // plain mp4, unplayable if the recording is killed
+	ContainerFmp4 = "fmp4" // fragmented mp4, playable up to the last fragment if the recording is killed
+)
+
+// LiveInit controls how many times to retry getting the room info when adding a room.
+type LiveInit struct {
+	RetryCount    int           `yaml:"retry_count"`
+	RetryInterval time.Duration `yaml:"retry_interval"`
+}
+
+// Headers overrides the headers used to download the stream of a platform,
+// an empty value removes the header.
+type Headers map[string]string
+
 type Log struct {
 	OutPutFolder string `yaml:"out_put_folder"`
 	SaveLastLog  bool   `yaml:"save_last_log"`
 	SaveEveryLog bool   `yaml:"save_every_log"`
 }
 
+// DiskSpace pauses the recorders when the free space of the out put path is below
+// CriticalFreeSpaceMB, and resumes them above RecoveryFreeSpaceMB, 0 means disabled.
+type DiskSpace struct {
+	CriticalFreeSpaceMB int64 `yaml:"critical_free_space_mb"`
+	RecoveryFreeSpaceMB int64 `yaml:"recovery_free_space_mb"` // CriticalFreeSpaceMB is used when smaller than it
+	// what to do when the recording can't be written to the disk, e.g. ENOSPC, EIO
+	WriteFailPolicy    string `yaml:"write_fail_policy,omitempty"`
+	FallbackOutputPath string `yaml:"fallback_output_path,omitempty"` // used by the switch policy
+}
+
+// Hibernation polls the rooms offline for longer than IdleAfter every Interval seconds
+// instead of the global interval, 0 IdleAfter means disabled.
+type Hibernation struct {
+	IdleAfter time.Duration `yaml:"idle_after"`
+	Interval  int           `yaml:"interval"`
+}
+
 // Config content all config info.
 type Config struct {
 	File                 string               `yaml:"-"`
 	RPC                  RPC                  `yaml:"rpc"`
 	Debug                bool                 `yaml:"debug"`
 	Interval             int                  `yaml:"interval"`
+	PollJitterMs         int                  `yaml:"poll_jitter_ms"` // standard deviation of the random jitter of the interval
 	OutPutPath           string               `yaml:"out_put_path"`
+	MirrorOutputPaths    []string             `yaml:"mirror_output_paths,omitempty"` // the finished files are copied into them
 	FfmpegPath           string               `yaml:"ffmpeg_path"`
 	Log                  Log                  `yaml:"log"`
 	Feature              Feature              `yaml:"feature"`
@@ -76,18 +214,115 @@ type Config struct {
 	OutputTmpl           string               `yaml:"out_put_tmpl"`
 	VideoSplitStrategies VideoSplitStrategies `yaml:"video_split_strategies"`
 	Cookies              map[string]string    `yaml:"cookies"`
+	Headers              map[string]Headers   `yaml:"headers"`
 	OnRecordFinished     OnRecordFinished     `yaml:"on_record_finished"`
 	TimeoutInUs          int                  `yaml:"timeout_in_us"`
+	MinViewersToRecord   int                  `yaml:"min_viewers_to_record"`
+	LiveInit             LiveInit             `yaml:"live_init"`
+	SecretsFile          string               `yaml:"secrets_file"`
+	StartupGraceMode     string               `yaml:"startup_grace_mode"`
+	MaxInitConcurrency   int                  `yaml:"max_init_concurrency"`
+	EndGracePeriod       time.Duration        `yaml:"end_grace_period"`
+	DiskSpace            DiskSpace            `yaml:"disk_space"`
+	Hibernation          Hibernation          `yaml:"hibernation"`
+	Profiles             map[string]Profile   `yaml:"profiles,omitempty"`
+	ActiveProfile        string               `yaml:"active_profile,omitempty"`
+	// stops recording a room after it, unlike VideoSplitStrategies.MaxDuration which only splits the file
+	MaxRecordingDuration             time.Duration     `yaml:"max_recording_duration"`
+	RestartAfterMaxRecordingDuration bool              `yaml:"restart_after_max_recording_duration"` // restart if the room is still living
+	FfmpegExtraArgs                  FfmpegExtraArgs   `yaml:"ffmpeg_extra_args"`
+	SelfTestUrls                     map[string]string `yaml:"self_test_urls,omitempty"` // test rooms of the platform self-test, keyed by domain
+	// caps the concurrent info requests of the platform, keyed by domain, unset or 0 means no limit
+	MaxInflightInfoRequests map[string]int `yaml:"max_inflight_info_requests,omitempty"`
+	// a thumbnail of the stream is captured in this interval during recording, 0 means disabled
+	PeriodicThumbnailIntervalSec int `yaml:"periodic_thumbnail_interval_sec"`
 
 	liveRoomIndexCache map[string]int
 }
 
 type LiveRoom struct {
-	Url         string  `yaml:"url"`
-	IsListening bool    `yaml:"is_listening"`
-	LiveId      live.ID `yaml:"-"`
-	Quality     int     `yaml:"quality"`
-	AudioOnly   bool    `yaml:"audio_only"`
+	Url                   string           `yaml:"url"`
+	IsListening           bool             `yaml:"is_listening"`
+	LiveId                live.ID          `yaml:"-"`
+	Quality               int              `yaml:"quality"`
+	AudioOnly             bool             `yaml:"audio_only"`
+	MinViewersToRecord    *int             `yaml:"min_viewers_to_record,omitempty"`
+	NickName              string           `yaml:"nick_name,omitempty"`
+	DisablePostProcessing bool             `yaml:"disable_post_processing,omitempty"` // skip all the on_record_finished actions
+	Transcode             *Transcode       `yaml:"transcode,omitempty"`
+	Container             string           `yaml:"container,omitempty"` // flv when empty
+	MaxRecordingDuration  *time.Duration   `yaml:"max_recording_duration,omitempty"`
+	FfmpegExtraArgs       *FfmpegExtraArgs `yaml:"ffmpeg_extra_args,omitempty"`
+	Tags                  []string         `yaml:"tags,omitempty"`
+	PollJitterMs          *int             `yaml:"poll_jitter_ms,omitempty"`
+}
+
+// FfmpegExtraArgs are spliced into the command line of the ffmpeg parser,
+// the args are separated by spaces.
+type FfmpegExtraArgs struct {
+	Input  string `yaml:"input,omitempty"`  // before -i, e.g. -reconnect 1 -reconnect_streamed 1
+	Output string `yaml:"output,omitempty"` // before the output file
+}
+
+// the flags set by the ffmpeg parser itself, overriding them breaks the recording
+var reservedFfmpegFlags = map[string]bool{
+	"-i": true, "-y": true, "-n": true, "-progress": true, "-nostats": true, "-stats": true,
+}
+
+// the flags of ffmpeg which take no value, a positional arg after them is an output file
+var valuelessFfmpegFlags = map[string]bool{
+	"-an": true, "-vn": true, "-sn": true, "-dn": true, "-re": true, "-shortest": true,
+	"-copyts": true, "-start_at_zero": true, "-accurate_seek": true, "-noaccurate_seek": true,
+	"-autorotate": true, "-noautorotate": true, "-autoscale": true, "-noautoscale": true,
+	"-ignore_unknown": true, "-copy_unknown": true, "-xerror": true, "-bitexact": true,
+	"-hide_banner": true, "-nostdin": true, "-benchmark": true, "-benchmark_all": true,
+	"-debug_ts": true, "-dump": true, "-hex": true, "-vstats": true, "-intra": true,
+	"-deinterlace": true, "-stdin": true,
+}
+
+// verifyFfmpegArgs checks the args do not contain the reserved flags or a positional arg,
+// which ffmpeg takes as an extra output file.
+func verifyFfmpegArgs(args string) error {
+	afterFlag := false
+	for _, arg := range strings.Fields(args) {
+		if _, err := strconv.ParseFloat(arg, 64); err == nil && afterFlag {
+			// a negative value, e.g. "-map_metadata -1"
+			afterFlag = false
+			continue
+		}
+		if strings.HasPrefix(arg, "-") {
+			if reservedFfmpegFlags[arg] {
+				return fmt.Errorf(`the flag "%s" is set by bililive-go and can not be overridden`, arg)
+			}
+			afterFlag = !valuelessFfmpegFlags[arg]
+			continue
+		}
+		if !afterFlag {
+			return fmt.Errorf(`the arg "%s" is not the value of a flag, output files are not allowed`, arg)
+		}
+		afterFlag = false
+	}
+	return nil
+}
+
+func (a FfmpegExtraArgs) verify(field string) ValidationErrors {
+	var errs ValidationErrors
+	if err := verifyFfmpegArgs(a.Input); err != nil {
+		errs = append(errs, newValidationError(field+".input", CodeInvalidValue, err.Error()))
+	}
+	if err := verifyFfmpegArgs(a.Output); err != nil {
+		errs = append(errs, newValidationError(field+".output", CodeInvalidValue, err.Error()))
+	}
+	return errs
+}
+
+// Transcode re-encodes the stream while recording instead of copying it.
+type Transcode struct {
+	VideoBitrate string `yaml:"video_bitrate"`           // e.g. 1500k
+	AudioBitrate string `yaml:"audio_bitrate,omitempty"` // e.g. 128k, audio is copied when empty
+	Height       int    `yaml:"height,omitempty"`        // scale the video down to the height, 0 keeps the source resolution
+	VideoCodec   string `yaml:"video_codec,omitempty"`   // libx264 when empty
+	Preset       string `yaml:"preset,omitempty"`        // veryfast when empty
 }
 
 type liveRoomAlias LiveRoom
@@ -123,11 +358,12 @@ func NewLiveRoomsWithStrings(strings []string) []LiveRoom {
 }
 
 var defaultConfig = Config{
-	RPC:        defaultRPC,
-	Debug:      false,
-	Interval:   30,
-	OutPutPath: "./",
-	FfmpegPath: "",
+	RPC:          defaultRPC,
+	Debug:        false,
+	Interval:     30,
+	PollJitterMs: 3000,
+	OutPutPath:   "./",
+	FfmpegPath:   "",
 	Log: Log{
 		OutPutFolder: "./",
 		SaveLastLog:  true,
@@ -136,6 +372,7 @@ var defaultConfig = Config{
 	Feature: Feature{
 		UseNativeFlvParser:         false,
 		RemoveSymbolOtherCharacter: false,
+		HWAccel:                    "none",
 	},
 	LiveRooms:          []LiveRoom{},
 	File:               "",
@@ -147,7 +384,16 @@ var defaultConfig = Config{
 		ConvertToMp4:          false,
 		DeleteFlvAfterConvert: false,
 	},
-	TimeoutInUs: 60000000,
+	TimeoutInUs:        60000000,
+	StartupGraceMode:   StartupGraceModeSequential,
+	MaxInitConcurrency: 10,
+	LiveInit: LiveInit{
+		RetryCount:    3,
+		RetryInterval: time.Second,
+	},
+	Hibernation: Hibernation{
+		Interval: 600,
+	},
 }
 
 func NewConfig() *Config {
@@ -157,28 +403,323 @@ func NewConfig() *Config {
 }
 
 // Verify will return an error when this config has problem.
+// The error is a ValidationErrors which contains all problems found.
 func (c *Config) Verify() error {
 	if c == nil {
-		return fmt.Errorf("config is null")
+		return ValidationErrors{newValidationError("", CodeRequired, "config is null")}
 	}
+	errs := make(ValidationErrors, 0)
 	if err := c.RPC.verify(); err != nil {
-		return err
+		errs = append(errs, newValidationError("rpc.bind", CodeInvalidValue, err.Error()))
+	}
+	if c.RPC.RateLimit.RequestsPerMinute < 0 {
+		errs = append(errs, newValidationError("rpc.rate_limit.requests_per_minute", CodeOutOfRange, "the requests_per_minute can not < 0"))
+	}
+	if c.RPC.StreamProxy.MaxClients < 0 {
+		errs = append(errs, newValidationError("rpc.stream_proxy.max_clients", CodeOutOfRange, "the max_clients can not < 0"))
+	}
+	if c.RPC.SSEHeartbeatInterval < 0 {
+		errs = append(errs, newValidationError("rpc.sse_heartbeat_interval", CodeOutOfRange, "the sse_heartbeat_interval can not < 0"))
 	}
 	if c.Interval <= 0 {
-		return fmt.Errorf("the interval can not <= 0")
+		errs = append(errs, newValidationError("interval", CodeOutOfRange, "the interval can not <= 0"))
+	}
+	if err := c.verifyPollJitter("poll_jitter_ms", c.PollJitterMs); err != nil {
+		errs = append(errs, err)
+	}
+	if c.PeriodicThumbnailIntervalSec < 0 {
+		errs = append(errs, newValidationError("periodic_thumbnail_interval_sec", CodeOutOfRange, "the periodic_thumbnail_interval_sec can not < 0"))
 	}
 	if _, err := os.Stat(c.OutPutPath); err != nil {
-		return fmt.Errorf(`the out put path: "%s" is not exist`, c.OutPutPath)
+		errs = append(errs, newValidationError("out_put_path", CodeNotExist, fmt.Sprintf(`the out put path: "%s" is not exist`, c.OutPutPath)))
+	}
+	for i, path := range c.MirrorOutputPaths {
+		field := fmt.Sprintf("mirror_output_paths[%d]", i)
+		if _, err := os.Stat(path); err != nil {
+			errs = append(errs, newValidationError(field, CodeNotExist, fmt.Sprintf(`the mirror output path: "%s" is not exist`, path)))
+		} else if filepath.Clean(path) == filepath.Clean(c.OutPutPath) {
+			errs = append(errs, newValidationError(field, CodeInvalidValue, "the mirror output path can not be the out put path"))
+		}
+	}
+	switch c.StartupGraceMode {
+	case "", StartupGraceModeSequential:
+	case StartupGraceModeFast:
+		if c.MaxInitConcurrency <= 0 {
+			errs = append(errs, newValidationError("max_init_concurrency", CodeOutOfRange, "the max_init_concurrency can not <= 0"))
+		}
+	default:
+		errs = append(errs, newValidationError("startup_grace_mode", CodeInvalidValue, fmt.Sprintf(`the startup_grace_mode: "%s" is invalid`, c.StartupGraceMode)))
+	}
+	if c.LiveInit.RetryCount < 0 {
+		errs = append(errs, newValidationError("live_init.retry_count", CodeOutOfRange, "the retry_count can not < 0"))
+	}
+	if c.EndGracePeriod < 0 {
+		errs = append(errs, newValidationError("end_grace_period", CodeOutOfRange, "the end_grace_period can not < 0"))
+	}
+	if c.MaxRecordingDuration < 0 {
+		errs = append(errs, newValidationError("max_recording_duration", CodeOutOfRange, "the max_recording_duration can not < 0"))
+	}
+	if c.DiskSpace.CriticalFreeSpaceMB < 0 {
+		errs = append(errs, newValidationError("disk_space.critical_free_space_mb", CodeOutOfRange, "the critical_free_space_mb can not < 0"))
+	}
+	if c.DiskSpace.RecoveryFreeSpaceMB < 0 {
+		errs = append(errs, newValidationError("disk_space.recovery_free_space_mb", CodeOutOfRange, "the recovery_free_space_mb can not < 0"))
+	}
+	for domain, limit := range c.MaxInflightInfoRequests {
+		if limit < 0 {
+			errs = append(errs, newValidationError(fmt.Sprintf("max_inflight_info_requests[%s]", domain), CodeOutOfRange, "the max inflight info requests can not < 0"))
+		}
+	}
+	switch c.OnRecordFinished.Dedup.Action {
+	case "", DedupActionRemove, DedupActionHardlink:
+	default:
+		errs = append(errs, newValidationError("on_record_finished.dedup.action", CodeInvalidValue, fmt.Sprintf(`the dedup action: "%s" is invalid`, c.OnRecordFinished.Dedup.Action)))
+	}
+	if c.OnRecordFinished.Dedup.MinSizeKB < 0 {
+		errs = append(errs, newValidationError("on_record_finished.dedup.min_size_kb", CodeOutOfRange, "the min_size_kb can not < 0"))
+	}
+	switch c.DiskSpace.WriteFailPolicy {
+	case "", WriteFailPolicyStop, WriteFailPolicyPauseAll:
+	case WriteFailPolicySwitch:
+		if c.DiskSpace.FallbackOutputPath == "" {
+			errs = append(errs, newValidationError("disk_space.fallback_output_path", CodeRequired, "the fallback_output_path is required by the switch policy"))
+		} else if _, err := os.Stat(c.DiskSpace.FallbackOutputPath); err != nil {
+			errs = append(errs, newValidationError("disk_space.fallback_output_path", CodeNotExist, fmt.Sprintf(`the fallback output path: "%s" is not exist`, c.DiskSpace.FallbackOutputPath)))
+		}
+	default:
+		errs = append(errs, newValidationError("disk_space.write_fail_policy", CodeInvalidValue, fmt.Sprintf(`the write_fail_policy: "%s" is invalid`, c.DiskSpace.WriteFailPolicy)))
+	}
+	errs = append(errs, verifyProcessPriority("feature.ffmpeg_nice", "feature.ffmpeg_io_priority",
+		c.Feature.FfmpegNice, c.Feature.FfmpegIOPriority)...)
+	for domain, priority := range c.Feature.FfmpegPriorities {
+		var (
+			nice       int
+			ioPriority string
+		)
+		if priority.Nice != nil {
+			nice = *priority.Nice
+		}
+		if priority.IOPriority != nil {
+			ioPriority = *priority.IOPriority
+		}
+		field := fmt.Sprintf("feature.ffmpeg_priorities[%s]", domain)
+		errs = append(errs, verifyProcessPriority(field+".nice", field+".io_priority", nice, ioPriority)...)
+	}
+	if hw := c.Feature.HWAccel; hw != "" && hw != HWAccelAuto && hw != HWAccelNone && !hwAccels[hw] {
+		errs = append(errs, newValidationError("feature.hw_accel", CodeInvalidValue, fmt.Sprintf(`the hw_accel: "%s" is invalid`, hw)))
+	}
+	if c.Hibernation.IdleAfter < 0 {
+		errs = append(errs, newValidationError("hibernation.idle_after", CodeOutOfRange, "the idle_after can not < 0"))
+	}
+	if c.Hibernation.IdleAfter > 0 && c.Hibernation.Interval <= 0 {
+		errs = append(errs, newValidationError("hibernation.interval", CodeOutOfRange, "the interval can not <= 0"))
+	}
+	if _, ok := c.Profiles[""]; ok {
+		errs = append(errs, newValidationError("profiles", CodeInvalidValue, "the name of a profile can not be empty"))
+	}
+	if _, ok := c.Profiles[c.ActiveProfile]; c.ActiveProfile != "" && !ok {
+		errs = append(errs, newValidationError("active_profile", CodeNotExist, fmt.Sprintf(`the profile: "%s" is not exist`, c.ActiveProfile)))
+	}
+	if c.MinViewersToRecord < 0 {
+		errs = append(errs, newValidationError("min_viewers_to_record", CodeOutOfRange, "the min_viewers_to_record can not < 0"))
 	}
+	errs = append(errs, c.FfmpegExtraArgs.verify("ffmpeg_extra_args")...)
 	if maxDur := c.VideoSplitStrategies.MaxDuration; maxDur > 0 && maxDur < time.Minute {
-		return fmt.Errorf("the minimum value of max_duration is one minute")
+		errs = append(errs, newValidationError("video_split_strategies.max_duration", CodeOutOfRange, "the minimum value of max_duration is one minute"))
 	}
 	if !c.RPC.Enable && len(c.LiveRooms) == 0 {
-		return fmt.Errorf("the RPC is not enabled, and no live room is set. the program has nothing to do using this setting")
+		errs = append(errs, newValidationError("live_rooms", CodeRequired, "the RPC is not enabled, and no live room is set. the program has nothing to do using this setting"))
+	}
+	for i, room := range c.LiveRooms {
+		if room.MinViewersToRecord != nil && *room.MinViewersToRecord < 0 {
+			errs = append(errs, newValidationError(fmt.Sprintf("live_rooms[%d].min_viewers_to_record", i), CodeOutOfRange, "the min_viewers_to_record can not < 0"))
+		}
+		if room.PollJitterMs != nil {
+			if err := c.verifyPollJitter(fmt.Sprintf("live_rooms[%d].poll_jitter_ms", i), *room.PollJitterMs); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if room.MaxRecordingDuration != nil && *room.MaxRecordingDuration < 0 {
+			errs = append(errs, newValidationError(fmt.Sprintf("live_rooms[%d].max_recording_duration", i), CodeOutOfRange, "the max_recording_duration can not < 0"))
+		}
+		if room.FfmpegExtraArgs != nil {
+			errs = append(errs, room.FfmpegExtraArgs.verify(fmt.Sprintf("live_rooms[%d].ffmpeg_extra_args", i))...)
+		}
+		switch room.Container {
+		case "", ContainerFlv, ContainerMp4, ContainerFmp4:
+		default:
+			errs = append(errs, newValidationError(fmt.Sprintf("live_rooms[%d].container", i), CodeInvalidValue, fmt.Sprintf(`the container: "%s" is invalid`, room.Container)))
+		}
+		if t := room.Transcode; t != nil {
+			if !bitrateRegexp.MatchString(t.VideoBitrate) {
+				errs = append(errs, newValidationError(fmt.Sprintf("live_rooms[%d].transcode.video_bitrate", i), CodeInvalidValue, fmt.Sprintf(`the video_bitrate: "%s" is invalid`, t.VideoBitrate)))
+			}
+			if t.AudioBitrate != "" && !bitrateRegexp.MatchString(t.AudioBitrate) {
+				errs = append(errs, newValidationError(fmt.Sprintf("live_rooms[%d].transcode.audio_bitrate", i), CodeInvalidValue, fmt.Sprintf(`the audio_bitrate: "%s" is invalid`, t.AudioBitrate)))
+			}
+			if t.Height < 0 {
+				errs = append(errs, newValidationError(fmt.Sprintf("live_rooms[%d].transcode.height", i), CodeOutOfRange, "the height can not < 0"))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// GetMinViewersToRecord returns the viewer threshold of the room,
+// the room level setting takes precedence over the global one.
+func (c *Config) GetMinViewersToRecord(url string) int {
+	if room, err := c.GetLiveRoomByUrl(url); err == nil && room.MinViewersToRecord != nil {
+		return *room.MinViewersToRecord
+	}
+	return c.MinViewersToRecord
+}
+
+// verifyPollJitter rejects the negative jitter, and the one not less than half of the interval,
+// with which the normally distributed interval would be negative too often.
+func (c *Config) verifyPollJitter(field string, jitterMs int) *ValidationError {
+	if jitterMs < 0 {
+		return newValidationError(field, CodeOutOfRange, "the poll_jitter_ms can not < 0")
+	}
+	if max := c.Interval * 1000 / 2; c.Interval > 0 && jitterMs >= max {
+		return newValidationError(field, CodeOutOfRange, fmt.Sprintf("the poll_jitter_ms should be less than %d, half of the interval", max))
 	}
 	return nil
 }
 
+// GetPollJitter returns the standard deviation of the random jitter of polling the room.
+func (c *Config) GetPollJitter(url string) time.Duration {
+	jitter := c.PollJitterMs
+	if room, err := c.GetLiveRoomByUrl(url); err == nil && room.PollJitterMs != nil {
+		jitter = *room.PollJitterMs
+	}
+	return time.Duration(jitter) * time.Millisecond
+}
+
+// verifyProcessPriority rejects the nice value out of [-20, 19] and the unknown io priority.
+func verifyProcessPriority(niceField, ioPriorityField string, nice int, ioPriority string) ValidationErrors {
+	var errs ValidationErrors
+	if nice < -20 || nice > 19 {
+		errs = append(errs, newValidationError(niceField, CodeOutOfRange, "the nice value should be in [-20, 19]"))
+	}
+	if _, ok := ioPriorityClasses[ioPriority]; ioPriority != "" && !ok {
+		errs = append(errs, newValidationError(ioPriorityField, CodeInvalidValue, fmt.Sprintf(`the io priority: "%s" is invalid`, ioPriority)))
+	}
+	return errs
+}
+
+// GetFfmpegPriority returns the nice value and the io scheduling class of the ffmpeg processes
+// of the room, the setting of its platform takes precedence over the global one, 0 means unchanged.
+func (c *Config) GetFfmpegPriority(rawUrl string) (nice int, ioClass int) {
+	nice, ioPriority := c.Feature.FfmpegNice, c.Feature.FfmpegIOPriority
+	if u, err := url.Parse(rawUrl); err == nil {
+		if priority, ok := c.Feature.FfmpegPriorities[u.Host]; ok {
+			if priority.Nice != nil {
+				nice = *priority.Nice
+			}
+			if priority.IOPriority != nil {
+				ioPriority = *priority.IOPriority
+			}
+		}
+	}
+	return nice, ioPriorityClasses[ioPriority]
+}
+
+// GetMaxRecordingDuration returns the max recording duration of the room,
+// the room level setting takes precedence over the global one, 0 means no limit.
+func (c *Config) GetMaxRecordingDuration(url string) time.Duration {
+	if room, err := c.GetLiveRoomByUrl(url); err == nil && room.MaxRecordingDuration != nil {
+		return *room.MaxRecordingDuration
+	}
+	return c.MaxRecordingDuration
+}
+
+// GetFfmpegExtraArgs returns the extra ffmpeg args of the room,
+// the room level setting takes precedence over the global one.
+func (c *Config) GetFfmpegExtraArgs(url string) FfmpegExtraArgs {
+	if room, err := c.GetLiveRoomByUrl(url); err == nil && room.FfmpegExtraArgs != nil {
+		return *room.FfmpegExtraArgs
+	}
+	return c.FfmpegExtraArgs
+}
+
+// GetTags returns the tags of the room.
+func (c *Config) GetTags(url string) []string {
+	if room, err := c.GetLiveRoomByUrl(url); err == nil {
+		return room.Tags
+	}
+	return nil
+}
+
+// HasTag reports whether the room is tagged with tag.
+func (l *LiveRoom) HasTag(tag string) bool {
+	for _, t := range l.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// NormalizeTags trims the tags and drops the empty and repeated ones, keeping the order.
+func NormalizeTags(tags []string) []string {
+	seen := make(map[string]struct{}, len(tags))
+	ret := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if _, ok := seen[tag]; ok || tag == "" {
+			continue
+		}
+		seen[tag] = struct{}{}
+		ret = append(ret, tag)
+	}
+	return ret
+}
+
+// IsPostProcessingDisabled reports whether the on_record_finished actions are skipped for the room.
+func (c *Config) IsPostProcessingDisabled(url string) bool {
+	room, err := c.GetLiveRoomByUrl(url)
+	return err == nil && room.DisablePostProcessing
+}
+
+// GetTranscode returns the transcode setting of the room, nil means stream-copy.
+func (c *Config) GetTranscode(url string) *Transcode {
+	if room, err := c.GetLiveRoomByUrl(url); err == nil {
+		return room.Transcode
+	}
+	return nil
+}
+
+// GetContainer returns the container of the recorded files of the room.
+func (c *Config) GetContainer(url string) string {
+	if room, err := c.GetLiveRoomByUrl(url); err == nil && room.Container != "" {
+		return room.Container
+	}
+	return ContainerFlv
+}
+
+// FilterNewLiveRooms returns the rooms whose url is neither in this config nor
+// repeated in rooms, and the urls of the others.
+func (c *Config) FilterNewLiveRooms(rooms []LiveRoom) (newRooms []LiveRoom, duplicates []string) {
+	seen := make(map[string]struct{}, len(c.LiveRooms)+len(rooms))
+	for _, room := range c.LiveRooms {
+		seen[room.Url] = struct{}{}
+	}
+	newRooms = make([]LiveRoom, 0, len(rooms))
+	duplicates = make([]string, 0)
+	for _, room := range rooms {
+		if _, ok := seen[room.Url]; ok {
+			duplicates = append(duplicates, room.Url)
+			continue
+		}
+		seen[room.Url] = struct{}{}
+		newRooms = append(newRooms, room)
+	}
+	return newRooms, duplicates
+}
+
 func (c *Config) RefreshLiveRoomIndexCache() {
 	for index, room := range c.LiveRooms {
 		c.liveRoomIndexCache[room.Url] = index
@@ -236,14 +777,31 @@ func NewConfigWithFile(file string) (*Config, error) {
 		return nil, err
 	}
 	config.File = file
+	if config.SecretsFile != "" {
+		secrets, err := loadSecrets(config.GetSecretsFilePath())
+		if err != nil {
+			return nil, err
+		}
+		config.mergeSecrets(secrets)
+	}
 	return config, nil
 }
 
+// Marshal writes the config into its file, when SecretsFile is set the
+// secrets are written into that file and replaced by placeholders in the main one.
 func (c *Config) Marshal() error {
 	if c.File == "" {
 		return errors.New("config path not set")
 	}
-	b, err := yaml.Marshal(c)
+	config := c
+	if c.SecretsFile != "" {
+		var secrets *Secrets
+		config, secrets = c.splitSecrets()
+		if err := writeSecrets(c.GetSecretsFilePath(), secrets); err != nil {
+			return err
+		}
+	}
+	b, err := yaml.Marshal(config)
 	if err != nil {
 		return err
 	}
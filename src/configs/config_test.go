@@ -3,8 +3,11 @@ package configs
 import (
 	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/hr3lxphr6j/bililive-go/src/live"
 )
 
 func TestNewConfig(t *testing.T) {
@@ -42,3 +45,317 @@ func TestConfig_Verify(t *testing.T) {
 	cfg.RPC.Enable = false
 	assert.Error(t, cfg.Verify())
 }
+
+func TestConfig_VerifyCollectsAllErrors(t *testing.T) {
+	minViewers := -1
+	cfg := &Config{
+		RPC:        RPC{Enable: true, Bind: "foo@bar"},
+		Interval:   0,
+		OutPutPath: "foobar",
+		LiveRooms: []LiveRoom{
+			{Url: "https://live.bilibili.com/1", MinViewersToRecord: &minViewers},
+		},
+	}
+	err := cfg.Verify()
+	errs, ok := err.(ValidationErrors)
+	assert.True(t, ok)
+	fields := make([]string, 0, len(errs))
+	for _, e := range errs {
+		fields = append(fields, e.Field)
+	}
+	assert.Equal(t, []string{"rpc.bind", "interval", "out_put_path", "live_rooms[0].min_viewers_to_record"}, fields)
+	assert.Equal(t, CodeOutOfRange, errs[1].Code)
+	assert.Contains(t, err.Error(), "interval: the interval can not <= 0; ")
+}
+
+func TestConfig_IsPostProcessingDisabled(t *testing.T) {
+	cfg := NewConfig()
+	cfg.LiveRooms = []LiveRoom{
+		{Url: "https://live.bilibili.com/1"},
+		{Url: "https://live.bilibili.com/2", DisablePostProcessing: true},
+	}
+	cfg.RefreshLiveRoomIndexCache()
+	assert.False(t, cfg.IsPostProcessingDisabled("https://live.bilibili.com/1"))
+	assert.True(t, cfg.IsPostProcessingDisabled("https://live.bilibili.com/2"))
+	assert.False(t, cfg.IsPostProcessingDisabled("https://live.bilibili.com/3"))
+}
+
+func TestConfig_VerifyTranscode(t *testing.T) {
+	cfg := NewConfig()
+	cfg.LiveRooms = []LiveRoom{
+		{Url: "https://live.bilibili.com/1", Transcode: &Transcode{VideoBitrate: "1500k", AudioBitrate: "128k", Height: 720}},
+		{Url: "https://live.bilibili.com/2", Transcode: &Transcode{VideoBitrate: "fast", Height: -1}},
+	}
+	errs, ok := cfg.Verify().(ValidationErrors)
+	assert.True(t, ok)
+	fields := make([]string, 0, len(errs))
+	for _, e := range errs {
+		fields = append(fields, e.Field)
+	}
+	assert.Equal(t, []string{"live_rooms[1].transcode.video_bitrate", "live_rooms[1].transcode.height"}, fields)
+
+	cfg.RefreshLiveRoomIndexCache()
+	assert.Equal(t, "1500k", cfg.GetTranscode("https://live.bilibili.com/1").VideoBitrate)
+	assert.Nil(t, cfg.GetTranscode("https://live.bilibili.com/3"))
+}
+
+func TestConfig_FilterNewLiveRooms(t *testing.T) {
+	cfg := NewConfig()
+	cfg.LiveRooms = NewLiveRoomsWithStrings([]string{"https://live.bilibili.com/1"})
+	imported, err := NewConfigWithBytes([]byte(`
+live_rooms:
+  - https://live.bilibili.com/1
+  - url: https://live.bilibili.com/2
+    quality: 1
+  - url: https://live.bilibili.com/3
+    is_listening: false
+  - https://live.bilibili.com/2
+`))
+	assert.NoError(t, err)
+	newRooms, duplicates := cfg.FilterNewLiveRooms(imported.LiveRooms)
+	assert.Equal(t, []LiveRoom{
+		{Url: "https://live.bilibili.com/2", IsListening: true, Quality: 1},
+		{Url: "https://live.bilibili.com/3", IsListening: false},
+	}, newRooms)
+	assert.Equal(t, []string{"https://live.bilibili.com/1", "https://live.bilibili.com/2"}, duplicates)
+}
+
+func TestChangedFields(t *testing.T) {
+	oldConfig := NewConfig()
+	oldConfig.LiveRooms = []LiveRoom{{Url: "https://live.bilibili.com/1", IsListening: true}}
+	newConfig := NewConfig()
+	newConfig.LiveRooms = []LiveRoom{{Url: "https://live.bilibili.com/1", IsListening: true, LiveId: "id"}}
+	newConfig.File = "config.yml"
+	assert.Empty(t, ChangedFields(oldConfig, newConfig))
+
+	newConfig.LiveRooms = append(newConfig.LiveRooms, LiveRoom{Url: "https://live.bilibili.com/2"})
+	newConfig.Interval = 60
+	newConfig.Feature.UseNativeFlvParser = true
+	assert.Equal(t, []string{"interval", "feature", "live_rooms"}, ChangedFields(oldConfig, newConfig))
+}
+
+func TestConfig_GetContainer(t *testing.T) {
+	cfg := NewConfig()
+	cfg.LiveRooms = []LiveRoom{
+		{Url: "https://live.bilibili.com/1"},
+		{Url: "https://live.bilibili.com/2", Container: ContainerFmp4},
+		{Url: "https://live.bilibili.com/3", Container: "mkv"},
+	}
+	errs, ok := cfg.Verify().(ValidationErrors)
+	assert.True(t, ok)
+	assert.Len(t, errs, 1)
+	assert.Equal(t, "live_rooms[2].container", errs[0].Field)
+
+	cfg.RefreshLiveRoomIndexCache()
+	assert.Equal(t, ContainerFlv, cfg.GetContainer("https://live.bilibili.com/1"))
+	assert.Equal(t, ContainerFmp4, cfg.GetContainer("https://live.bilibili.com/2"))
+	assert.Equal(t, ContainerFlv, cfg.GetContainer("https://live.bilibili.com/4"))
+}
+
+func TestConfig_VerifyFfmpegExtraArgs(t *testing.T) {
+	cfg := NewConfig()
+	cfg.FfmpegExtraArgs = FfmpegExtraArgs{Input: "-reconnect 1 -reconnect_streamed 1", Output: "-an -map_metadata -1"}
+	cfg.LiveRooms = []LiveRoom{
+		{Url: "https://live.bilibili.com/1", FfmpegExtraArgs: &FfmpegExtraArgs{Input: "-i http://example.com/a.flv"}},
+		{Url: "https://live.bilibili.com/2", FfmpegExtraArgs: &FfmpegExtraArgs{Output: "-c:v libx264 out.mp4"}},
+		{Url: "https://live.bilibili.com/3"},
+		// an output file after the flags without value
+		{Url: "https://live.bilibili.com/4", FfmpegExtraArgs: &FfmpegExtraArgs{Output: "-an out.mp4"}},
+		{Url: "https://live.bilibili.com/5", FfmpegExtraArgs: &FfmpegExtraArgs{Output: "-map_metadata -1 out.mp4"}},
+		{Url: "https://live.bilibili.com/6", FfmpegExtraArgs: &FfmpegExtraArgs{Output: "-vn -shortest -b:a 128k"}},
+	}
+	errs, ok := cfg.Verify().(ValidationErrors)
+	assert.True(t, ok)
+	fields := make([]string, 0, len(errs))
+	for _, e := range errs {
+		fields = append(fields, e.Field)
+	}
+	assert.Equal(t, []string{
+		"live_rooms[0].ffmpeg_extra_args.input",
+		"live_rooms[1].ffmpeg_extra_args.output",
+		"live_rooms[3].ffmpeg_extra_args.output",
+		"live_rooms[4].ffmpeg_extra_args.output",
+	}, fields)
+
+	cfg.RefreshLiveRoomIndexCache()
+	assert.Equal(t, "-i http://example.com/a.flv", cfg.GetFfmpegExtraArgs("https://live.bilibili.com/1").Input)
+	assert.Equal(t, cfg.FfmpegExtraArgs, cfg.GetFfmpegExtraArgs("https://live.bilibili.com/3"))
+}
+
+func TestConfig_Tags(t *testing.T) {
+	assert.Equal(t, []string{"gaming", "vtuber"}, NormalizeTags([]string{" gaming", "", "vtuber", "gaming "}))
+
+	cfg := NewConfig()
+	cfg.LiveRooms = []LiveRoom{
+		{Url: "https://live.bilibili.com/1", Tags: []string{"gaming"}},
+		{Url: "https://live.bilibili.com/2"},
+	}
+	cfg.RefreshLiveRoomIndexCache()
+	assert.Equal(t, []string{"gaming"}, cfg.GetTags("https://live.bilibili.com/1"))
+	assert.Nil(t, cfg.GetTags("https://live.bilibili.com/3"))
+	assert.True(t, cfg.LiveRooms[0].HasTag("gaming"))
+	assert.False(t, cfg.LiveRooms[1].HasTag("gaming"))
+}
+
+func TestConfig_Profiles(t *testing.T) {
+	cfg := NewConfig()
+	cfg.OutPutPath = os.TempDir()
+	cfg.LiveRooms = []LiveRoom{{Url: "https://live.bilibili.com/1", IsListening: true, LiveId: "id1"}}
+	cfg.OnRecordFinished.ConvertToMp4 = true
+	cfg.Profiles = map[string]Profile{
+		"archival": {LiveRooms: []LiveRoom{{Url: "https://live.bilibili.com/1", IsListening: true}}, OnRecordFinished: &OnRecordFinished{ConvertToMp4: true}},
+		"monitoring": {LiveRooms: []LiveRoom{
+			{Url: "https://live.bilibili.com/1", IsListening: false},
+			{Url: "https://live.bilibili.com/2", IsListening: true},
+		}},
+	}
+	cfg.ActiveProfile = "archival"
+	assert.NoError(t, cfg.Verify())
+
+	// the changes are stored into the active profile before switching away
+	cfg.LiveRooms[0].Quality = 1
+	cfg.StoreActiveProfile()
+	assert.Equal(t, 1, cfg.Profiles["archival"].LiveRooms[0].Quality)
+
+	cfg.OnRecordFinished.ConvertToMp4 = false
+	assert.NoError(t, cfg.UseProfile("monitoring"))
+	assert.Equal(t, "monitoring", cfg.ActiveProfile)
+	assert.Len(t, cfg.LiveRooms, 2)
+	assert.False(t, cfg.LiveRooms[0].IsListening)
+	assert.Equal(t, live.ID("id1"), cfg.LiveRooms[0].LiveId)
+	// kept as monitoring doesn't set it
+	assert.False(t, cfg.OnRecordFinished.ConvertToMp4)
+	room, err := cfg.GetLiveRoomByUrl("https://live.bilibili.com/2")
+	assert.NoError(t, err)
+	assert.True(t, room.IsListening)
+
+	assert.NoError(t, cfg.UseProfile("archival"))
+	assert.True(t, cfg.OnRecordFinished.ConvertToMp4)
+	assert.Equal(t, ErrProfileNotExist, cfg.UseProfile("unknown"))
+
+	// saved into a new profile without an active one
+	cfg.ActiveProfile = ""
+	cfg.Profiles[DefaultProfileName] = Profile{}
+	assert.Equal(t, DefaultProfileName+"-2", cfg.StoreActiveProfile())
+	assert.Equal(t, cfg.LiveRooms, cfg.Profiles[DefaultProfileName+"-2"].LiveRooms)
+	assert.True(t, cfg.Profiles[DefaultProfileName+"-2"].OnRecordFinished.ConvertToMp4)
+
+	cfg.ActiveProfile = "unknown"
+	errs, ok := cfg.Verify().(ValidationErrors)
+	assert.True(t, ok)
+	assert.Equal(t, "active_profile", errs[0].Field)
+}
+
+func TestConfig_VerifyFfmpegPriority(t *testing.T) {
+	cfg := NewConfig()
+	cfg.OutPutPath = os.TempDir()
+	cfg.Feature.FfmpegNice, cfg.Feature.FfmpegIOPriority = 10, IOPriorityIdle
+	assert.NoError(t, cfg.Verify())
+	cfg.Feature.FfmpegNice, cfg.Feature.FfmpegIOPriority = 20, "realtime"
+	errs, ok := cfg.Verify().(ValidationErrors)
+	assert.True(t, ok)
+	assert.Len(t, errs, 2)
+
+	cfg.Feature.FfmpegNice, cfg.Feature.FfmpegIOPriority = 0, ""
+	nice, ioPriority := -21, "realtime"
+	cfg.Feature.FfmpegPriorities = map[string]ProcessPriority{"live.douyin.com": {Nice: &nice, IOPriority: &ioPriority}}
+	errs, ok = cfg.Verify().(ValidationErrors)
+	assert.True(t, ok)
+	assert.Len(t, errs, 2)
+	assert.Equal(t, "feature.ffmpeg_priorities[live.douyin.com].nice", errs[0].Field)
+}
+
+func TestConfig_GetFfmpegPriority(t *testing.T) {
+	cfg := NewConfig()
+	cfg.Feature.FfmpegNice, cfg.Feature.FfmpegIOPriority = 10, IOPriorityIdle
+	nice, ioPriority := 0, IOPriorityBestEffort
+	cfg.Feature.FfmpegPriorities = map[string]ProcessPriority{
+		"live.bilibili.com": {Nice: &nice},
+		"live.douyin.com":   {IOPriority: &ioPriority},
+	}
+	n, ioClass := cfg.GetFfmpegPriority("https://www.huya.com/1")
+	assert.Equal(t, 10, n)
+	assert.Equal(t, 3, ioClass)
+	// the unset fields are inherited
+	n, ioClass = cfg.GetFfmpegPriority("https://live.bilibili.com/1")
+	assert.Equal(t, 0, n)
+	assert.Equal(t, 3, ioClass)
+	n, ioClass = cfg.GetFfmpegPriority("https://live.douyin.com/1")
+	assert.Equal(t, 10, n)
+	assert.Equal(t, 2, ioClass)
+}
+
+func TestConfig_VerifyHWAccel(t *testing.T) {
+	cfg := NewConfig()
+	cfg.OutPutPath = os.TempDir()
+	for _, hw := range []string{"", HWAccelAuto, HWAccelNone, "cuda", "vaapi", "videotoolbox"} {
+		cfg.Feature.HWAccel = hw
+		assert.NoError(t, cfg.Verify(), hw)
+	}
+	cfg.Feature.HWAccel = "gpu"
+	errs, ok := cfg.Verify().(ValidationErrors)
+	assert.True(t, ok)
+	assert.Equal(t, "feature.hw_accel", errs[0].Field)
+}
+
+func TestConfig_GetPollJitter(t *testing.T) {
+	cfg := NewConfig()
+	cfg.OutPutPath = os.TempDir()
+	jitter := 0
+	cfg.LiveRooms = []LiveRoom{{Url: "https://live.bilibili.com/1", PollJitterMs: &jitter}}
+	cfg.RefreshLiveRoomIndexCache()
+	assert.Equal(t, time.Duration(0), cfg.GetPollJitter("https://live.bilibili.com/1"))
+	assert.Equal(t, 3*time.Second, cfg.GetPollJitter("https://live.bilibili.com/2"))
+	assert.NoError(t, cfg.Verify())
+
+	cfg.PollJitterMs = -1
+	errs, ok := cfg.Verify().(ValidationErrors)
+	assert.True(t, ok)
+	assert.Equal(t, "poll_jitter_ms", errs[0].Field)
+
+	// not less than half of the interval, globally and per room
+	cfg.Interval = 20
+	cfg.PollJitterMs = 9999
+	assert.NoError(t, cfg.Verify())
+	cfg.PollJitterMs = 10000
+	jitter = 20000
+	errs, ok = cfg.Verify().(ValidationErrors)
+	assert.True(t, ok)
+	assert.Len(t, errs, 2)
+	assert.Equal(t, "poll_jitter_ms", errs[0].Field)
+	assert.Equal(t, "live_rooms[0].poll_jitter_ms", errs[1].Field)
+	assert.Equal(t, CodeOutOfRange, errs[1].Code)
+}
+
+func TestConfig_VerifyWriteFailPolicy(t *testing.T) {
+	cfg := NewConfig()
+	cfg.OutPutPath = os.TempDir()
+	cfg.DiskSpace.WriteFailPolicy = WriteFailPolicyPauseAll
+	assert.NoError(t, cfg.Verify())
+	cfg.DiskSpace.WriteFailPolicy, cfg.DiskSpace.FallbackOutputPath = WriteFailPolicySwitch, os.TempDir()
+	assert.NoError(t, cfg.Verify())
+
+	cfg.DiskSpace.FallbackOutputPath = ""
+	errs, ok := cfg.Verify().(ValidationErrors)
+	assert.True(t, ok)
+	assert.Equal(t, "disk_space.fallback_output_path", errs[0].Field)
+	cfg.DiskSpace.WriteFailPolicy = "retry"
+	errs, ok = cfg.Verify().(ValidationErrors)
+	assert.True(t, ok)
+	assert.Equal(t, "disk_space.write_fail_policy", errs[0].Field)
+}
+
+func TestConfig_VerifyDedup(t *testing.T) {
+	cfg := NewConfig()
+	cfg.OutPutPath = os.TempDir()
+	cfg.OnRecordFinished.Dedup = FragmentDedup{Enable: true, Action: DedupActionHardlink, MinSizeKB: 64}
+	assert.NoError(t, cfg.Verify())
+
+	cfg.OnRecordFinished.Dedup.Action = "merge"
+	cfg.OnRecordFinished.Dedup.MinSizeKB = -1
+	errs, ok := cfg.Verify().(ValidationErrors)
+	assert.True(t, ok)
+	assert.Len(t, errs, 2)
+	assert.Equal(t, "on_record_finished.dedup.action", errs[0].Field)
+	assert.Equal(t, "on_record_finished.dedup.min_size_kb", errs[1].Field)
+}
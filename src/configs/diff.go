@@ -0,0 +1,29 @@
+package configs
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ChangedFields returns the yaml names of the top level fields which differ between
+// the two configs, the fields not saved in the config file are ignored.
+func ChangedFields(oldConfig, newConfig *Config) []string {
+	fields := make([]string, 0)
+	oldValue, newValue := reflect.ValueOf(oldConfig).Elem(), reflect.ValueOf(newConfig).Elem()
+	typ := oldValue.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		name := strings.Split(typ.Field(i).Tag.Get("yaml"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		oldBytes, err1 := yaml.Marshal(oldValue.Field(i).Interface())
+		newBytes, err2 := yaml.Marshal(newValue.Field(i).Interface())
+		if err1 != nil || err2 != nil || !bytes.Equal(oldBytes, newBytes) {
+			fields = append(fields, name)
+		}
+	}
+	return fields
+}
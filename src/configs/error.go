@@ -0,0 +1,44 @@
+package configs
+
+import "strings"
+
+// Codes of ValidationError.
+const (
+	CodeRequired     = "required"
+	CodeInvalidValue = "invalid_value"
+	CodeOutOfRange   = "out_of_range"
+	CodeNotExist     = "not_exist"
+)
+
+// ValidationError describes a problem of a single config field.
+type ValidationError struct {
+	Field   string `json:"field"` // path of the field in yaml, e.g. "rpc.bind", "live_rooms[0].url"
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func newValidationError(field, code, msg string) *ValidationError {
+	return &ValidationError{
+		Field:   field,
+		Code:    code,
+		Message: msg,
+	}
+}
+
+func (e *ValidationError) Error() string {
+	if e.Field == "" {
+		return e.Message
+	}
+	return e.Field + ": " + e.Message
+}
+
+// ValidationErrors is returned by Config.Verify and contains all problems found.
+type ValidationErrors []*ValidationError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
@@ -0,0 +1,177 @@
+package configs
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The config formats of other recorders whose room lists can be imported.
+const (
+	ExternalFormatBlrec            = "blrec"             // settings.toml of blrec, or the json from its settings api
+	ExternalFormatBililiveRecorder = "bililive-recorder" // config.json (version 3) of BililiveRecorder
+)
+
+var ErrFormatNotSupported = errors.New("external config format is not supported")
+
+const bilibiliRoomUrlPrefix = "https://live.bilibili.com/"
+
+// ParseExternalLiveRooms converts the room list in the config of another recorder into live rooms,
+// the entries which can not be mapped are described in unmapped.
+func ParseExternalLiveRooms(format string, data []byte) (rooms []LiveRoom, unmapped []string, err error) {
+	switch format {
+	case ExternalFormatBlrec:
+		return parseBlrecRooms(data)
+	case ExternalFormatBililiveRecorder:
+		return parseBililiveRecorderRooms(data)
+	default:
+		return nil, nil, ErrFormatNotSupported
+	}
+}
+
+type blrecTask struct {
+	RoomId         int64 `json:"room_id"`
+	EnableMonitor  *bool `json:"enable_monitor"`
+	EnableRecorder *bool `json:"enable_recorder"`
+}
+
+// parseBlrecRooms maps the tasks of blrec, it's bilibili only, a task is listened when both
+// the monitor and the recorder of it are enabled, which is the default of blrec.
+func parseBlrecRooms(data []byte) ([]LiveRoom, []string, error) {
+	var tasks []blrecTask
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		settings := struct {
+			Tasks []blrecTask `json:"tasks"`
+		}{}
+		if err := json.Unmarshal(trimmed, &settings); err != nil {
+			return nil, nil, err
+		}
+		tasks = settings.Tasks
+	} else {
+		var err error
+		if tasks, err = parseBlrecToml(data); err != nil {
+			return nil, nil, err
+		}
+	}
+	rooms := make([]LiveRoom, 0, len(tasks))
+	unmapped := make([]string, 0)
+	for i, task := range tasks {
+		if task.RoomId <= 0 {
+			unmapped = append(unmapped, fmt.Sprintf("tasks[%d]: room_id is missing", i))
+			continue
+		}
+		rooms = append(rooms, LiveRoom{
+			Url:         bilibiliRoomUrlPrefix + strconv.FormatInt(task.RoomId, 10),
+			IsListening: boolOrTrue(task.EnableMonitor) && boolOrTrue(task.EnableRecorder),
+		})
+	}
+	return rooms, unmapped, nil
+}
+
+// parseBlrecToml reads the keys of the [[tasks]] tables in the settings.toml of blrec,
+// only the flat "key = value" lines are needed, so it's not a full toml parser.
+func parseBlrecToml(data []byte) ([]blrecTask, error) {
+	tasks := make([]blrecTask, 0)
+	inTask := false
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+		switch {
+		case line == "":
+			continue
+		case line == "[[tasks]]":
+			tasks = append(tasks, blrecTask{})
+			inTask = true
+			continue
+		case strings.HasPrefix(line, "["):
+			// the other tables, including the sub tables of the task like [tasks.output]
+			inTask = false
+			continue
+		case !inTask:
+			continue
+		}
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("line %d: invalid toml: %s", lineNo, line)
+		}
+		task := &tasks[len(tasks)-1]
+		key, value := strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+		var err error
+		switch key {
+		case "room_id":
+			task.RoomId, err = strconv.ParseInt(value, 10, 64)
+		case "enable_monitor":
+			task.EnableMonitor, err = parseTomlBool(value)
+		case "enable_recorder":
+			task.EnableRecorder, err = parseTomlBool(value)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid value of %s: %s", lineNo, key, value)
+		}
+	}
+	return tasks, scanner.Err()
+}
+
+func parseTomlBool(value string) (*bool, error) {
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+func boolOrTrue(b *bool) bool {
+	return b == nil || *b
+}
+
+// optional is how BililiveRecorder stores the settings which fall back to the global ones.
+type optional struct {
+	HasValue bool            `json:"HasValue"`
+	Value    json.RawMessage `json:"Value"`
+}
+
+// parseBililiveRecorderRooms maps the rooms of BililiveRecorder, it's bilibili only,
+// a room is listened when auto recording is enabled, which is the default of it.
+func parseBililiveRecorderRooms(data []byte) ([]LiveRoom, []string, error) {
+	cfg := struct {
+		Version int `json:"version"`
+		Rooms   []struct {
+			RoomId     optional `json:"RoomId"`
+			AutoRecord optional `json:"AutoRecord"`
+		} `json:"rooms"`
+	}{}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, nil, err
+	}
+	if cfg.Version != 3 {
+		return nil, nil, fmt.Errorf("version %d of the config of BililiveRecorder is not supported, only 3 is", cfg.Version)
+	}
+	rooms := make([]LiveRoom, 0, len(cfg.Rooms))
+	unmapped := make([]string, 0)
+	for i, room := range cfg.Rooms {
+		var roomId int64
+		if room.RoomId.HasValue {
+			_ = json.Unmarshal(room.RoomId.Value, &roomId)
+		}
+		if roomId <= 0 {
+			unmapped = append(unmapped, fmt.Sprintf("rooms[%d]: RoomId is missing", i))
+			continue
+		}
+		autoRecord := true
+		if room.AutoRecord.HasValue {
+			_ = json.Unmarshal(room.AutoRecord.Value, &autoRecord)
+		}
+		rooms = append(rooms, LiveRoom{
+			Url:         bilibiliRoomUrlPrefix + strconv.FormatInt(roomId, 10),
+			IsListening: autoRecord,
+		})
+	}
+	return rooms, unmapped, nil
+}
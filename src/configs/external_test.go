@@ -0,0 +1,65 @@
+package configs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseExternalLiveRooms(t *testing.T) {
+	toml := `
+[output]
+out_dir = "rec"
+
+[[tasks]]
+room_id = 493 # comment
+enable_monitor = true
+enable_recorder = false
+
+[tasks.output]
+room_id = 1
+
+[[tasks]]
+room_id = 23058
+
+[[tasks]]
+enable_monitor = true
+`
+	rooms, unmapped, err := ParseExternalLiveRooms(ExternalFormatBlrec, []byte(toml))
+	assert.NoError(t, err)
+	assert.Equal(t, []LiveRoom{
+		{Url: "https://live.bilibili.com/493", IsListening: false},
+		{Url: "https://live.bilibili.com/23058", IsListening: true},
+	}, rooms)
+	assert.Equal(t, []string{"tasks[2]: room_id is missing"}, unmapped)
+
+	rooms, _, err = ParseExternalLiveRooms(ExternalFormatBlrec, []byte(`{"tasks":[{"room_id":493,"enable_monitor":true}]}`))
+	assert.NoError(t, err)
+	assert.Equal(t, []LiveRoom{{Url: "https://live.bilibili.com/493", IsListening: true}}, rooms)
+
+	_, _, err = ParseExternalLiveRooms(ExternalFormatBlrec, []byte("[[tasks]]\nroom_id = abc"))
+	assert.Error(t, err)
+
+	json := `{
+  "$schema": "https://raw.githubusercontent.com/Bililive/BililiveRecorder/dev/configV3.schema.json",
+  "version": 3,
+  "global": {"RecordMode": {"HasValue": true, "Value": 0}},
+  "rooms": [
+    {"RoomId": {"HasValue": true, "Value": 493}, "AutoRecord": {"HasValue": true, "Value": false}},
+    {"RoomId": {"HasValue": true, "Value": 23058}, "AutoRecord": {"HasValue": false}},
+    {"RoomId": {"HasValue": false}}
+  ]
+}`
+	rooms, unmapped, err = ParseExternalLiveRooms(ExternalFormatBililiveRecorder, []byte(json))
+	assert.NoError(t, err)
+	assert.Equal(t, []LiveRoom{
+		{Url: "https://live.bilibili.com/493", IsListening: false},
+		{Url: "https://live.bilibili.com/23058", IsListening: true},
+	}, rooms)
+	assert.Equal(t, []string{"rooms[2]: RoomId is missing"}, unmapped)
+
+	_, _, err = ParseExternalLiveRooms(ExternalFormatBililiveRecorder, []byte(`{"version":2,"rooms":[]}`))
+	assert.Error(t, err)
+	_, _, err = ParseExternalLiveRooms("unknown", nil)
+	assert.Equal(t, ErrFormatNotSupported, err)
+}
@@ -0,0 +1,75 @@
+package configs
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/hr3lxphr6j/bililive-go/src/live"
+)
+
+var ErrProfileNotExist = errors.New("profile is not exist")
+
+// DefaultProfileName is the profile the rooms are saved into when switching without an active profile.
+const DefaultProfileName = "default"
+
+// Profile is a named set of rooms and on_record_finished actions, which can be
+// switched to at runtime, e.g. one for archiving and one for monitoring only.
+type Profile struct {
+	LiveRooms        []LiveRoom        `yaml:"live_rooms"`
+	OnRecordFinished *OnRecordFinished `yaml:"on_record_finished,omitempty"` // the current ones are kept when nil
+}
+
+// StoreActiveProfile saves the current rooms and on_record_finished actions into the active
+// profile, so that the changes made since switching to it are not lost when switching away.
+// Without an active profile, they are saved into a new one named DefaultProfileName, or with
+// a numeric suffix when the name is taken, whose name is returned.
+func (c *Config) StoreActiveProfile() string {
+	name := c.ActiveProfile
+	profile, ok := c.Profiles[name]
+	if name == "" {
+		name = DefaultProfileName
+		for i := 2; ; i++ {
+			if _, ok := c.Profiles[name]; !ok {
+				break
+			}
+			name = fmt.Sprintf("%s-%d", DefaultProfileName, i)
+		}
+		if c.Profiles == nil {
+			c.Profiles = make(map[string]Profile)
+		}
+		profile = Profile{OnRecordFinished: new(OnRecordFinished)}
+	} else if !ok {
+		return ""
+	}
+	profile.LiveRooms = append([]LiveRoom(nil), c.LiveRooms...)
+	if profile.OnRecordFinished != nil {
+		onRecordFinished := c.OnRecordFinished
+		profile.OnRecordFinished = &onRecordFinished
+	}
+	c.Profiles[name] = profile
+	return name
+}
+
+// UseProfile replaces the rooms and on_record_finished actions with the ones of the profile,
+// the running lives are not touched, they should be reconciled with the rooms beforehand.
+func (c *Config) UseProfile(name string) error {
+	profile, ok := c.Profiles[name]
+	if !ok {
+		return ErrProfileNotExist
+	}
+	liveIds := make(map[string]live.ID, len(c.LiveRooms))
+	for _, room := range c.LiveRooms {
+		liveIds[room.Url] = room.LiveId
+	}
+	rooms := append([]LiveRoom(nil), profile.LiveRooms...)
+	for i := range rooms {
+		rooms[i].LiveId = liveIds[rooms[i].Url]
+	}
+	c.LiveRooms = rooms
+	if profile.OnRecordFinished != nil {
+		c.OnRecordFinished = *profile.OnRecordFinished
+	}
+	c.ActiveProfile = name
+	c.RefreshLiveRoomIndexCache()
+	return nil
+}
@@ -0,0 +1,120 @@
+package configs
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v2"
+)
+
+// SecretPlaceholder is written into the main config in place of the values
+// which are stored in the secrets file.
+const SecretPlaceholder = "<secret>"
+
+const secretsFilePerm os.FileMode = 0600
+
+// Secrets contains the credentials which are kept in a separate file when
+// Config.SecretsFile is set, so that the main config can be shared safely.
+type Secrets struct {
+	Cookies map[string]string `yaml:"cookies,omitempty"`
+}
+
+// GetSecretsFilePath returns the path of the secrets file, a relative path is
+// resolved against the directory of the main config file.
+func (c Config) GetSecretsFilePath() string {
+	if c.SecretsFile == "" || filepath.IsAbs(c.SecretsFile) || c.File == "" {
+		return c.SecretsFile
+	}
+	return filepath.Join(filepath.Dir(c.File), c.SecretsFile)
+}
+
+func loadSecrets(file string) (*Secrets, error) {
+	secrets := new(Secrets)
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return secrets, nil
+		}
+		return nil, fmt.Errorf("can`t open secrets file: %s", file)
+	}
+	if err := yaml.Unmarshal(b, secrets); err != nil {
+		return nil, err
+	}
+	return secrets, nil
+}
+
+func writeSecrets(file string, secrets *Secrets) error {
+	b, err := yaml.Marshal(secrets)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(file, b, secretsFilePerm); err != nil {
+		return err
+	}
+	// WriteFile keeps the permission of an existing file
+	return os.Chmod(file, secretsFilePerm)
+}
+
+// mergeSecrets fills the config with the values from secrets, placeholders
+// without a matching secret are dropped.
+func (c *Config) mergeSecrets(secrets *Secrets) {
+	cookies := make(map[string]string, len(c.Cookies)+len(secrets.Cookies))
+	for host, cookie := range c.Cookies {
+		if cookie != SecretPlaceholder {
+			cookies[host] = cookie
+		}
+	}
+	for host, cookie := range secrets.Cookies {
+		cookies[host] = cookie
+	}
+	c.Cookies = cookies
+}
+
+// splitSecrets returns a copy of the config with the secrets replaced by
+// placeholders, and the secrets themselves.
+func (c *Config) splitSecrets() (*Config, *Secrets) {
+	public := *c
+	secrets := &Secrets{Cookies: make(map[string]string, len(c.Cookies))}
+	public.Cookies = make(map[string]string, len(c.Cookies))
+	for host, cookie := range c.Cookies {
+		secrets.Cookies[host] = cookie
+		public.Cookies[host] = SecretPlaceholder
+	}
+	return &public, secrets
+}
+
+// FillSecrets replaces the placeholders in the config with the values of the secrets file, e.g. the
+// config posted by the raw config editor, the placeholders without a matching secret are dropped
+// and the other cookies are kept as they are.
+func (c *Config) FillSecrets() error {
+	if c.SecretsFile == "" {
+		return nil
+	}
+	secrets, err := loadSecrets(c.GetSecretsFilePath())
+	if err != nil {
+		return err
+	}
+	for host, cookie := range c.Cookies {
+		if cookie != SecretPlaceholder {
+			continue
+		}
+		if secret, ok := secrets.Cookies[host]; ok {
+			c.Cookies[host] = secret
+		} else {
+			delete(c.Cookies, host)
+		}
+	}
+	return nil
+}
+
+// MarshalPublic returns the yaml of the config as it's written into the main config file,
+// with the secrets replaced by placeholders when SecretsFile is set.
+func (c *Config) MarshalPublic() ([]byte, error) {
+	config := c
+	if c.SecretsFile != "" {
+		config, _ = c.splitSecrets()
+	}
+	return yaml.Marshal(config)
+}
@@ -0,0 +1,50 @@
+package configs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig_Secrets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "configs")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "config.yml")
+	c := NewConfig()
+	c.File = file
+	c.SecretsFile = "secrets.yml"
+	c.Cookies = map[string]string{"live.bilibili.com": "SESSDATA=foo"}
+	assert.NoError(t, c.Marshal())
+
+	b, err := ioutil.ReadFile(file)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(b), "SESSDATA")
+	assert.Contains(t, string(b), SecretPlaceholder)
+	assert.Equal(t, "SESSDATA=foo", c.Cookies["live.bilibili.com"])
+
+	stat, err := os.Stat(filepath.Join(dir, "secrets.yml"))
+	assert.NoError(t, err)
+	if os.PathSeparator == '/' {
+		assert.Equal(t, secretsFilePerm, stat.Mode().Perm())
+	}
+
+	c2, err := NewConfigWithFile(file)
+	assert.NoError(t, err)
+	assert.Equal(t, c.Cookies, c2.Cookies)
+}
+
+func TestConfig_SecretsFileNotExist(t *testing.T) {
+	c := &Config{
+		SecretsFile: "secrets.yml",
+		Cookies:     map[string]string{"a.com": SecretPlaceholder, "b.com": "k=v"},
+	}
+	secrets, err := loadSecrets(filepath.Join(os.TempDir(), "not-exist-secrets.yml"))
+	assert.NoError(t, err)
+	c.mergeSecrets(secrets)
+	assert.Equal(t, map[string]string{"b.com": "k=v"}, c.Cookies)
+}
@@ -8,6 +8,9 @@ import (
 
 const (
 	AppName = "BiliLive-go"
+
+	// unknown is reported for the build info which is not injected by ldflags.
+	unknown = "unknown"
 )
 
 type Info struct {
@@ -26,11 +29,18 @@ var (
 	GitHash    string
 	AppInfo    = Info{
 		AppName:    AppName,
-		AppVersion: AppVersion,
-		BuildTime:  BuildTime,
-		GitHash:    GitHash,
+		AppVersion: orUnknown(AppVersion),
+		BuildTime:  orUnknown(BuildTime),
+		GitHash:    orUnknown(GitHash),
 		Pid:        os.Getpid(),
 		Platform:   fmt.Sprintf("%s/%s", runtime.GOOS, runtime.GOARCH),
 		GoVersion:  runtime.Version(),
 	}
 )
+
+func orUnknown(s string) string {
+	if s == "" {
+		return unknown
+	}
+	return s
+}
@@ -11,13 +11,14 @@ import (
 )
 
 type Instance struct {
-	WaitGroup       sync.WaitGroup
-	Config          *configs.Config
-	Logger          *interfaces.Logger
-	Lives           map[live.ID]live.Live
-	Cache           gcache.Cache
-	Server          interfaces.Module
-	EventDispatcher interfaces.Module
-	ListenerManager interfaces.Module
-	RecorderManager interfaces.Module
+	WaitGroup        sync.WaitGroup
+	Config           *configs.Config
+	Logger           *interfaces.Logger
+	Lives            map[live.ID]live.Live
+	Cache            gcache.Cache
+	Server           interfaces.Module
+	EventDispatcher  interfaces.Module
+	ListenerManager  interfaces.Module
+	RecorderManager  interfaces.Module
+	DiskSpaceManager interfaces.Module
 }
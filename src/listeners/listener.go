@@ -30,14 +30,20 @@ type Listener interface {
 
 func NewListener(ctx context.Context, live live.Live) Listener {
 	inst := instance.GetInstance(ctx)
+	// the rooms not seen living yet are idle since the start of the listener
+	lastActive := live.GetLastStartTime()
+	if lastActive.IsZero() {
+		lastActive = time.Now()
+	}
 	return &listener{
-		Live:   live,
-		status: status{},
-		config: inst.Config,
-		stop:   make(chan struct{}),
-		ed:     inst.EventDispatcher.(events.Dispatcher),
-		logger: inst.Logger,
-		state:  begin,
+		Live:       live,
+		status:     status{},
+		config:     inst.Config,
+		stop:       make(chan struct{}),
+		ed:         inst.EventDispatcher.(events.Dispatcher),
+		logger:     inst.Logger,
+		state:      begin,
+		lastActive: lastActive,
 	}
 }
 
@@ -48,6 +54,8 @@ type listener struct {
 	config *configs.Config
 	ed     events.Dispatcher
 	logger *interfaces.Logger
+	// the last time the room was seen living, used by the hibernation
+	lastActive time.Time
 
 	state uint32
 	stop  chan struct{}
@@ -93,6 +101,14 @@ func (l *listener) refresh() {
 		}
 	)
 	defer func() { l.status = latestStatus }()
+	if info.Status {
+		l.lastActive = time.Now()
+	}
+	if !l.status.roomStatus && latestStatus.roomStatus && !l.reachMinViewers(info) {
+		// keep listening, the threshold will be checked again on the next poll
+		l.logger.WithFields(fields).Debugf("viewer count %d is below the threshold, skip recording", info.ViewerCount)
+		latestStatus.roomStatus = false
+	}
 	isStatusChanged := true
 	switch l.status.Diff(latestStatus) {
 	case 0:
@@ -129,14 +145,39 @@ func (l *listener) refresh() {
 	}
 }
 
+// reachMinViewers reports whether the viewer count of the room reaches the
+// configured threshold, platforms that don't report viewers are always allowed.
+func (l *listener) reachMinViewers(info *live.Info) bool {
+	if !info.HasViewerCount {
+		return true
+	}
+	return info.ViewerCount >= int64(l.config.GetMinViewersToRecord(l.Live.GetRawUrl()))
+}
+
+// pollInterval returns the interval of polling the room, the rooms offline for longer
+// than hibernation.idle_after are polled in the hibernation interval.
+func (l *listener) pollInterval(now time.Time) time.Duration {
+	interval := time.Duration(l.config.Interval) * time.Second
+	h := l.config.Hibernation
+	if h.IdleAfter <= 0 || l.status.roomStatus || now.Sub(l.lastActive) < h.IdleAfter {
+		return interval
+	}
+	if hibernation := time.Duration(h.Interval) * time.Second; hibernation > interval {
+		return hibernation
+	}
+	return interval
+}
+
+func (l *listener) newTicker(interval time.Duration) *jitterbug.Ticker {
+	return jitterbug.New(interval, jitterbug.Norm{
+		Stdev: l.config.GetPollJitter(l.Live.GetRawUrl()),
+	})
+}
+
 func (l *listener) run() {
-	ticker := jitterbug.New(
-		time.Duration(l.config.Interval)*time.Second,
-		jitterbug.Norm{
-			Stdev: time.Second * 3,
-		},
-	)
-	defer ticker.Stop()
+	interval := l.pollInterval(time.Now())
+	ticker := l.newTicker(interval)
+	defer func() { ticker.Stop() }()
 
 	for {
 		select {
@@ -144,6 +185,20 @@ func (l *listener) run() {
 			return
 		case <-ticker.C:
 			l.refresh()
+			next := l.pollInterval(time.Now())
+			if next == interval {
+				continue
+			}
+			logger := l.logger.WithField("url", l.Live.GetRawUrl())
+			switch {
+			case next > interval:
+				logger.Infof("offline since %s, hibernate and check every %s", l.lastActive.Format("2006-01-02 15:04:05"), next)
+			default:
+				logger.Infof("wake up from hibernation, check every %s", next)
+			}
+			ticker.Stop()
+			interval = next
+			ticker = l.newTicker(interval)
 		}
 	}
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/bluele/gcache"
 	"github.com/golang/mock/gomock"
@@ -32,6 +33,7 @@ func TestRefresh(t *testing.T) {
 	})
 	log.New(ctx)
 	live := livemock.NewMockLive(ctrl)
+	live.EXPECT().GetLastStartTime().Return(time.Time{})
 	l := NewListener(ctx, live).(*listener)
 
 	// false -> false
@@ -63,6 +65,48 @@ func TestRefresh(t *testing.T) {
 	assert.False(t, l.status.roomStatus)
 }
 
+func TestRefreshWithMinViewers(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ed := evtmock.NewMockDispatcher(ctrl)
+	cfg := configs.NewConfig()
+	cfg.MinViewersToRecord = 100
+	ctx := context.WithValue(context.Background(), instance.Key, &instance.Instance{
+		EventDispatcher: ed,
+		Config:          cfg,
+	})
+	log.New(ctx)
+	live := livemock.NewMockLive(ctrl)
+	live.EXPECT().GetRawUrl().Return("https://example.com/1").AnyTimes()
+	live.EXPECT().GetLastStartTime().Return(time.Time{})
+	l := NewListener(ctx, live).(*listener)
+
+	// false -> true, below threshold
+	live.EXPECT().GetInfo().Return(&livepkg.Info{Status: true, ViewerCount: 10, HasViewerCount: true}, nil)
+	l.refresh()
+	assert.False(t, l.status.roomStatus)
+
+	// false -> true, reach threshold
+	live.EXPECT().GetInfo().Return(&livepkg.Info{Status: true, ViewerCount: 100, HasViewerCount: true}, nil)
+	live.EXPECT().SetLastStartTime(gomock.Any())
+	ed.EXPECT().DispatchEvent(events.NewEvent(LiveStart, live))
+	l.refresh()
+	assert.True(t, l.status.roomStatus)
+
+	// true -> true, the threshold is not checked once recording
+	live.EXPECT().GetInfo().Return(&livepkg.Info{Status: true, ViewerCount: 10, HasViewerCount: true}, nil)
+	l.refresh()
+	assert.True(t, l.status.roomStatus)
+
+	// platform without viewer count
+	l.status = status{}
+	live.EXPECT().GetInfo().Return(&livepkg.Info{Status: true}, nil)
+	live.EXPECT().SetLastStartTime(gomock.Any())
+	ed.EXPECT().DispatchEvent(events.NewEvent(LiveStart, live))
+	l.refresh()
+	assert.True(t, l.status.roomStatus)
+}
+
 func TestRefreshWithError(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -75,6 +119,7 @@ func TestRefreshWithError(t *testing.T) {
 	})
 	log.New(ctx)
 	live := livemock.NewMockLive(ctrl)
+	live.EXPECT().GetLastStartTime().Return(time.Time{})
 	l := NewListener(ctx, live).(*listener)
 
 	live.EXPECT().GetInfo().Return(nil, errors.New("this is error"))
@@ -98,10 +143,56 @@ func TestListenerStartAndClose(t *testing.T) {
 	log.New(ctx)
 	live := livemock.NewMockLive(ctrl)
 	live.EXPECT().GetInfo().Return(&livepkg.Info{Status: false}, nil)
+	// the jitter of the room is looked up by run, which races with Close
+	live.EXPECT().GetRawUrl().Return("https://example.com/1").AnyTimes()
 	ed.EXPECT().DispatchEvent(gomock.Any()).Times(2)
+	live.EXPECT().GetLastStartTime().Return(time.Time{})
 	l := NewListener(ctx, live)
 	assert.NoError(t, l.Start())
 	assert.NoError(t, l.Start())
 	l.Close()
 	l.Close()
 }
+
+func TestPollInterval(t *testing.T) {
+	cfg := configs.NewConfig()
+	cfg.Interval = 30
+	now := time.Now()
+	l := &listener{config: cfg, lastActive: now.Add(-48 * time.Hour)}
+
+	// disabled
+	assert.Equal(t, 30*time.Second, l.pollInterval(now))
+
+	cfg.Hibernation = configs.Hibernation{IdleAfter: 24 * time.Hour, Interval: 600}
+	assert.Equal(t, 600*time.Second, l.pollInterval(now))
+	l.lastActive = now.Add(-time.Hour)
+	assert.Equal(t, 30*time.Second, l.pollInterval(now))
+	l.lastActive = now.Add(-48 * time.Hour)
+	l.status.roomStatus = true
+	assert.Equal(t, 30*time.Second, l.pollInterval(now))
+	l.status.roomStatus = false
+	// never polled more frequently than the global interval
+	cfg.Hibernation.Interval = 10
+	assert.Equal(t, 30*time.Second, l.pollInterval(now))
+}
+
+func TestNewListenerNeverLiving(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	cfg := configs.NewConfig()
+	cfg.Interval = 30
+	cfg.Hibernation = configs.Hibernation{IdleAfter: time.Hour, Interval: 600}
+	ctx := context.WithValue(context.Background(), instance.Key, &instance.Instance{
+		EventDispatcher: evtmock.NewMockDispatcher(ctrl),
+		Config:          cfg,
+	})
+	log.New(ctx)
+	live := livemock.NewMockLive(ctrl)
+	live.EXPECT().GetLastStartTime().Return(time.Time{})
+	start := time.Now()
+	l := NewListener(ctx, live).(*listener)
+
+	// idle since the start of the listener, not hibernated on the first tick
+	assert.Equal(t, 30*time.Second, l.pollInterval(start))
+	assert.Equal(t, 600*time.Second, l.pollInterval(start.Add(2*time.Hour)))
+}
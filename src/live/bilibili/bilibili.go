@@ -6,6 +6,7 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/hr3lxphr6j/requests"
 	"github.com/tidwall/gjson"
@@ -26,10 +27,14 @@ const (
 	appLiveApiUrlv2 = "https://api.live.bilibili.com/xlive/app-room/v2/index/getRoomPlayInfo"
 	biliAppAgent    = "Bilibili Freedoooooom/MarkII BiliDroid/5.49.0 os/android model/MuMu mobi_app/android build/5490400 channel/dw090 innerVer/5490400 osVer/6.0.1 network/2"
 	biliWebAgent    = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_12_6) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/59.0.3071.115 Safari/537.36"
+
+	// the code of the api when the requests are blocked for being too frequent
+	codeRequestBlocked = -412
 )
 
 func init() {
 	live.Register(domain, new(builder))
+	live.RegisterSelfTestUrl(domain, "https://live.bilibili.com/1030")
 }
 
 type builder struct{}
@@ -63,6 +68,9 @@ func (l *Live) parseRealId() error {
 		return live.ErrRoomNotExist
 	}
 	body, err := resp.Bytes()
+	if err == nil && gjson.GetBytes(body, "code").Int() == codeRequestBlocked {
+		return &live.RateLimitedError{}
+	}
 	if err != nil || gjson.GetBytes(body, "code").Int() != 0 {
 		return live.ErrRoomNotExist
 	}
@@ -99,15 +107,21 @@ func (l *Live) GetInfo() (info *live.Info, err error) {
 	if err != nil {
 		return nil, err
 	}
-	if gjson.GetBytes(body, "code").Int() != 0 {
+	switch gjson.GetBytes(body, "code").Int() {
+	case 0:
+	case codeRequestBlocked:
+		return nil, &live.RateLimitedError{}
+	default:
 		return nil, live.ErrRoomNotExist
 	}
 
 	info = &live.Info{
-		Live:      l,
-		RoomName:  gjson.GetBytes(body, "data.title").String(),
-		Status:    gjson.GetBytes(body, "data.live_status").Int() == 1,
-		AudioOnly: l.Options.AudioOnly,
+		Live:           l,
+		RoomName:       gjson.GetBytes(body, "data.title").String(),
+		Status:         gjson.GetBytes(body, "data.live_status").Int() == 1,
+		AudioOnly:      l.Options.AudioOnly,
+		ViewerCount:    gjson.GetBytes(body, "data.online").Int(),
+		HasViewerCount: gjson.GetBytes(body, "data.online").Exists(),
 	}
 
 	resp, err = requests.Get(userApiUrl, live.CommonUserAgent, requests.Query("roomid", l.realID))
@@ -201,6 +215,11 @@ func (l *Live) GetPlatformCNName() string {
 	return cnName
 }
 
+// GetStreamUrlExpiry parses the expires signed into the url, in unix seconds.
+func (l *Live) GetStreamUrlExpiry(u *url.URL) (time.Time, bool) {
+	return live.ParseUnixExpiry(u, "expires", 10)
+}
+
 func (l *Live) GetHeadersForDownloader() map[string]string {
 	agent := biliWebAgent
 	referer := l.GetRawUrl()
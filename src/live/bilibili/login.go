@@ -0,0 +1,89 @@
+package bilibili
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/hr3lxphr6j/requests"
+	"github.com/tidwall/gjson"
+
+	"github.com/hr3lxphr6j/bililive-go/src/live"
+)
+
+// codes of the qr code polling api
+const (
+	qrCodeSucceeded = 0
+	qrCodeExpired   = 86038
+	qrCodeScanned   = 86090
+	qrCodeNotScan   = 86101
+)
+
+// for test
+var (
+	qrCodeGenerateUrl = "https://passport.bilibili.com/x/passport-login/web/qrcode/generate"
+	qrCodePollUrl     = "https://passport.bilibili.com/x/passport-login/web/qrcode/poll"
+)
+
+func init() {
+	live.RegisterLoginAssist(domain, new(loginAssist))
+}
+
+// loginAssist logs in by scanning the qr code with the bilibili app.
+type loginAssist struct{}
+
+func (a *loginAssist) StartLogin() (*live.LoginSession, error) {
+	resp, err := requests.Get(qrCodeGenerateUrl, requests.UserAgent(biliWebAgent))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, live.ErrInternalError
+	}
+	body, err := resp.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	if gjson.GetBytes(body, "code").Int() != 0 {
+		return nil, live.ErrInternalError
+	}
+	return &live.LoginSession{
+		Key:    gjson.GetBytes(body, "data.qrcode_key").String(),
+		QRCode: gjson.GetBytes(body, "data.url").String(),
+	}, nil
+}
+
+func (a *loginAssist) PollLogin(key string) (*live.LoginResult, error) {
+	resp, err := requests.Get(qrCodePollUrl, requests.UserAgent(biliWebAgent), requests.Query("qrcode_key", key))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, live.ErrInternalError
+	}
+	cookies := resp.Cookies()
+	body, err := resp.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	if gjson.GetBytes(body, "code").Int() != 0 {
+		return nil, live.ErrInternalError
+	}
+	switch gjson.GetBytes(body, "data.code").Int() {
+	case qrCodeSucceeded:
+		kvs := make([]string, 0, len(cookies))
+		for _, cookie := range cookies {
+			kvs = append(kvs, cookie.Name+"="+cookie.Value)
+		}
+		sort.Strings(kvs)
+		return &live.LoginResult{Status: live.LoginSucceeded, Cookies: strings.Join(kvs, "; ")}, nil
+	case qrCodeScanned:
+		return &live.LoginResult{Status: live.LoginScanned}, nil
+	case qrCodeNotScan:
+		return &live.LoginResult{Status: live.LoginPending}, nil
+	case qrCodeExpired:
+		return &live.LoginResult{Status: live.LoginExpired}, nil
+	default:
+		return nil, live.ErrInternalError
+	}
+}
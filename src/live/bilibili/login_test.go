@@ -0,0 +1,59 @@
+package bilibili
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hr3lxphr6j/bililive-go/src/live"
+)
+
+func TestLoginAssist(t *testing.T) {
+	pollCode := qrCodeNotScan
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/generate":
+			fmt.Fprint(w, `{"code":0,"data":{"url":"https://account.bilibili.com/h5/account-h5/auth/scan-web?qrcode_key=abc","qrcode_key":"abc"}}`)
+		case "/poll":
+			assert.Equal(t, "abc", r.URL.Query().Get("qrcode_key"))
+			if pollCode == qrCodeSucceeded {
+				http.SetCookie(w, &http.Cookie{Name: "SESSDATA", Value: "sess"})
+				http.SetCookie(w, &http.Cookie{Name: "DedeUserID", Value: "1"})
+			}
+			fmt.Fprintf(w, `{"code":0,"data":{"code":%d}}`, pollCode)
+		}
+	}))
+	defer server.Close()
+	backupGenerate, backupPoll := qrCodeGenerateUrl, qrCodePollUrl
+	qrCodeGenerateUrl, qrCodePollUrl = server.URL+"/generate", server.URL+"/poll"
+	defer func() { qrCodeGenerateUrl, qrCodePollUrl = backupGenerate, backupPoll }()
+
+	assist, err := live.GetLoginAssist(domain)
+	assert.NoError(t, err)
+	session, err := assist.StartLogin()
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", session.Key)
+	assert.Contains(t, session.QRCode, "qrcode_key=abc")
+
+	for code, status := range map[int]live.LoginStatus{
+		qrCodeNotScan: live.LoginPending,
+		qrCodeScanned: live.LoginScanned,
+		qrCodeExpired: live.LoginExpired,
+	} {
+		pollCode = code
+		result, err := assist.PollLogin("abc")
+		assert.NoError(t, err)
+		assert.Equal(t, &live.LoginResult{Status: status}, result)
+	}
+
+	pollCode = qrCodeSucceeded
+	result, err := assist.PollLogin("abc")
+	assert.NoError(t, err)
+	assert.Equal(t, &live.LoginResult{Status: live.LoginSucceeded, Cookies: "DedeUserID=1; SESSDATA=sess"}, result)
+
+	_, err = live.GetLoginAssist("www.example.com")
+	assert.Equal(t, live.ErrLoginNotSupport, err)
+}
@@ -0,0 +1,173 @@
+package live
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// for test
+var (
+	// the breaker of a platform opens after these consecutive failures of all its rooms within the window
+	breakerFailureThreshold = 10
+	breakerFailureWindow    = 5 * time.Minute
+	// the requests are paused for this long before one is let through to test the recovery
+	breakerCooldown = 5 * time.Minute
+
+	now = time.Now
+)
+
+// PlatformStatus is the state of the circuit breaker of a platform.
+type PlatformStatus struct {
+	Platform            string    `json:"platform"`
+	Degraded            bool      `json:"degraded"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	LastError           string    `json:"last_error,omitempty"`
+	RetryAt             time.Time `json:"retry_at,omitempty"` // when the next request is let through, only set when degraded
+	RateLimited         bool      `json:"rate_limited"`
+	RateLimitedUntil    time.Time `json:"rate_limited_until,omitempty"` // the backoff asked by the platform, only set when rate limited
+}
+
+// circuitBreaker stops requesting a platform whose implementation keeps failing, so that
+// the retries of its rooms don't hammer it, and lets a request through after the cooldown.
+type circuitBreaker struct {
+	lock         sync.Mutex
+	platform     string
+	failures     int
+	firstFailure time.Time
+	lastError    string
+	openedAt     time.Time // zero means closed
+	probing      bool      // a request is let through in the half-open state
+
+	// the requests are paused until then as the platform asked, e.g. by the Retry-After of HTTP 429
+	rateLimitedUntil time.Time
+}
+
+// rateLimited reports whether the platform is still in the backoff it asked for.
+func (b *circuitBreaker) rateLimited() bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return now().Before(b.rateLimitedUntil)
+}
+
+// allow reports whether a request can be sent to the platform.
+func (b *circuitBreaker) allow() bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.openedAt.IsZero() {
+		return true
+	}
+	if b.probing || now().Sub(b.openedAt) < breakerCooldown {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+// record updates the breaker with the result of a request which was allowed.
+func (b *circuitBreaker) record(err error) {
+	b.lock.Lock()
+	degraded := !b.openedAt.IsZero()
+	// called after unlocking, the hooks may read the status
+	defer func() {
+		if s := b.status(); s.Degraded != degraded {
+			notifyPlatformStatusChanged(s)
+		}
+	}()
+	defer b.lock.Unlock()
+	if err == nil {
+		b.failures, b.lastError, b.openedAt, b.probing = 0, "", time.Time{}, false
+		return
+	}
+	if err == ErrRoomNotExist {
+		// a problem of the room rather than the platform, but the platform answered,
+		// so the probe of the half-open state counts as a success
+		if b.probing {
+			b.failures, b.lastError, b.openedAt, b.probing = 0, "", time.Time{}, false
+		}
+		return
+	}
+	var rateLimited *RateLimitedError
+	if errors.As(err, &rateLimited) {
+		// the platform is fine, it only asks to slow down, so it's not counted as a failure
+		b.rateLimitedUntil, b.probing = now().Add(rateLimited.backoff()), false
+		return
+	}
+	t := now()
+	if b.failures == 0 || t.Sub(b.firstFailure) > breakerFailureWindow {
+		b.failures, b.firstFailure = 0, t
+	}
+	b.failures++
+	b.lastError = err.Error()
+	if b.probing || (b.openedAt.IsZero() && b.failures >= breakerFailureThreshold) {
+		b.openedAt, b.probing = t, false
+	}
+}
+
+func (b *circuitBreaker) status() PlatformStatus {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	s := PlatformStatus{
+		Platform:            b.platform,
+		Degraded:            !b.openedAt.IsZero(),
+		ConsecutiveFailures: b.failures,
+		LastError:           b.lastError,
+	}
+	if s.Degraded {
+		s.RetryAt = b.openedAt.Add(breakerCooldown)
+	}
+	if now().Before(b.rateLimitedUntil) {
+		s.RateLimited, s.RateLimitedUntil = true, b.rateLimitedUntil
+	}
+	return s
+}
+
+var platformStatusHooks = struct {
+	sync.Mutex
+	fns []func(PlatformStatus)
+}{}
+
+// OnPlatformStatusChanged adds the callback called when a platform becomes degraded or recovers.
+func OnPlatformStatusChanged(fn func(PlatformStatus)) {
+	platformStatusHooks.Lock()
+	defer platformStatusHooks.Unlock()
+	platformStatusHooks.fns = append(platformStatusHooks.fns, fn)
+}
+
+func notifyPlatformStatusChanged(s PlatformStatus) {
+	platformStatusHooks.Lock()
+	fns := append([]func(PlatformStatus){}, platformStatusHooks.fns...)
+	platformStatusHooks.Unlock()
+	for _, fn := range fns {
+		fn(s)
+	}
+}
+
+var breakers = struct {
+	sync.Mutex
+	m map[string]*circuitBreaker
+}{m: make(map[string]*circuitBreaker)}
+
+func getBreaker(platform string) *circuitBreaker {
+	breakers.Lock()
+	defer breakers.Unlock()
+	b, ok := breakers.m[platform]
+	if !ok {
+		b = &circuitBreaker{platform: platform}
+		breakers.m[platform] = b
+	}
+	return b
+}
+
+// GetPlatformStatuses returns the states of the platforms which have rooms, sorted by the domain.
+func GetPlatformStatuses() []PlatformStatus {
+	breakers.Lock()
+	statuses := make([]PlatformStatus, 0, len(breakers.m))
+	for _, b := range breakers.m {
+		statuses = append(statuses, b.status())
+	}
+	breakers.Unlock()
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Platform < statuses[j].Platform })
+	return statuses
+}
@@ -0,0 +1,113 @@
+package live
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	current := time.Now()
+	backup := now
+	now = func() time.Time { return current }
+	defer func() { now = backup }()
+
+	b := &circuitBreaker{platform: "example.com"}
+	errFailed := errors.New("failed")
+	for i := 0; i < breakerFailureThreshold-1; i++ {
+		assert.True(t, b.allow())
+		b.record(errFailed)
+	}
+	// the missing rooms don't count
+	b.record(ErrRoomNotExist)
+	assert.False(t, b.status().Degraded)
+	// the streak is too old
+	current = current.Add(breakerFailureWindow + time.Second)
+	b.record(errFailed)
+	assert.False(t, b.status().Degraded)
+	assert.Equal(t, 1, b.status().ConsecutiveFailures)
+
+	for i := 1; i < breakerFailureThreshold; i++ {
+		b.record(errFailed)
+	}
+	s := b.status()
+	assert.True(t, s.Degraded)
+	assert.Equal(t, "failed", s.LastError)
+	assert.Equal(t, current.Add(breakerCooldown), s.RetryAt)
+	assert.False(t, b.allow())
+
+	// half open, only one request is let through, and it fails
+	current = current.Add(breakerCooldown)
+	assert.True(t, b.allow())
+	assert.False(t, b.allow())
+	b.record(errFailed)
+	assert.False(t, b.allow())
+
+	current = current.Add(breakerCooldown)
+	assert.True(t, b.allow())
+	b.record(nil)
+	assert.False(t, b.status().Degraded)
+	assert.Equal(t, 0, b.status().ConsecutiveFailures)
+	assert.True(t, b.allow())
+
+	// the probe hits a missing room, the platform answered so it's closed rather than stuck half open
+	for i := 0; i < breakerFailureThreshold; i++ {
+		b.record(errFailed)
+	}
+	assert.True(t, b.status().Degraded)
+	current = current.Add(breakerCooldown)
+	assert.True(t, b.allow())
+	b.record(ErrRoomNotExist)
+	assert.False(t, b.status().Degraded)
+	assert.True(t, b.allow())
+	assert.True(t, b.allow())
+}
+
+type failingLive struct {
+	fakeLive
+	calls int
+}
+
+func (f *failingLive) GetInfo() (*Info, error) {
+	f.calls++
+	return nil, errors.New("failed")
+}
+
+func TestWrappedLiveWithBreaker(t *testing.T) {
+	backup := breakerFailureThreshold
+	breakerFailureThreshold = 1
+	defer func() { breakerFailureThreshold = backup }()
+
+	l := new(failingLive)
+	w := newWrappedLive(l, nil, MustNewOptions()).(*WrappedLive)
+	w.breaker = &circuitBreaker{platform: "example.com"}
+	_, err := w.getInfo()
+	assert.EqualError(t, err, "failed")
+	// not requested any more
+	_, err = w.getInfo()
+	assert.Equal(t, ErrPlatformDegraded, err)
+	assert.Equal(t, 1, l.calls)
+}
+
+func TestPlatformStatusHooks(t *testing.T) {
+	var statuses []PlatformStatus
+	OnPlatformStatusChanged(func(s PlatformStatus) {
+		if s.Platform == "hooks.example.com" {
+			statuses = append(statuses, s)
+		}
+	})
+	b := &circuitBreaker{platform: "hooks.example.com"}
+	errFailed := errors.New("failed")
+	for i := 0; i < breakerFailureThreshold+1; i++ {
+		b.record(errFailed)
+	}
+	b.record(nil)
+	b.record(nil)
+	// notified only when it opens and closes
+	assert.Len(t, statuses, 2)
+	assert.True(t, statuses[0].Degraded)
+	assert.Equal(t, "failed", statuses[0].LastError)
+	assert.False(t, statuses[1].Degraded)
+}
@@ -0,0 +1,27 @@
+package live
+
+import "sync"
+
+// inflightLimits caps the concurrent info requests of each platform, keyed by the host, unlike
+// the spacing of the requests it prevents the bursts of connections, e.g. the rooms initialized
+// in parallel at startup.
+var inflightLimits = struct {
+	sync.Mutex
+	m map[string]chan struct{}
+}{m: make(map[string]chan struct{})}
+
+// getInflightLimit returns the semaphore shared by the lives of the platform, nil when limit <= 0.
+// A new one is made when the limit is changed, the lives created before keep the old one.
+func getInflightLimit(host string, limit int) chan struct{} {
+	if limit <= 0 {
+		return nil
+	}
+	inflightLimits.Lock()
+	defer inflightLimits.Unlock()
+	if sem, ok := inflightLimits.m[host]; ok && cap(sem) == limit {
+		return sem
+	}
+	sem := make(chan struct{}, limit)
+	inflightLimits.m[host] = sem
+	return sem
+}
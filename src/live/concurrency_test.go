@@ -0,0 +1,51 @@
+package live
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type slowLive struct {
+	Live
+	inflight, max int32
+}
+
+func (s *slowLive) GetInfo() (*Info, error) {
+	n := atomic.AddInt32(&s.inflight, 1)
+	for {
+		max := atomic.LoadInt32(&s.max)
+		if n <= max || atomic.CompareAndSwapInt32(&s.max, max, n) {
+			break
+		}
+	}
+	time.Sleep(10 * time.Millisecond)
+	atomic.AddInt32(&s.inflight, -1)
+	return &Info{}, nil
+}
+
+func TestInflightLimit(t *testing.T) {
+	assert.Nil(t, getInflightLimit("a.example.com", 0))
+	sem := getInflightLimit("a.example.com", 2)
+	assert.Equal(t, sem, getInflightLimit("a.example.com", 2))
+	assert.Equal(t, 3, cap(getInflightLimit("a.example.com", 3)))
+
+	l := new(slowLive)
+	sem = getInflightLimit("b.example.com", 2)
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		w := newWrappedLive(l, nil, MustNewOptions()).(*WrappedLive)
+		w.inflight = sem
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := w.getInfo()
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(2), atomic.LoadInt32(&l.max))
+}
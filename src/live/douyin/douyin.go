@@ -71,9 +71,10 @@ func (l *Live) getLiveRoomWebPageResponse() (body string, err error) {
 
 	// proxy, _ := url.Parse("http://localhost:8888")
 	requestSession := requests.NewSession(&http.Client{
-		// Transport: &http.Transport{
+		Transport: live.WithRateLimit(nil),
+		// Transport: live.WithRateLimit(&http.Transport{
 		// 	Proxy: http.ProxyURL(proxy),
-		// },
+		// }),
 	})
 	req, err := requests.NewRequest(
 		http.MethodGet,
@@ -8,4 +8,10 @@ var (
 	ErrRoomNotExist     = errors.New("room not exists")
 	ErrRoomUrlIncorrect = errors.New("room url incorrect")
 	ErrInternalError    = errors.New("internal error")
+	ErrNotSupportUrl    = errors.New("not support this url")
+	ErrLoginNotSupport  = errors.New("login is not supported by this platform")
+	ErrPlatformNotExist = errors.New("platform not exists")
+	ErrNoSelfTestUrl    = errors.New("no test url of this platform, one has to be given")
+	ErrPlatformDegraded = errors.New("the platform keeps failing, requests are paused for a while")
+	ErrPlatformLimited  = errors.New("the platform rate limits the requests, they are paused as it asked")
 )
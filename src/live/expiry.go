@@ -0,0 +1,39 @@
+package live
+
+import (
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// StreamUrlExpirer is implemented by the platforms whose stream urls are signed with an expiry,
+// the recorder resolves the stream again shortly before it instead of waiting for the failure.
+type StreamUrlExpirer interface {
+	// GetStreamUrlExpiry returns when the url expires, false when it's unknown.
+	GetStreamUrlExpiry(u *url.URL) (time.Time, bool)
+}
+
+// ParseUnixExpiry parses the query parameter of the url as the unix seconds in the base,
+// e.g. 10 for "expires=1622548800", 16 for "wsTime=60b5f1c0".
+func ParseUnixExpiry(u *url.URL, key string, base int) (time.Time, bool) {
+	if u == nil {
+		return time.Time{}, false
+	}
+	v := u.Query().Get(key)
+	if v == "" {
+		return time.Time{}, false
+	}
+	sec, err := strconv.ParseInt(v, base, 64)
+	if err != nil || sec <= 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(sec, 0), true
+}
+
+// GetStreamUrlExpiry returns the expiry of the url from the platform, false when the platform doesn't expose it.
+func (w *WrappedLive) GetStreamUrlExpiry(u *url.URL) (time.Time, bool) {
+	if e, ok := w.Live.(StreamUrlExpirer); ok {
+		return e.GetStreamUrlExpiry(u)
+	}
+	return time.Time{}, false
+}
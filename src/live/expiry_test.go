@@ -0,0 +1,27 @@
+package live
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseUnixExpiry(t *testing.T) {
+	u, _ := url.Parse("https://example.com/live.flv?expires=1622548800&wsTime=60b5f1c0&bad=x")
+	expiry, ok := ParseUnixExpiry(u, "expires", 10)
+	assert.True(t, ok)
+	assert.Equal(t, time.Unix(1622548800, 0), expiry)
+	expiry, ok = ParseUnixExpiry(u, "wsTime", 16)
+	assert.True(t, ok)
+	assert.Equal(t, time.Unix(0x60b5f1c0, 0), expiry)
+	_, ok = ParseUnixExpiry(u, "bad", 10)
+	assert.False(t, ok)
+	_, ok = ParseUnixExpiry(u, "missing", 10)
+	assert.False(t, ok)
+
+	// the platforms without expiry
+	_, ok = newWrappedLive(&fakeLive{}, nil, MustNewOptions()).(*WrappedLive).GetStreamUrlExpiry(u)
+	assert.False(t, ok)
+}
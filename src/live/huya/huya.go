@@ -29,6 +29,7 @@ const (
 
 func init() {
 	live.Register(domain, new(builder))
+	live.RegisterSelfTestUrl(domain, "https://www.huya.com/991111")
 }
 
 type builder struct{}
@@ -182,6 +183,11 @@ func (l *Live) GetPlatformCNName() string {
 	return cnName
 }
 
+// GetStreamUrlExpiry parses the wsTime signed into the url, in hex unix seconds.
+func (l *Live) GetStreamUrlExpiry(u *url.URL) (time.Time, bool) {
+	return live.ParseUnixExpiry(u, "wsTime", 16)
+}
+
 func (l *Live) GetHeadersForDownloader() map[string]string {
 	return map[string]string{
 		"User-Agent":      userAgent,
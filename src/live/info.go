@@ -2,6 +2,7 @@ package live
 
 import (
 	"encoding/json"
+	"time"
 )
 
 type Info struct {
@@ -9,9 +10,21 @@ type Info struct {
 	HostName, RoomName   string
 	Status               bool // means isLiving, maybe better to rename it
 	Listening, Recording bool
+	Reconnecting         bool // the live is ended, and the recorder is waiting in the end grace period
 	Initializing         bool
 	CustomLiveId         string
 	AudioOnly            bool
+	NickName             string // custom name set by user, takes precedence over HostName
+	ViewerCount          int64
+	HasViewerCount       bool // false when the platform doesn't report viewers
+
+	RecordingFile          string // the file being written by the recorder
+	RecordingFileSizeBytes int64
+	RecordingFileMD5       string // only filled when explicitly requested, hashing is not free
+	// time left before the recording is stopped by max_recording_duration, nil means no limit
+	RecordingRemaining *time.Duration
+	UnsupportedReason  string // why the stream can not be recorded, e.g. encrypted
+	Tags               []string
 }
 
 func (i *Info) MarshalJSON() ([]byte, error) {
@@ -20,25 +33,50 @@ func (i *Info) MarshalJSON() ([]byte, error) {
 		LiveUrl           string `json:"live_url"`
 		PlatformCNName    string `json:"platform_cn_name"`
 		HostName          string `json:"host_name"`
+		NickName          string `json:"nick_name,omitempty"`
 		RoomName          string `json:"room_name"`
 		Status            bool   `json:"status"`
 		Listening         bool   `json:"listening"`
 		Recording         bool   `json:"recording"`
+		Reconnecting      bool   `json:"reconnecting"`
 		Initializing      bool   `json:"initializing"`
 		LastStartTime     string `json:"last_start_time,omitempty"`
 		LastStartTimeUnix int64  `json:"last_start_time_unix,omitempty"`
 		AudioOnly         bool   `json:"audio_only"`
+		ViewerCount       *int64 `json:"viewer_count,omitempty"`
+
+		RecordingFile          string   `json:"recording_file,omitempty"`
+		RecordingFileSizeBytes int64    `json:"recording_file_size_bytes,omitempty"`
+		RecordingFileMD5       string   `json:"recording_file_md5,omitempty"`
+		RecordingRemaining     *int64   `json:"recording_remaining_seconds,omitempty"`
+		UnsupportedReason      string   `json:"unsupported_reason,omitempty"`
+		Tags                   []string `json:"tags,omitempty"`
 	}{
 		Id:             i.Live.GetLiveId(),
 		LiveUrl:        i.Live.GetRawUrl(),
 		PlatformCNName: i.Live.GetPlatformCNName(),
-		HostName:       i.HostName,
+		HostName:       i.DisplayName(),
+		NickName:       i.NickName,
 		RoomName:       i.RoomName,
 		Status:         i.Status,
 		Listening:      i.Listening,
 		Recording:      i.Recording,
+		Reconnecting:   i.Reconnecting,
 		Initializing:   i.Initializing,
 		AudioOnly:      i.AudioOnly,
+
+		RecordingFile:          i.RecordingFile,
+		RecordingFileSizeBytes: i.RecordingFileSizeBytes,
+		RecordingFileMD5:       i.RecordingFileMD5,
+		UnsupportedReason:      i.UnsupportedReason,
+		Tags:                   i.Tags,
+	}
+	if i.RecordingRemaining != nil {
+		seconds := int64(i.RecordingRemaining.Seconds())
+		t.RecordingRemaining = &seconds
+	}
+	if i.HasViewerCount {
+		t.ViewerCount = &i.ViewerCount
 	}
 	if !i.Live.GetLastStartTime().IsZero() {
 		t.LastStartTime = i.Live.GetLastStartTime().Format("2006-01-02 15:04:05")
@@ -46,3 +84,11 @@ func (i *Info) MarshalJSON() ([]byte, error) {
 	}
 	return json.Marshal(t)
 }
+
+// DisplayName returns the nick name if set, otherwise the host name.
+func (i *Info) DisplayName() string {
+	if i.NickName != "" {
+		return i.NickName
+	}
+	return i.HostName
+}
@@ -0,0 +1,50 @@
+package live
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeLive struct {
+	Live
+	info    *Info
+	headers map[string]string
+}
+
+func (f *fakeLive) GetInfo() (*Info, error) {
+	info := *f.info
+	info.Live = f
+	return &info, nil
+}
+
+func (f *fakeLive) GetLiveId() ID                              { return "id" }
+func (f *fakeLive) GetRawUrl() string                          { return "https://example.com/1" }
+func (f *fakeLive) GetPlatformCNName() string                  { return "platform" }
+func (f *fakeLive) GetLastStartTime() time.Time                { return time.Time{} }
+func (f *fakeLive) GetHeadersForDownloader() map[string]string { return f.headers }
+
+func TestWrappedLiveNickName(t *testing.T) {
+	l := &fakeLive{info: &Info{HostName: "host", RoomName: "room"}}
+	w := newWrappedLive(l, nil, MustNewOptions(WithNickName("nick"))).(*WrappedLive)
+
+	info, err := w.GetInfo()
+	assert.NoError(t, err)
+	assert.Equal(t, "nick", info.NickName)
+	assert.Equal(t, "nick", info.DisplayName())
+	b, err := json.Marshal(info)
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), `"host_name":"nick"`)
+	assert.Contains(t, string(b), `"nick_name":"nick"`)
+
+	w.SetNickName("")
+	info, err = w.GetInfo()
+	assert.NoError(t, err)
+	assert.Equal(t, "host", info.DisplayName())
+	b, err = json.Marshal(info)
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), `"host_name":"host"`)
+	assert.NotContains(t, string(b), `"nick_name"`)
+}
@@ -2,11 +2,13 @@
 package live
 
 import (
+	"context"
 	"errors"
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/bluele/gcache"
@@ -41,9 +43,14 @@ type InitializingFinishedParam struct {
 }
 
 type Options struct {
-	Cookies   *cookiejar.Jar
-	Quality   int
-	AudioOnly bool
+	Cookies           *cookiejar.Jar
+	Quality           int
+	AudioOnly         bool
+	InitRetryCount    int
+	InitRetryInterval time.Duration
+	NickName          string
+	Headers           map[string]string
+	MaxInflight       int // of the info requests of the platform, 0 means no limit
 }
 
 func NewOptions(opts ...Option) (*Options, error) {
@@ -51,7 +58,12 @@ func NewOptions(opts ...Option) (*Options, error) {
 	if err != nil {
 		return nil, err
 	}
-	options := &Options{Cookies: cookieJar, Quality: 0}
+	options := &Options{
+		Cookies:           cookieJar,
+		Quality:           0,
+		InitRetryCount:    defaultInitRetryCount,
+		InitRetryInterval: defaultInitRetryInterval,
+	}
 	for _, opt := range opts {
 		opt(options)
 	}
@@ -97,6 +109,37 @@ func WithAudioOnly(audioOnly bool) Option {
 	}
 }
 
+// WithInitRetry sets how many times New tries to get the info of the live,
+// and the interval between the attempts.
+func WithInitRetry(count int, interval time.Duration) Option {
+	return func(opts *Options) {
+		opts.InitRetryCount = count
+		opts.InitRetryInterval = interval
+	}
+}
+
+// WithMaxInflight caps the concurrent info requests of the platform, shared by its lives.
+func WithMaxInflight(limit int) Option {
+	return func(opts *Options) {
+		opts.MaxInflight = limit
+	}
+}
+
+// WithNickName sets a custom name of the live which takes precedence over the host name.
+func WithNickName(nickName string) Option {
+	return func(opts *Options) {
+		opts.NickName = nickName
+	}
+}
+
+// WithHeaders sets the headers which override the ones from GetHeadersForDownloader,
+// a header with an empty value is removed.
+func WithHeaders(headers map[string]string) Option {
+	return func(opts *Options) {
+		opts.Headers = headers
+	}
+}
+
 type ID string
 
 type StreamUrlInfo struct {
@@ -121,41 +164,142 @@ type Live interface {
 
 type WrappedLive struct {
 	Live
-	cache gcache.Cache
+	cache    gcache.Cache
+	nickName atomic.Value // string
+	headers  map[string]string
+	breaker  *circuitBreaker // of the platform, nil means no breaker
+	inflight chan struct{}   // semaphore of the info requests of the platform, nil means no limit
 }
 
-func newWrappedLive(live Live, cache gcache.Cache) Live {
-	return &WrappedLive{
-		Live:  live,
-		cache: cache,
+func newWrappedLive(live Live, cache gcache.Cache, options *Options) Live {
+	w := &WrappedLive{
+		Live:    live,
+		cache:   cache,
+		headers: options.Headers,
+	}
+	w.nickName.Store(options.NickName)
+	return w
+}
+
+func (w *WrappedLive) GetHeadersForDownloader() map[string]string {
+	headers := w.Live.GetHeadersForDownloader()
+	if len(w.headers) == 0 {
+		return headers
+	}
+	merged := make(map[string]string, len(headers)+len(w.headers))
+	for k, v := range headers {
+		merged[http.CanonicalHeaderKey(k)] = v
+	}
+	for k, v := range w.headers {
+		k = http.CanonicalHeaderKey(k)
+		if v == "" {
+			delete(merged, k)
+			continue
+		}
+		merged[k] = v
+	}
+	return merged
+}
+
+// SetNickName changes the nick name filled into the info, an empty name clears it.
+func (w *WrappedLive) SetNickName(nickName string) {
+	w.nickName.Store(nickName)
+	if w.cache == nil {
+		return
+	}
+	if info, err := w.cache.Get(w); err == nil {
+		info.(*Info).NickName = nickName
 	}
 }
 
 func (w *WrappedLive) GetInfo() (*Info, error) {
-	i, err := w.Live.GetInfo()
+	i, err := w.getInfo()
 	if err != nil {
 		if info, err2 := w.cache.Get(w); err2 == nil {
 			info.(*Info).RoomName = err.Error()
 		}
 		return nil, err
 	}
+	i.NickName, _ = w.nickName.Load().(string)
 	if w.cache != nil {
 		w.cache.Set(w, i)
 	}
 	return i, nil
 }
 
-func New(url *url.URL, cache gcache.Cache, opts ...Option) (live Live, err error) {
+// GetCachedInfo returns the info of the live in the cache, it's fetched again
+// when the cache doesn't have it, e.g. after the cache is cleared.
+func GetCachedInfo(cache gcache.Cache, l Live) (*Info, error) {
+	if obj, err := cache.Get(l); err == nil {
+		return obj.(*Info), nil
+	}
+	info, err := l.GetInfo()
+	if err != nil {
+		return nil, err
+	}
+	cache.Set(l, info)
+	return info, nil
+}
+
+// getInfo gets the info through the circuit breaker and the inflight limit of the platform.
+func (w *WrappedLive) getInfo() (*Info, error) {
+	if w.breaker != nil && w.breaker.rateLimited() {
+		return nil, ErrPlatformLimited
+	}
+	if w.breaker != nil && !w.breaker.allow() {
+		return nil, ErrPlatformDegraded
+	}
+	if w.inflight != nil {
+		w.inflight <- struct{}{}
+		defer func() { <-w.inflight }()
+	}
+	i, err := w.Live.GetInfo()
+	if w.breaker != nil {
+		w.breaker.record(err)
+	}
+	return i, err
+}
+
+// GetStreamUrls resolves the stream, it's paused as well while the platform is rate limited,
+// and a rate limit response feeds the backoff of the platform.
+func (w *WrappedLive) GetStreamUrls() ([]*url.URL, error) {
+	if w.breaker != nil && w.breaker.rateLimited() {
+		return nil, ErrPlatformLimited
+	}
+	urls, err := w.Live.GetStreamUrls()
+	if errors.As(err, new(*RateLimitedError)) && w.breaker != nil {
+		w.breaker.record(err)
+	}
+	return urls, err
+}
+
+// New creates a live by url, it tries to get the info of the live according to the
+// init retry options, and falls back to an initializing live when all attempts failed.
+// It returns the error of ctx when ctx is done before that.
+func New(ctx context.Context, url *url.URL, cache gcache.Cache, opts ...Option) (live Live, err error) {
 	builder, ok := getBuilder(url.Host)
 	if !ok {
-		return nil, errors.New("not support this url")
+		return nil, ErrNotSupportUrl
+	}
+	options, err := NewOptions(opts...)
+	if err != nil {
+		return nil, err
 	}
 	live, err = builder.Build(url, opts...)
 	if err != nil {
 		return
 	}
-	live = newWrappedLive(live, cache)
-	for i := 0; i < 3; i++ {
+	live = newWrappedLive(live, cache, options)
+	live.(*WrappedLive).breaker = getBreaker(url.Host)
+	live.(*WrappedLive).inflight = getInflightLimit(url.Host, options.MaxInflight)
+	for i := 0; i < options.InitRetryCount || i == 0; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(options.InitRetryInterval):
+			}
+		}
 		var info *Info
 		if info, err = live.GetInfo(); err == nil {
 			if info.CustomLiveId != "" {
@@ -163,12 +307,15 @@ func New(url *url.URL, cache gcache.Cache, opts ...Option) (live Live, err error
 			}
 			return
 		}
-		time.Sleep(1 * time.Second)
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
 	}
 
 	// when room initializaion is failed
 	live, err = InitializingLiveBuilderInstance.Build(live, url, opts...)
-	live = newWrappedLive(live, cache)
+	// no breaker, the initializing live always succeeds, the original one inside is guarded
+	live = newWrappedLive(live, cache, options)
 	live.GetInfo() // dummy call to initialize cache inside wrappedLive
 	return
 }
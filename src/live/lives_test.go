@@ -0,0 +1,24 @@
+package live
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWrappedLiveHeaders(t *testing.T) {
+	l := &fakeLive{headers: map[string]string{"user-agent": "platform", "Origin": "https://example.com"}}
+
+	w := newWrappedLive(l, nil, MustNewOptions())
+	assert.Equal(t, l.headers, w.GetHeadersForDownloader())
+
+	w = newWrappedLive(l, nil, MustNewOptions(WithHeaders(map[string]string{
+		"User-Agent": "custom",
+		"referer":    "https://example.com/1",
+		"origin":     "",
+	})))
+	assert.Equal(t, map[string]string{
+		"User-Agent": "custom",
+		"Referer":    "https://example.com/1",
+	}, w.GetHeadersForDownloader())
+}
@@ -0,0 +1,44 @@
+package live
+
+// LoginStatus is the state of a login session.
+type LoginStatus string
+
+const (
+	LoginPending   LoginStatus = "pending"   // waiting for the qr code to be scanned
+	LoginScanned   LoginStatus = "scanned"   // scanned, waiting for the confirmation on the phone
+	LoginSucceeded LoginStatus = "succeeded" // the cookies are available
+	LoginExpired   LoginStatus = "expired"   // a new session should be started
+)
+
+// LoginSession is a started qr code login, QRCode is the content to be rendered as a qr code.
+type LoginSession struct {
+	Key    string `json:"key"`
+	QRCode string `json:"qr_code"`
+}
+
+// LoginResult is the result of polling a login session, Cookies is only set when succeeded,
+// in the same "k1=v1; k2=v2" format as the cookies in the config.
+type LoginResult struct {
+	Status  LoginStatus
+	Cookies string
+}
+
+// LoginAssist drives the login flow of a platform to acquire the cookies.
+type LoginAssist interface {
+	StartLogin() (*LoginSession, error)
+	PollLogin(key string) (*LoginResult, error)
+}
+
+var loginAssists = make(map[string]LoginAssist)
+
+func RegisterLoginAssist(domain string, a LoginAssist) {
+	loginAssists[domain] = a
+}
+
+func GetLoginAssist(domain string) (LoginAssist, error) {
+	a, ok := loginAssists[domain]
+	if !ok {
+		return nil, ErrLoginNotSupport
+	}
+	return a, nil
+}
@@ -0,0 +1,106 @@
+package live
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hr3lxphr6j/requests"
+)
+
+func init() {
+	// the requests of all the platforms go through it, so that they all honor the rate limits
+	requests.DefaultSession = requests.NewSession(&http.Client{Transport: WithRateLimit(nil)})
+}
+
+// for test
+var (
+	// the backoff when the platform signals the rate limit without telling how long
+	defaultRateLimitBackoff = time.Minute
+	// bounds a bogus Retry-After, the rooms would never be polled again otherwise
+	maxRateLimitBackoff = time.Hour
+)
+
+// RateLimitedError is returned by the platforms when the requests are rate limited, e.g. HTTP 429,
+// the requests to the platform are paused for RetryAfter, the default backoff is used when it's 0.
+type RateLimitedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitedError) Error() string {
+	if e.RetryAfter <= 0 {
+		return "rate limited by the platform"
+	}
+	return fmt.Sprintf("rate limited by the platform, retry after %s", e.RetryAfter)
+}
+
+// backoff returns how long the requests to the platform are paused.
+func (e *RateLimitedError) backoff() time.Duration {
+	switch {
+	case e.RetryAfter <= 0:
+		return defaultRateLimitBackoff
+	case e.RetryAfter > maxRateLimitBackoff:
+		return maxRateLimitBackoff
+	default:
+		return e.RetryAfter
+	}
+}
+
+// ParseRetryAfter parses the value of the Retry-After header, either the seconds or a http date.
+func ParseRetryAfter(value string) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if sec, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if sec < 0 {
+			return 0, false
+		}
+		return time.Duration(sec) * time.Second, true
+	}
+	t, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	if d := t.Sub(now()); d > 0 {
+		return d, true
+	}
+	return 0, true
+}
+
+// CheckRateLimited returns a RateLimitedError when the response is HTTP 429,
+// with the duration of the Retry-After header if there is one.
+func CheckRateLimited(resp *http.Response) error {
+	if resp == nil || resp.StatusCode != http.StatusTooManyRequests {
+		return nil
+	}
+	d, _ := ParseRetryAfter(resp.Header.Get("Retry-After"))
+	return &RateLimitedError{RetryAfter: d}
+}
+
+type rateLimitTransport struct {
+	next http.RoundTripper
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := CheckRateLimited(resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp, nil
+}
+
+// WithRateLimit wraps the transport, http.DefaultTransport when nil, to fail the rate limited
+// responses with a RateLimitedError, which is found by errors.As in the error of http.Client.
+func WithRateLimit(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &rateLimitTransport{next: next}
+}
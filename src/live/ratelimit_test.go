@@ -0,0 +1,121 @@
+package live
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/hr3lxphr6j/requests"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	current := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	backup := now
+	now = func() time.Time { return current }
+	defer func() { now = backup }()
+
+	d, ok := ParseRetryAfter("120")
+	assert.True(t, ok)
+	assert.Equal(t, 2*time.Minute, d)
+	d, ok = ParseRetryAfter("Tue, 01 Jun 2021 12:00:30 GMT")
+	assert.True(t, ok)
+	assert.Equal(t, 30*time.Second, d)
+	// in the past
+	d, ok = ParseRetryAfter("Tue, 01 Jun 2021 11:00:00 GMT")
+	assert.True(t, ok)
+	assert.Equal(t, time.Duration(0), d)
+	for _, v := range []string{"", "-1", "soon"} {
+		_, ok = ParseRetryAfter(v)
+		assert.False(t, ok, v)
+	}
+}
+
+func TestCheckRateLimited(t *testing.T) {
+	assert.NoError(t, CheckRateLimited(&http.Response{StatusCode: http.StatusOK}))
+	resp := &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{}}
+	resp.Header.Set("Retry-After", "30")
+	assert.Equal(t, &RateLimitedError{RetryAfter: 30 * time.Second}, CheckRateLimited(resp))
+
+	assert.Equal(t, defaultRateLimitBackoff, (&RateLimitedError{}).backoff())
+	assert.Equal(t, maxRateLimitBackoff, (&RateLimitedError{RetryAfter: 24 * time.Hour}).backoff())
+}
+
+func TestRateLimitTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/limited" {
+			w.Header().Set("Retry-After", "30")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		fmt.Fprint(w, "ok")
+	}))
+	defer server.Close()
+
+	// the requests of the platforms go through the default session
+	resp, err := requests.Get(server.URL + "/ok")
+	assert.NoError(t, err)
+	body, _ := resp.Text()
+	assert.Equal(t, "ok", body)
+	_, err = requests.Get(server.URL + "/limited")
+	var rateLimited *RateLimitedError
+	assert.True(t, errors.As(err, &rateLimited))
+	assert.Equal(t, 30*time.Second, rateLimited.RetryAfter)
+
+	// and noticed by the breaker when wrapped by http.Client
+	b := &circuitBreaker{platform: "example.com"}
+	b.record(err)
+	assert.True(t, b.status().RateLimited)
+	assert.Equal(t, 0, b.status().ConsecutiveFailures)
+}
+
+type rateLimitedLive struct {
+	fakeLive
+	calls int
+}
+
+func (r *rateLimitedLive) GetInfo() (*Info, error) {
+	r.calls++
+	return nil, &RateLimitedError{RetryAfter: time.Minute}
+}
+
+func (r *rateLimitedLive) GetStreamUrls() ([]*url.URL, error) {
+	r.calls++
+	return nil, nil
+}
+
+func TestWrappedLiveRateLimited(t *testing.T) {
+	current := time.Now()
+	backup := now
+	now = func() time.Time { return current }
+	defer func() { now = backup }()
+
+	l := new(rateLimitedLive)
+	w := newWrappedLive(l, nil, MustNewOptions()).(*WrappedLive)
+	w.breaker = &circuitBreaker{platform: "example.com"}
+	_, err := w.getInfo()
+	assert.IsType(t, &RateLimitedError{}, err)
+	s := w.breaker.status()
+	assert.True(t, s.RateLimited)
+	assert.Equal(t, current.Add(time.Minute), s.RateLimitedUntil)
+	// not a failure of the platform
+	assert.False(t, s.Degraded)
+	assert.Equal(t, 0, s.ConsecutiveFailures)
+
+	// paused during the backoff
+	_, err = w.getInfo()
+	assert.Equal(t, ErrPlatformLimited, err)
+	_, err = w.GetStreamUrls()
+	assert.Equal(t, ErrPlatformLimited, err)
+	assert.Equal(t, 1, l.calls)
+
+	current = current.Add(time.Minute)
+	assert.False(t, w.breaker.status().RateLimited)
+	_, err = w.GetStreamUrls()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, l.calls)
+}
@@ -0,0 +1,102 @@
+package live
+
+import (
+	"errors"
+	"net/url"
+	"time"
+)
+
+// steps of SelfTest
+const (
+	SelfTestStepParseUrl      = "parse_url"
+	SelfTestStepGetInfo       = "get_info"
+	SelfTestStepGetStreamUrls = "get_stream_urls"
+)
+
+var selfTestUrls = make(map[string]string)
+
+// RegisterSelfTestUrl sets the canonical room used by SelfTest of the platform,
+// the room should be living most of the time.
+func RegisterSelfTestUrl(domain, url string) {
+	selfTestUrls[domain] = url
+}
+
+// SelfTestStep is the result of a step of SelfTest.
+type SelfTestStep struct {
+	Name       string `json:"name"`
+	Passed     bool   `json:"passed"`
+	Skipped    bool   `json:"skipped,omitempty"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// SelfTestResult is the result of SelfTest, the steps after a failed one are not run.
+type SelfTestResult struct {
+	Platform string         `json:"platform"`
+	Url      string         `json:"url"`
+	Passed   bool           `json:"passed"`
+	Steps    []SelfTestStep `json:"steps"`
+}
+
+// SelfTest checks whether the implementation of the platform still works with its canonical test room.
+func SelfTest(platform string) (*SelfTestResult, error) {
+	return SelfTestWithUrl(platform, "")
+}
+
+// SelfTestWithUrl checks whether the implementation of the platform still works, by parsing the url,
+// getting the info and the stream urls of the room, the canonical test room is used when url is empty.
+// The stream urls are only checked when the room is living.
+func SelfTestWithUrl(platform, rawUrl string) (*SelfTestResult, error) {
+	builder, ok := getBuilder(platform)
+	if !ok {
+		return nil, ErrPlatformNotExist
+	}
+	if rawUrl == "" {
+		if rawUrl, ok = selfTestUrls[platform]; !ok {
+			return nil, ErrNoSelfTestUrl
+		}
+	}
+	result := &SelfTestResult{Platform: platform, Url: rawUrl}
+	run := func(name string, fn func() error) bool {
+		start := time.Now()
+		err := fn()
+		step := SelfTestStep{Name: name, Passed: err == nil, DurationMs: time.Since(start).Milliseconds()}
+		if err != nil {
+			step.Error = err.Error()
+		}
+		result.Steps = append(result.Steps, step)
+		return err == nil
+	}
+
+	var l Live
+	var info *Info
+	result.Passed = run(SelfTestStepParseUrl, func() error {
+		u, err := url.Parse(rawUrl)
+		if err != nil {
+			return err
+		}
+		if u.Host != platform {
+			return ErrRoomUrlIncorrect
+		}
+		l, err = builder.Build(u)
+		return err
+	}) && run(SelfTestStepGetInfo, func() (err error) {
+		info, err = l.GetInfo()
+		return err
+	})
+	if !result.Passed {
+		return result, nil
+	}
+	if !info.Status {
+		result.Steps = append(result.Steps, SelfTestStep{Name: SelfTestStepGetStreamUrls, Skipped: true, Error: "the room is not living"})
+		return result, nil
+	}
+	result.Passed = run(SelfTestStepGetStreamUrls, func() error {
+		urls, err := l.GetStreamUrls()
+		if err == nil && len(urls) == 0 {
+			err = errors.New("no stream url")
+		}
+		return err
+	})
+	return result, nil
+}
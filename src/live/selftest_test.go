@@ -0,0 +1,89 @@
+package live
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type selfTestLive struct {
+	fakeLive
+	infoErr error
+	urls    []*url.URL
+}
+
+func (l *selfTestLive) GetInfo() (*Info, error) {
+	if l.infoErr != nil {
+		return nil, l.infoErr
+	}
+	return l.fakeLive.GetInfo()
+}
+
+func (l *selfTestLive) GetStreamUrls() ([]*url.URL, error) {
+	return l.urls, nil
+}
+
+type selfTestBuilder struct {
+	live *selfTestLive
+}
+
+func (b *selfTestBuilder) Build(*url.URL, ...Option) (Live, error) {
+	return b.live, nil
+}
+
+func TestSelfTest(t *testing.T) {
+	l := &selfTestLive{fakeLive: fakeLive{info: &Info{Status: true}}}
+	Register("selftest.example.com", &selfTestBuilder{live: l})
+	defer delete(m, "selftest.example.com")
+
+	_, err := SelfTest("not.exist.com")
+	assert.Equal(t, ErrPlatformNotExist, err)
+	_, err = SelfTest("selftest.example.com")
+	assert.Equal(t, ErrNoSelfTestUrl, err)
+
+	RegisterSelfTestUrl("selftest.example.com", "https://selftest.example.com/1")
+	defer delete(selfTestUrls, "selftest.example.com")
+	stepNames := func(r *SelfTestResult) []string {
+		names := make([]string, 0, len(r.Steps))
+		for _, s := range r.Steps {
+			names = append(names, s.Name)
+		}
+		return names
+	}
+
+	// no stream url
+	r, err := SelfTest("selftest.example.com")
+	assert.NoError(t, err)
+	assert.False(t, r.Passed)
+	assert.Equal(t, []string{SelfTestStepParseUrl, SelfTestStepGetInfo, SelfTestStepGetStreamUrls}, stepNames(r))
+	assert.Equal(t, "no stream url", r.Steps[2].Error)
+
+	u, _ := url.Parse("https://selftest.example.com/1.flv")
+	l.urls = []*url.URL{u}
+	r, err = SelfTest("selftest.example.com")
+	assert.NoError(t, err)
+	assert.True(t, r.Passed)
+	assert.Equal(t, "https://selftest.example.com/1", r.Url)
+
+	// the stream is not checked when not living
+	l.info.Status = false
+	r, err = SelfTestWithUrl("selftest.example.com", "https://selftest.example.com/2")
+	assert.NoError(t, err)
+	assert.True(t, r.Passed)
+	assert.True(t, r.Steps[2].Skipped)
+	assert.Equal(t, "https://selftest.example.com/2", r.Url)
+
+	l.infoErr = errors.New("api changed")
+	r, err = SelfTest("selftest.example.com")
+	assert.NoError(t, err)
+	assert.False(t, r.Passed)
+	assert.Equal(t, []string{SelfTestStepParseUrl, SelfTestStepGetInfo}, stepNames(r))
+	assert.Equal(t, "api changed", r.Steps[1].Error)
+
+	r, err = SelfTestWithUrl("selftest.example.com", "https://other.example.com/1")
+	assert.NoError(t, err)
+	assert.False(t, r.Passed)
+	assert.Equal(t, ErrRoomUrlIncorrect.Error(), r.Steps[0].Error)
+}
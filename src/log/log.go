@@ -2,52 +2,103 @@ package log
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"io"
 	"log"
 	"os"
 	"path/filepath"
+	"sync"
 	"time"
 
 	"github.com/sirupsen/logrus"
 
+	"github.com/hr3lxphr6j/bililive-go/src/configs"
 	"github.com/hr3lxphr6j/bililive-go/src/instance"
 	"github.com/hr3lxphr6j/bililive-go/src/interfaces"
 )
 
+var ErrNotReloadable = errors.New("the output of the logger is not reloadable")
+
+// reloadableWriter lets the log files be swapped while the logger is in use,
+// the writes and the swap are serialized so that a closed file is never written.
+type reloadableWriter struct {
+	sync.Mutex
+	out   io.Writer
+	files []*os.File
+	runID string // the file name of save_every_log, kept across reloads
+}
+
+func (w *reloadableWriter) Write(p []byte) (int, error) {
+	w.Lock()
+	defer w.Unlock()
+	return w.out.Write(p)
+}
+
+// open opens the log files of the config, the last log is truncated only when truncateLastLog.
+func (w *reloadableWriter) open(config configs.Log, truncateLastLog bool) (io.Writer, []*os.File, error) {
+	writers := []io.Writer{os.Stderr}
+	files := make([]*os.File, 0, 2)
+	closeAll := func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}
+	outputFolder := config.OutPutFolder
+	if _, err := os.Stat(outputFolder); os.IsNotExist(err) {
+		return nil, nil, fmt.Errorf("err: \"%s\", Failed to determine log output folder: %s", err, outputFolder)
+	}
+	if config.SaveEveryLog {
+		logLocation := filepath.Join(outputFolder, w.runID+".log")
+		logFile, err := os.OpenFile(logLocation, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			closeAll()
+			return nil, nil, fmt.Errorf("Failed to open log file %s for output: %s", logLocation, err)
+		}
+		writers = append(writers, logFile)
+		files = append(files, logFile)
+	}
+	if config.SaveLastLog {
+		logLocation := filepath.Join(outputFolder, "bililive-go.log")
+		flag := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+		if truncateLastLog {
+			flag = os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+		}
+		logFile, err := os.OpenFile(logLocation, flag, 0644)
+		if err != nil {
+			closeAll()
+			return nil, nil, fmt.Errorf("Failed to open default log file %s for output: %s", logLocation, err)
+		}
+		writers = append(writers, logFile)
+		files = append(files, logFile)
+	}
+	return io.MultiWriter(writers...), files, nil
+}
+
+func (w *reloadableWriter) swap(out io.Writer, files []*os.File) {
+	w.Lock()
+	oldFiles := w.files
+	w.out, w.files = out, files
+	w.Unlock()
+	for _, f := range oldFiles {
+		f.Close()
+	}
+}
+
 func New(ctx context.Context) *interfaces.Logger {
 	inst := instance.GetInstance(ctx)
 	logLevel := logrus.InfoLevel
 	if inst.Config.Debug {
 		logLevel = logrus.DebugLevel
 	}
-	config := inst.Config
-	writers := []io.Writer{os.Stderr}
-	outputFolder := config.Log.OutPutFolder
-	if _, err := os.Stat(outputFolder); os.IsNotExist(err) {
-		log.Fatalf("err: \"%s\", Failed to determine log output folder: %s", err, outputFolder)
-	} else {
-		if config.Log.SaveEveryLog {
-			runID := time.Now().Format("run-2006-01-02-15-04-05")
-			logLocation := filepath.Join(outputFolder, runID+".log")
-			logFile, err := os.OpenFile(logLocation, os.O_CREATE|os.O_WRONLY, 0644)
-			if err != nil {
-				log.Fatalf("Failed to open log file %s for output: %s", logLocation, err)
-			} else {
-				writers = append(writers, logFile)
-			}
-		}
-		if config.Log.SaveLastLog {
-			logLocation := filepath.Join(outputFolder, "bililive-go.log")
-			logFile, err := os.OpenFile(logLocation, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
-			if err != nil {
-				log.Fatalf("Failed to open default log file %s for output: %s", logLocation, err)
-			} else {
-				writers = append(writers, logFile)
-			}
-		}
+	w := &reloadableWriter{runID: time.Now().Format("run-2006-01-02-15-04-05")}
+	out, files, err := w.open(inst.Config.Log, true)
+	if err != nil {
+		log.Fatal(err)
 	}
+	w.swap(out, files)
 	logger := &interfaces.Logger{Logger: &logrus.Logger{
-		Out: io.MultiWriter(writers...),
+		Out: w,
 		Formatter: &logrus.TextFormatter{
 			DisableColors:   true,
 			FullTimestamp:   true,
@@ -61,3 +112,18 @@ func New(ctx context.Context) *interfaces.Logger {
 
 	return logger
 }
+
+// Reload applies the log settings to the running logger without restart,
+// the old log files are kept when the new ones can not be opened.
+func Reload(ctx context.Context, config configs.Log) error {
+	w, ok := instance.GetInstance(ctx).Logger.Out.(*reloadableWriter)
+	if !ok {
+		return ErrNotReloadable
+	}
+	out, files, err := w.open(config, false)
+	if err != nil {
+		return err
+	}
+	w.swap(out, files)
+	return nil
+}
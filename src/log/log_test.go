@@ -0,0 +1,50 @@
+package log
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hr3lxphr6j/bililive-go/src/configs"
+	"github.com/hr3lxphr6j/bililive-go/src/instance"
+)
+
+func TestReload(t *testing.T) {
+	dir1, err := ioutil.TempDir("", "log")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir1)
+	dir2, err := ioutil.TempDir("", "log")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir2)
+
+	config := configs.NewConfig()
+	config.Log = configs.Log{OutPutFolder: dir1, SaveLastLog: true}
+	ctx := context.WithValue(context.Background(), instance.Key, &instance.Instance{Config: config})
+	logger := New(ctx)
+	logger.Info("before reload")
+
+	assert.NoError(t, Reload(ctx, configs.Log{OutPutFolder: dir2, SaveEveryLog: true}))
+	logger.Info("after reload")
+	b, err := ioutil.ReadFile(filepath.Join(dir1, "bililive-go.log"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), "before reload")
+	assert.NotContains(t, string(b), "after reload")
+	files, err := filepath.Glob(filepath.Join(dir2, "run-*.log"))
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+	b, err = ioutil.ReadFile(files[0])
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), "after reload")
+
+	// the old files are kept if the new folder does not exist
+	assert.Error(t, Reload(ctx, configs.Log{OutPutFolder: filepath.Join(dir2, "not-exist"), SaveLastLog: true}))
+	logger.Info("still here")
+	b, err = ioutil.ReadFile(files[0])
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), "still here")
+	logger.Out.(*reloadableWriter).swap(os.Stderr, nil)
+}
@@ -0,0 +1,13 @@
+package ffmpeg
+
+// the muxer is picked by ffmpeg from the file extension, only the fragmented mp4 needs extra flags.
+const containerFmp4 = "fmp4"
+
+// containerArgs returns the output args of the container.
+func containerArgs(container string) []string {
+	if container == containerFmp4 {
+		// every keyframe starts a fragment, the moov is written at the beginning without samples
+		return []string{"-movflags", "+frag_keyframe+empty_moov+default_base_moof"}
+	}
+	return nil
+}
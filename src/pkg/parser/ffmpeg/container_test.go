@@ -0,0 +1,13 @@
+package ffmpeg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContainerArgs(t *testing.T) {
+	assert.Empty(t, containerArgs(""))
+	assert.Empty(t, containerArgs("mp4"))
+	assert.Equal(t, []string{"-movflags", "+frag_keyframe+empty_moov+default_base_moof"}, containerArgs("fmp4"))
+}
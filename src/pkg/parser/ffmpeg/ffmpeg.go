@@ -10,10 +10,13 @@ import (
 	"os"
 	"os/exec"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/hr3lxphr6j/bililive-go/src/instance"
+	"github.com/hr3lxphr6j/bililive-go/src/interfaces"
 	"github.com/hr3lxphr6j/bililive-go/src/live"
 	"github.com/hr3lxphr6j/bililive-go/src/pkg/parser"
 	"github.com/hr3lxphr6j/bililive-go/src/pkg/utils"
@@ -36,15 +39,28 @@ func (b *builder) Build(cfg map[string]string) (parser.Parser, error) {
 	if debugFlag, ok := cfg["debug"]; ok && debugFlag != "" {
 		debug = true
 	}
+	nice, _ := strconv.Atoi(cfg["nice"])
+	ioClass, _ := strconv.Atoi(cfg["io_class"])
 	return &Parser{
 		debug:       debug,
 		closeOnce:   new(sync.Once),
 		statusReq:   make(chan struct{}, 1),
 		statusResp:  make(chan map[string]string, 1),
 		timeoutInUs: cfg["timeout_in_us"],
+		hwAccel:     cfg["hwaccel"],
+		cpuAffinity: cfg["cpu_affinity"],
+		nice:        nice,
+		ioClass:     ioClass,
+		transcode:   newTranscode(cfg),
+		container:   cfg["container"],
+		inputArgs:   strings.Fields(cfg["extra_input_args"]),
+		outputArgs:  strings.Fields(cfg["extra_output_args"]),
 	}, nil
 }
 
+// for test
+var applyCPUAffinity = utils.ApplyCPUAffinity
+
 type Parser struct {
 	cmd         *exec.Cmd
 	cmdStdIn    io.WriteCloser
@@ -52,6 +68,16 @@ type Parser struct {
 	closeOnce   *sync.Once
 	debug       bool
 	timeoutInUs string
+	hwAccel     string
+	cpuAffinity string
+	nice        int
+	ioClass     int
+	transcode   *transcode
+	container   string
+	inputArgs   []string // extra args before -i
+	outputArgs  []string // extra args before the output file
+	// set when the transcoding can not keep up and ffmpeg is stopped
+	transcodeFellBack uint32
 
 	statusReq  chan struct{}
 	statusResp chan map[string]string
@@ -99,6 +125,7 @@ func (p *Parser) decodeFFmpegStatus(b []byte) (status map[string]string) {
 func (p *Parser) scheduler() {
 	defer close(p.statusResp)
 	statusCh := p.scanFFmpegStatus()
+	monitor := new(speedMonitor)
 	for {
 		select {
 		case <-p.statusReq:
@@ -107,14 +134,23 @@ func (p *Parser) scheduler() {
 				if !ok {
 					return
 				}
-				p.statusResp <- p.decodeFFmpegStatus(b)
+				status := p.decodeFFmpegStatus(b)
+				if p.debug {
+					status["command"] = maskedCommand(p.cmd.Args)
+				}
+				p.statusResp <- status
 			case <-time.After(time.Second * 3):
 				p.statusResp <- nil
 			}
 		default:
-			if _, ok := <-statusCh; !ok {
+			b, ok := <-statusCh
+			if !ok {
 				return
 			}
+			if p.transcode != nil && monitor.feed(p.decodeFFmpegStatus(b)) &&
+				atomic.CompareAndSwapUint32(&p.transcodeFellBack, 0, 1) {
+				p.Stop()
+			}
 		}
 	}
 }
@@ -130,34 +166,43 @@ func (p *Parser) ParseLiveStream(ctx context.Context, url *url.URL, live live.Li
 	if err != nil {
 		return err
 	}
-	headers := live.GetHeadersForDownloader()
-	ffUserAgent, exists := headers["User-Agent"]
-	if !exists {
-		ffUserAgent = userAgent
+	headers := make(map[string]string)
+	for k, v := range live.GetHeadersForDownloader() {
+		headers[k] = v
+	}
+	if _, exists := headers["User-Agent"]; !exists {
+		headers["User-Agent"] = userAgent
 	}
-	referer, exists := headers["Referer"]
-	if !exists {
-		referer = live.GetRawUrl()
+	if _, exists := headers["Referer"]; !exists {
+		headers["Referer"] = live.GetRawUrl()
 	}
+	inst := instance.GetInstance(ctx)
 	args := []string{
 		"-nostats",
 		"-progress", "-",
 		"-y", "-re",
-		"-user_agent", ffUserAgent,
-		"-referer", referer,
-		"-rw_timeout", p.timeoutInUs,
-		"-i", url.String(),
-		"-c", "copy",
-		"-bsf:a", "aac_adtstoasc",
-	}
-	for k, v := range headers {
-		if k == "User-Agent" || k == "Referer" {
-			continue
+	}
+	args = append(args, utils.FFmpegHeaderArgs(headers)...)
+	args = append(args, "-rw_timeout", p.timeoutInUs)
+	hwAccel := ""
+	// only the decoding for the transcoding is accelerated, the stream-copy decodes nothing
+	if p.transcode != nil {
+		hwAccel = p.resolveHWAccel(ffmpegPath)
+	}
+	if hwAccel != "" {
+		if p.hwAccel == HWAccelAuto {
+			inst.Logger.Debugf("use detected hwaccel: %s", hwAccel)
 		}
-		args = append(args, "-headers", k+": "+v)
+		args = append(args, "-hwaccel", hwAccel)
+	}
+	args = append(args, p.inputArgs...)
+	args = append(args, "-i", url.String())
+	if p.transcode != nil {
+		args = append(args, p.transcode.args()...)
+	} else {
+		args = append(args, "-c", "copy", "-bsf:a", "aac_adtstoasc")
 	}
 
-	inst := instance.GetInstance(ctx)
 	MaxFileSize := inst.Config.VideoSplitStrategies.MaxFileSize
 	if MaxFileSize < 0 {
 		inst.Logger.Infof("Invalid MaxFileSize: %d", MaxFileSize)
@@ -165,16 +210,23 @@ func (p *Parser) ParseLiveStream(ctx context.Context, url *url.URL, live live.Li
 		args = append(args, "-fs", strconv.Itoa(MaxFileSize))
 	}
 
+	args = append(args, containerArgs(p.container)...)
+	args = append(args, p.outputArgs...)
 	args = append(args, file)
 	p.cmd = exec.Command(ffmpegPath, args...)
+	inst.Logger.Debugf("ffmpeg command: %s", maskedCommand(p.cmd.Args))
+	p.applyProcessSettings(inst.Logger)
 	if p.cmdStdIn, err = p.cmd.StdinPipe(); err != nil {
 		return err
 	}
 	if p.cmdStdout, err = p.cmd.StdoutPipe(); err != nil {
 		return err
 	}
+	stderr := new(stderrTail)
 	if p.debug {
-		p.cmd.Stderr = os.Stderr
+		p.cmd.Stderr = io.MultiWriter(os.Stderr, stderr)
+	} else {
+		p.cmd.Stderr = stderr
 	}
 	if err = p.cmd.Start(); err != nil {
 		p.cmd.Process.Kill()
@@ -182,12 +234,39 @@ func (p *Parser) ParseLiveStream(ctx context.Context, url *url.URL, live live.Li
 	}
 	go p.scheduler()
 	err = p.cmd.Wait()
+	if p.TranscodeFellBack() {
+		inst.Logger.Warnf("ffmpeg can not transcode to %s in real time, the cpu is likely too slow, fall back to stream-copy", p.transcode.videoBitrate)
+	}
 	if err != nil {
+		if hwAccel != "" && p.hwAccel == HWAccelAuto && hwAccelFailed(stderr.String()) {
+			inst.Logger.Warnf("ffmpeg failed to init hwaccel %s, fall back to software decoding", hwAccel)
+			disableDetectedHWAccel(ffmpegPath)
+		}
+		if msg := stderr.lastLines(3); msg != "" {
+			return fmt.Errorf("%w: %s", err, msg)
+		}
 		return err
 	}
 	return nil
 }
 
+// TranscodeFellBack reports whether the transcoding was stopped because ffmpeg could not keep up,
+// the caller should record with stream-copy afterwards.
+func (p *Parser) TranscodeFellBack() bool {
+	return atomic.LoadUint32(&p.transcodeFellBack) == 1
+}
+
+func (p *Parser) resolveHWAccel(ffmpegPath string) string {
+	switch p.hwAccel {
+	case "", HWAccelNone:
+		return ""
+	case HWAccelAuto:
+		return detectBestHWAccel(ffmpegPath)
+	default:
+		return p.hwAccel
+	}
+}
+
 func (p *Parser) Stop() (err error) {
 	p.closeOnce.Do(func() {
 		if p.cmd.ProcessState == nil {
@@ -204,3 +283,27 @@ func (p *Parser) Stop() (err error) {
 	})
 	return err
 }
+
+// maskedCommand joins the args of ffmpeg for logging, with the values of -headers masked
+// as they may carry the cookies.
+func maskedCommand(args []string) string {
+	masked := make([]string, len(args))
+	copy(masked, args)
+	for i := 1; i < len(masked); i++ {
+		if masked[i-1] == "-headers" {
+			masked[i] = "<masked>"
+		}
+	}
+	return strings.Join(masked, " ")
+}
+
+// applyProcessSettings applies the cpu affinity and the priority to the command,
+// the failures are logged only and the recording goes on without them.
+func (p *Parser) applyProcessSettings(logger *interfaces.Logger) {
+	if err := applyCPUAffinity(p.cmd, p.cpuAffinity); err != nil {
+		logger.WithError(err).Warnf("failed to set cpu affinity %s of ffmpeg, ignored", p.cpuAffinity)
+	}
+	if err := utils.ApplyProcessPriority(p.cmd, p.nice, p.ioClass); err != nil {
+		logger.WithError(err).Warnf("failed to set the priority (nice %d, io class %d) of ffmpeg, ignored", p.nice, p.ioClass)
+	}
+}
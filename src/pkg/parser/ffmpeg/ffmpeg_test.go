@@ -0,0 +1,45 @@
+package ffmpeg
+
+import (
+	"os/exec"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hr3lxphr6j/bililive-go/src/interfaces"
+	"github.com/hr3lxphr6j/bililive-go/src/pkg/utils"
+)
+
+func TestMaskedCommand(t *testing.T) {
+	args := []string{"ffmpeg", "-user_agent", "test", "-headers", "Cookie: SESSDATA=foo\r\n", "-i", "https://example.com/live.flv", "out.flv"}
+	assert.Equal(t, "ffmpeg -user_agent test -headers <masked> -i https://example.com/live.flv out.flv", maskedCommand(args))
+	// the args of the command are kept
+	assert.Equal(t, "Cookie: SESSDATA=foo\r\n", args[4])
+}
+
+func TestApplyProcessSettingsUnsupportedCPUAffinity(t *testing.T) {
+	backup := applyCPUAffinity
+	defer func() { applyCPUAffinity = backup }()
+	// as on the platforms other than linux
+	applyCPUAffinity = func(*exec.Cmd, string) error {
+		return utils.ErrCPUAffinityNotSupported
+	}
+	logger, hook := test.NewNullLogger()
+	p := &Parser{cmd: exec.Command("/usr/bin/ffmpeg", "-i", "input"), cpuAffinity: "0-1"}
+	p.applyProcessSettings(&interfaces.Logger{Logger: logger})
+	assert.Len(t, hook.Entries, 1)
+	assert.Equal(t, logrus.WarnLevel, hook.LastEntry().Level)
+	assert.Equal(t, "failed to set cpu affinity 0-1 of ffmpeg, ignored", hook.LastEntry().Message)
+	assert.Equal(t, utils.ErrCPUAffinityNotSupported, hook.LastEntry().Data[logrus.ErrorKey])
+	// the recording goes on with the command untouched
+	assert.Equal(t, []string{"/usr/bin/ffmpeg", "-i", "input"}, p.cmd.Args)
+
+	// nothing is logged without the cpu affinity
+	hook.Reset()
+	p.cpuAffinity = ""
+	applyCPUAffinity = backup
+	p.applyProcessSettings(&interfaces.Logger{Logger: logger})
+	assert.Empty(t, hook.Entries)
+}
@@ -0,0 +1,86 @@
+package ffmpeg
+
+import (
+	"bufio"
+	"bytes"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+const (
+	HWAccelAuto = "auto"
+	HWAccelNone = "none"
+)
+
+var (
+	// the order of preference when picking an accelerator in auto mode
+	preferredHWAccels = []string{"cuda", "videotoolbox", "vaapi", "qsv", "d3d11va", "dxva2"}
+
+	// ffmpeg path -> detected accelerator, an empty value means no usable accelerator
+	detectedHWAccels sync.Map
+
+	// what ffmpeg writes to stderr when the accelerator can not be initialized,
+	// lower cased, the other exits, e.g. the stream ends, don't disable the detected one
+	hwAccelFailureMessages = []string{
+		"device creation failed",
+		"hwaccel initialisation returned error",
+		"failed setup for format",
+		"no device available for decoder",
+		"cannot load libcuda",
+		"could not dynamically load cuda",
+	}
+)
+
+// parseHWAccels parses the output of `ffmpeg -hwaccels`.
+func parseHWAccels(b []byte) []string {
+	accels := make([]string, 0)
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasSuffix(line, ":") {
+			continue
+		}
+		accels = append(accels, line)
+	}
+	return accels
+}
+
+// detectBestHWAccel returns the best accelerator supported by the ffmpeg binary,
+// or an empty string when nothing usable is found.
+func detectBestHWAccel(ffmpegPath string) string {
+	if v, ok := detectedHWAccels.Load(ffmpegPath); ok {
+		return v.(string)
+	}
+	best := ""
+	if out, err := exec.Command(ffmpegPath, "-hide_banner", "-hwaccels").Output(); err == nil {
+		supported := make(map[string]bool)
+		for _, accel := range parseHWAccels(out) {
+			supported[accel] = true
+		}
+		for _, accel := range preferredHWAccels {
+			if supported[accel] {
+				best = accel
+				break
+			}
+		}
+	}
+	detectedHWAccels.Store(ffmpegPath, best)
+	return best
+}
+
+// disableDetectedHWAccel makes the following auto detections of the ffmpeg binary fall back to software.
+func disableDetectedHWAccel(ffmpegPath string) {
+	detectedHWAccels.Store(ffmpegPath, "")
+}
+
+// hwAccelFailed reports whether the stderr of ffmpeg shows the accelerator failed to initialize.
+func hwAccelFailed(stderr string) bool {
+	stderr = strings.ToLower(stderr)
+	for _, msg := range hwAccelFailureMessages {
+		if strings.Contains(stderr, msg) {
+			return true
+		}
+	}
+	return false
+}
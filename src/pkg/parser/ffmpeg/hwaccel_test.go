@@ -0,0 +1,65 @@
+package ffmpeg
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newMockFFmpeg(t *testing.T, output string) string {
+	if runtime.GOOS == "windows" {
+		t.Skip("mock ffmpeg binary is a shell script")
+	}
+	dir, err := ioutil.TempDir("", "mock-ffmpeg")
+	assert.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, "ffmpeg")
+	script := "#!/bin/sh\ncat <<'EOF'\n" + output + "EOF\n"
+	assert.NoError(t, ioutil.WriteFile(path, []byte(script), 0755))
+	return path
+}
+
+func TestParseHWAccels(t *testing.T) {
+	out := []byte("Hardware acceleration methods:\nvdpau\ncuda\n\nvaapi\n")
+	assert.Equal(t, []string{"vdpau", "cuda", "vaapi"}, parseHWAccels(out))
+	assert.Empty(t, parseHWAccels([]byte("Hardware acceleration methods:\n")))
+}
+
+func TestDetectBestHWAccel(t *testing.T) {
+	path := newMockFFmpeg(t, "Hardware acceleration methods:\nvdpau\nvaapi\ncuda\n")
+	assert.Equal(t, "cuda", detectBestHWAccel(path))
+
+	disableDetectedHWAccel(path)
+	assert.Equal(t, "", detectBestHWAccel(path))
+
+	path = newMockFFmpeg(t, "Hardware acceleration methods:\nvdpau\n")
+	assert.Equal(t, "", detectBestHWAccel(path))
+
+	assert.Equal(t, "", detectBestHWAccel(filepath.Join(os.TempDir(), "not-exist-ffmpeg")))
+}
+
+func TestResolveHWAccel(t *testing.T) {
+	path := newMockFFmpeg(t, "Hardware acceleration methods:\nvaapi\n")
+	for hwAccel, expected := range map[string]string{
+		"":        "",
+		"none":    "",
+		"auto":    "vaapi",
+		"cuda":    "cuda",
+		"vaapi":   "vaapi",
+		"unknown": "unknown",
+	} {
+		p := &Parser{hwAccel: hwAccel}
+		assert.Equal(t, expected, p.resolveHWAccel(path), hwAccel)
+	}
+}
+
+func TestHWAccelFailed(t *testing.T) {
+	assert.True(t, hwAccelFailed("[AVHWDeviceContext @ 0x1] Cannot load libcuda.so.1\nDevice creation failed: -1.\n"))
+	assert.True(t, hwAccelFailed("Failed setup for format cuda: hwaccel initialisation returned error.\n"))
+	assert.False(t, hwAccelFailed("https://example.com/live.flv: Connection timed out\n"))
+	assert.False(t, hwAccelFailed(""))
+}
@@ -0,0 +1,44 @@
+package ffmpeg
+
+import (
+	"strings"
+	"sync"
+)
+
+const stderrTailSize = 4096
+
+// stderrTail keeps the last bytes ffmpeg writes to stderr, which tell why it exited.
+type stderrTail struct {
+	sync.Mutex
+	buf []byte
+}
+
+func (t *stderrTail) Write(p []byte) (int, error) {
+	t.Lock()
+	defer t.Unlock()
+	t.buf = append(t.buf, p...)
+	if len(t.buf) > stderrTailSize {
+		t.buf = append(t.buf[:0], t.buf[len(t.buf)-stderrTailSize:]...)
+	}
+	return len(p), nil
+}
+
+func (t *stderrTail) String() string {
+	t.Lock()
+	defer t.Unlock()
+	return string(t.buf)
+}
+
+// lastLines returns the last n non-empty lines, joined by "; ".
+func (t *stderrTail) lastLines(n int) string {
+	t.Lock()
+	defer t.Unlock()
+	lines := make([]string, 0, n)
+	all := strings.Split(string(t.buf), "\n")
+	for i := len(all) - 1; i >= 0 && len(lines) < n; i-- {
+		if line := strings.TrimSpace(all[i]); line != "" {
+			lines = append([]string{line}, lines...)
+		}
+	}
+	return strings.Join(lines, "; ")
+}
@@ -0,0 +1,18 @@
+package ffmpeg
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStderrTail(t *testing.T) {
+	tail := new(stderrTail)
+	tail.Write([]byte(strings.Repeat("x", stderrTailSize)))
+	tail.Write([]byte("\nav_interleaved_write_frame(): No space left on device\n\n"))
+	tail.Write([]byte("Conversion failed!\n"))
+	assert.Len(t, tail.buf, stderrTailSize)
+	assert.Equal(t, "av_interleaved_write_frame(): No space left on device; Conversion failed!", tail.lastLines(2))
+	assert.Equal(t, "", new(stderrTail).lastLines(2))
+}
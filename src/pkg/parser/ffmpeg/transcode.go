@@ -0,0 +1,90 @@
+package ffmpeg
+
+import (
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultTranscodeVideoCodec = "libx264"
+	defaultTranscodePreset     = "veryfast"
+
+	// ffmpeg reads the input at the native frame rate (-re), so a speed lower than
+	// transcodeMinSpeed means the encoder can not keep up with the live stream.
+	transcodeMinSpeed = 0.95
+	// the progress is reported every 0.5s, skip the first reports while ffmpeg warms up
+	transcodeWarmupReports = 20
+	// fall back to stream-copy after so many consecutive slow reports
+	transcodeSlowReports = 20
+)
+
+type transcode struct {
+	videoBitrate string
+	audioBitrate string
+	height       int
+	videoCodec   string
+	preset       string
+}
+
+// newTranscode returns nil when the transcode_video_bitrate is not set, which means stream-copy.
+func newTranscode(cfg map[string]string) *transcode {
+	if cfg["transcode_video_bitrate"] == "" {
+		return nil
+	}
+	t := &transcode{
+		videoBitrate: cfg["transcode_video_bitrate"],
+		audioBitrate: cfg["transcode_audio_bitrate"],
+		videoCodec:   cfg["transcode_video_codec"],
+		preset:       cfg["transcode_preset"],
+	}
+	t.height, _ = strconv.Atoi(cfg["transcode_height"])
+	if t.videoCodec == "" {
+		t.videoCodec = defaultTranscodeVideoCodec
+	}
+	if t.preset == "" {
+		t.preset = defaultTranscodePreset
+	}
+	return t
+}
+
+func (t *transcode) args() []string {
+	args := []string{
+		"-c:v", t.videoCodec,
+		"-preset", t.preset,
+		"-b:v", t.videoBitrate,
+		"-maxrate", t.videoBitrate,
+	}
+	if t.height > 0 {
+		args = append(args, "-vf", "scale=-2:"+strconv.Itoa(t.height))
+	}
+	if t.audioBitrate != "" {
+		args = append(args, "-c:a", "aac", "-b:a", t.audioBitrate)
+	} else {
+		args = append(args, "-c:a", "copy", "-bsf:a", "aac_adtstoasc")
+	}
+	return args
+}
+
+// speedMonitor tells whether the transcoding falls behind the live stream by the speed in the ffmpeg progress.
+type speedMonitor struct {
+	reports int
+	slow    int
+}
+
+// feed returns true when ffmpeg has been too slow for transcodeSlowReports reports.
+func (m *speedMonitor) feed(status map[string]string) bool {
+	speed, err := strconv.ParseFloat(strings.TrimSuffix(status["speed"], "x"), 64)
+	if err != nil {
+		return false
+	}
+	m.reports++
+	if m.reports <= transcodeWarmupReports {
+		return false
+	}
+	if speed >= transcodeMinSpeed {
+		m.slow = 0
+		return false
+	}
+	m.slow++
+	return m.slow >= transcodeSlowReports
+}
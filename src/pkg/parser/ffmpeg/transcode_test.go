@@ -0,0 +1,46 @@
+package ffmpeg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewTranscode(t *testing.T) {
+	assert.Nil(t, newTranscode(map[string]string{}))
+
+	tc := newTranscode(map[string]string{"transcode_video_bitrate": "1500k"})
+	assert.Equal(t, []string{
+		"-c:v", "libx264", "-preset", "veryfast", "-b:v", "1500k", "-maxrate", "1500k",
+		"-c:a", "copy", "-bsf:a", "aac_adtstoasc",
+	}, tc.args())
+
+	tc = newTranscode(map[string]string{
+		"transcode_video_bitrate": "1M",
+		"transcode_audio_bitrate": "96k",
+		"transcode_height":        "720",
+		"transcode_video_codec":   "libx265",
+		"transcode_preset":        "fast",
+	})
+	assert.Equal(t, []string{
+		"-c:v", "libx265", "-preset", "fast", "-b:v", "1M", "-maxrate", "1M",
+		"-vf", "scale=-2:720", "-c:a", "aac", "-b:a", "96k",
+	}, tc.args())
+}
+
+func TestSpeedMonitor(t *testing.T) {
+	m := new(speedMonitor)
+	for i := 0; i < transcodeWarmupReports; i++ {
+		assert.False(t, m.feed(map[string]string{"speed": "0.5x"}))
+	}
+	for i := 0; i < transcodeSlowReports-1; i++ {
+		assert.False(t, m.feed(map[string]string{"speed": "0.8x"}))
+	}
+	// a normal report resets the counter
+	assert.False(t, m.feed(map[string]string{"speed": "1x"}))
+	assert.False(t, m.feed(map[string]string{"speed": "N/A"}))
+	for i := 0; i < transcodeSlowReports-1; i++ {
+		assert.False(t, m.feed(map[string]string{"speed": "0.8x"}))
+	}
+	assert.True(t, m.feed(map[string]string{"speed": "0.8x"}))
+}
@@ -0,0 +1,51 @@
+package flv
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+)
+
+// amfWriter encodes the AMF0 values used in script tags.
+type amfWriter struct {
+	bytes.Buffer
+}
+
+func (w *amfWriter) writeKey(key string) {
+	binary.Write(w, binary.BigEndian, uint16(len(key)))
+	w.WriteString(key)
+}
+
+func (w *amfWriter) writeNumber(n float64) {
+	w.WriteByte(byte(Number))
+	binary.Write(w, binary.BigEndian, math.Float64bits(n))
+}
+
+func (w *amfWriter) writeString(s string) {
+	w.WriteByte(byte(String))
+	w.writeKey(s)
+}
+
+func (w *amfWriter) writeLongString(s string) {
+	w.WriteByte(byte(LongString))
+	binary.Write(w, binary.BigEndian, uint32(len(s)))
+	w.WriteString(s)
+}
+
+func (w *amfWriter) writeNumberArray(ns []float64) {
+	w.WriteByte(byte(StrictArray))
+	binary.Write(w, binary.BigEndian, uint32(len(ns)))
+	for _, n := range ns {
+		w.writeNumber(n)
+	}
+}
+
+func (w *amfWriter) writeObjectStart() {
+	w.WriteByte(byte(Object))
+}
+
+func (w *amfWriter) writeObjectEnd() {
+	w.Write(amfObjectEnd)
+}
+
+var amfObjectEnd = []byte{0, 0, byte(ObjectEndMarker)}
@@ -0,0 +1,85 @@
+package flv
+
+import (
+	"sync"
+	"time"
+
+	"github.com/hr3lxphr6j/bililive-go/src/pkg/parser"
+)
+
+const (
+	// a delta larger than discontinuityFactor times of the average delta is a discontinuity
+	discontinuityFactor = 3
+	// the average delta is not reliable until enough deltas are collected
+	discontinuityMinSamples = 3
+)
+
+// discontinuityDetector compares the deltas of the consecutive video timestamps.
+type discontinuityDetector struct {
+	sync.Mutex
+	last            uint32
+	hasLast         bool
+	totalDelta      int64
+	deltaCount      int64
+	discontinuities []parser.Discontinuity
+	onDiscontinuity func(parser.Discontinuity)
+}
+
+// feed returns the discontinuity between timestamp and the previous one, nil if there is none.
+func (d *discontinuityDetector) feed(timestamp uint32, now time.Time) *parser.Discontinuity {
+	d.Lock()
+	defer d.Unlock()
+	last, hasLast := d.last, d.hasLast
+	d.last, d.hasLast = timestamp, true
+	if !hasLast {
+		return nil
+	}
+	delta := int64(timestamp) - int64(last)
+	isGap := delta < 0 ||
+		(d.deltaCount >= discontinuityMinSamples && delta > discontinuityFactor*d.totalDelta/d.deltaCount)
+	if !isGap {
+		if delta > 0 {
+			d.totalDelta += delta
+			d.deltaCount++
+		}
+		return nil
+	}
+	disc := parser.Discontinuity{
+		Timestamp: now,
+		PTSGap:    time.Duration(delta) * time.Millisecond,
+	}
+	d.discontinuities = append(d.discontinuities, disc)
+	return &disc
+}
+
+func (d *discontinuityDetector) list() []parser.Discontinuity {
+	d.Lock()
+	defer d.Unlock()
+	return append([]parser.Discontinuity(nil), d.discontinuities...)
+}
+
+func (d *discontinuityDetector) count() int {
+	d.Lock()
+	defer d.Unlock()
+	return len(d.discontinuities)
+}
+
+func (d *discontinuityDetector) handler() func(parser.Discontinuity) {
+	d.Lock()
+	defer d.Unlock()
+	return d.onDiscontinuity
+}
+
+func (p *Parser) OnDiscontinuity(fn func(parser.Discontinuity)) {
+	p.discontinuities.Lock()
+	defer p.discontinuities.Unlock()
+	p.discontinuities.onDiscontinuity = fn
+}
+
+func (p *Parser) Discontinuities() []parser.Discontinuity {
+	return p.discontinuities.list()
+}
+
+func (p *Parser) DiscontinuityCount() int {
+	return p.discontinuities.count()
+}
@@ -0,0 +1,66 @@
+package flv
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hr3lxphr6j/bililive-go/src/instance"
+	"github.com/hr3lxphr6j/bililive-go/src/interfaces"
+	"github.com/hr3lxphr6j/bililive-go/src/pkg/counter"
+	"github.com/hr3lxphr6j/bililive-go/src/pkg/parser"
+	"github.com/hr3lxphr6j/bililive-go/src/pkg/reader"
+)
+
+func TestDiscontinuityDetector(t *testing.T) {
+	d := new(discontinuityDetector)
+	now := time.Now()
+	for _, ts := range []uint32{0, 40, 80, 120, 160} {
+		assert.Nil(t, d.feed(ts, now))
+	}
+	disc := d.feed(5160, now)
+	assert.NotNil(t, disc)
+	assert.Equal(t, 5*time.Second, disc.PTSGap)
+	// the gap is not counted into the average delta
+	assert.Nil(t, d.feed(5200, now))
+	// timestamps going backwards
+	disc = d.feed(0, now)
+	assert.NotNil(t, disc)
+	assert.Equal(t, -5200*time.Millisecond, disc.PTSGap)
+	assert.Equal(t, 2, len(d.list()))
+}
+
+func TestParseDiscontinuity(t *testing.T) {
+	b := []byte{'F', 'L', 'V', 1, 1, 0, 0, 0, 9, 0, 0, 0, 0}
+	b = append(b, buildTag(videoTag, 0, []byte{0x17, 0, 0, 0, 0, 1, 2, 3})...)
+	for _, ts := range []uint32{0, 40, 80, 120, 160, 10160, 10200} {
+		b = append(b, buildTag(videoTag, ts, []byte{0x27, 1, 0, 0, 0, 9})...)
+	}
+
+	ctx := context.WithValue(context.Background(), instance.Key, &instance.Instance{
+		Logger: &interfaces.Logger{Logger: logrus.New()},
+	})
+	p, err := new(builder).Build(map[string]string{})
+	assert.NoError(t, err)
+	var found []parser.Discontinuity
+	p.(parser.DiscontinuityParser).OnDiscontinuity(func(d parser.Discontinuity) {
+		found = append(found, d)
+	})
+	fp := p.(*Parser)
+	fp.i = reader.New(bytes.NewReader(b))
+	fp.o = counter.NewCountWriter(ioutil.Discard)
+	assert.Equal(t, io.EOF, fp.doParse(ctx))
+
+	assert.Equal(t, 1, fp.DiscontinuityCount())
+	assert.Equal(t, found, fp.Discontinuities())
+	assert.Equal(t, 10*time.Second, found[0].PTSGap)
+	status, err := fp.Status()
+	assert.NoError(t, err)
+	assert.Equal(t, "1", status["discontinuity_count"])
+}
@@ -11,10 +11,13 @@ import (
 	"net/url"
 	"os"
 	"runtime/debug"
+	"strconv"
 	"sync"
+	"sync/atomic"
 
 	"github.com/hr3lxphr6j/bililive-go/src/instance"
 	"github.com/hr3lxphr6j/bililive-go/src/live"
+	"github.com/hr3lxphr6j/bililive-go/src/pkg/counter"
 	"github.com/hr3lxphr6j/bililive-go/src/pkg/parser"
 	"github.com/hr3lxphr6j/bililive-go/src/pkg/reader"
 	"github.com/hr3lxphr6j/bililive-go/src/pkg/utils"
@@ -48,11 +51,14 @@ func (b *builder) Build(cfg map[string]string) (parser.Parser, error) {
 	// if err != nil {
 	// 	timeout = time.Minute
 	// }
+	maxDuration, _ := strconv.ParseUint(cfg["max_duration_ms"], 10, 32)
 	return &Parser{
-		Metadata:  Metadata{},
-		hc:        &http.Client{},
-		stopCh:    make(chan struct{}),
-		closeOnce: new(sync.Once),
+		Metadata:           Metadata{},
+		segment:            segmenter{maxDuration: uint32(maxDuration)},
+		hc:                 &http.Client{},
+		stopCh:             make(chan struct{}),
+		closeOnce:          new(sync.Once),
+		writeKeyframeIndex: cfg["write_keyframe_index"] == "true",
 	}, nil
 }
 
@@ -64,9 +70,20 @@ type Parser struct {
 	Metadata Metadata
 
 	i              *reader.BufferedReader
-	o              io.Writer
+	o              counter.CountWriter
+	output         atomic.Value // *hashWriter of o
+	f              *os.File
+	file           string
 	avcHeaderCount uint8
 	tagCount       uint32
+	prevTagSize    uint32 // overrides the PreviousTagSize of the next tag when not 0
+
+	writeKeyframeIndex bool
+	metadataChecked    bool
+	metadata           *metadataPlaceholder
+	keyframes          []keyframe
+	discontinuities    discontinuityDetector
+	segment            segmenter
 
 	hc        *http.Client
 	stopCh    chan struct{}
@@ -93,15 +110,31 @@ func (p *Parser) ParseLiveStream(ctx context.Context, url *url.URL, live live.Li
 	defer p.i.Free()
 
 	// init output
-	f, err := os.OpenFile(file, os.O_RDWR|os.O_CREATE, 0666)
-	if err != nil {
+	if err := p.openFile(file); err != nil {
 		return err
 	}
-	p.o = f
-	defer f.Close()
+	p.file = file
+	// the file is changed by the segmentation
+	defer func() { p.f.Close() }()
 
 	// start parse
-	return p.doParse(ctx)
+	err = p.doParse(ctx)
+	if err := p.flushKeyframeIndex(p.f); err != nil {
+		instance.GetInstance(ctx).Logger.WithError(err).Warn("failed to write keyframe index")
+	}
+	return err
+}
+
+func (p *Parser) openFile(file string) error {
+	f, err := os.OpenFile(file, os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		return err
+	}
+	p.f = f
+	w := newHashWriter(file, f)
+	p.o = counter.NewCountWriter(w)
+	p.output.Store(w)
+	return nil
 }
 
 func (p *Parser) Stop() error {
@@ -111,6 +144,12 @@ func (p *Parser) Stop() error {
 	return nil
 }
 
+func (p *Parser) Status() (map[string]string, error) {
+	return map[string]string{
+		"discontinuity_count": strconv.Itoa(p.DiscontinuityCount()),
+	}, nil
+}
+
 func (p *Parser) doParse(ctx context.Context) error {
 	// header of flv
 	b, err := p.i.ReadN(9)
@@ -131,6 +170,9 @@ func (p *Parser) doParse(ctx context.Context) error {
 	}
 
 	// write flv header
+	if p.segment.enabled() {
+		p.segment.header = append([]byte(nil), p.i.AllBytes()...)
+	}
 	if err := p.doWrite(ctx, p.i.AllBytes()); err != nil {
 		return err
 	}
@@ -0,0 +1,56 @@
+package flv
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"hash"
+	"io"
+	"sync"
+)
+
+// hashWriter hashes the content while it's written to the file.
+type hashWriter struct {
+	sync.Mutex
+	w         io.Writer
+	file      string
+	h         hash.Hash
+	rewritten bool
+}
+
+func newHashWriter(file string, w io.Writer) *hashWriter {
+	return &hashWriter{w: w, file: file, h: md5.New()}
+}
+
+func (w *hashWriter) Write(b []byte) (int, error) {
+	n, err := w.w.Write(b)
+	w.Lock()
+	w.h.Write(b[:n])
+	w.Unlock()
+	return n, err
+}
+
+// markRewritten invalidates the hash after the written content is modified in place.
+func (w *hashWriter) markRewritten() {
+	w.Lock()
+	w.rewritten = true
+	w.Unlock()
+}
+
+func (w *hashWriter) sum() string {
+	w.Lock()
+	defer w.Unlock()
+	if w.rewritten {
+		return ""
+	}
+	return hex.EncodeToString(w.h.Sum(nil))
+}
+
+// MD5 returns the file being written and the md5 of the content written to it, the sum is
+// empty before writing or after the keyframe index is filled into the head of the file.
+func (p *Parser) MD5() (file, sum string) {
+	w, ok := p.output.Load().(*hashWriter)
+	if !ok {
+		return "", ""
+	}
+	return w.file, w.sum()
+}
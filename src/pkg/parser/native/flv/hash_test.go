@@ -0,0 +1,58 @@
+package flv
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hr3lxphr6j/bililive-go/src/instance"
+	"github.com/hr3lxphr6j/bililive-go/src/interfaces"
+	"github.com/hr3lxphr6j/bililive-go/src/pkg/reader"
+)
+
+func TestMD5(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hash")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	ctx := context.WithValue(context.Background(), instance.Key, &instance.Instance{
+		Logger: &interfaces.Logger{Logger: logrus.New()},
+	})
+	for _, writeKeyframeIndex := range []bool{false, true} {
+		p, err := new(builder).Build(map[string]string{"write_keyframe_index": strconv.FormatBool(writeKeyframeIndex)})
+		assert.NoError(t, err)
+		parser := p.(*Parser)
+		file, sum := parser.MD5()
+		assert.Empty(t, file)
+		assert.Empty(t, sum)
+
+		parser.i = reader.New(bytes.NewReader(buildFlv()))
+		parser.file = filepath.Join(dir, "record.flv")
+		assert.NoError(t, parser.openFile(parser.file))
+		assert.Equal(t, io.EOF, parser.doParse(ctx))
+		assert.NoError(t, parser.flushKeyframeIndex(parser.f))
+		assert.NoError(t, parser.f.Close())
+
+		file, sum = parser.MD5()
+		assert.Equal(t, parser.file, file)
+		if writeKeyframeIndex {
+			// the head is rewritten
+			assert.Empty(t, sum)
+			continue
+		}
+		b, err := ioutil.ReadFile(parser.file)
+		assert.NoError(t, err)
+		expected := md5.Sum(b)
+		assert.Equal(t, hex.EncodeToString(expected[:]), sum)
+	}
+}
@@ -0,0 +1,130 @@
+package flv
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"strings"
+)
+
+const (
+	// the max count of keyframes kept in the index, the index is sampled down when exceeded.
+	keyframeIndexCapacity = 10000
+
+	keyframesKey        = "keyframes"
+	keyframesPaddingKey = "keyframesPadding"
+)
+
+var (
+	onMetaData = append([]byte{byte(String), 0, 10}, "onMetaData"...)
+
+	errNotMetadata = errors.New("not an onMetaData script tag")
+)
+
+type keyframe struct {
+	time     float64 // in seconds
+	position int64   // offset of the video tag in the output file
+}
+
+// metadataPlaceholder is the onMetaData script tag reserved with room for the keyframe index.
+type metadataPlaceholder struct {
+	origin []byte // body of the original onMetaData tag
+	offset int64  // offset of the body in the output file
+	size   int    // size of the reserved body
+}
+
+// encodeKeyframeIndex builds the body of onMetaData with the keyframe index appended,
+// and pads it to size when size > 0.
+func encodeKeyframeIndex(origin []byte, keyframes []keyframe, size int) ([]byte, error) {
+	if !bytes.HasPrefix(origin, onMetaData) || !bytes.HasSuffix(origin, amfObjectEnd) || len(origin) < len(onMetaData)+4 {
+		return nil, errNotMetadata
+	}
+	w := new(amfWriter)
+	w.Write(origin[:len(origin)-len(amfObjectEnd)])
+	body := w.Bytes()
+	switch DataType(body[len(onMetaData)]) {
+	case ECMAArray:
+		countOffset := len(onMetaData) + 1
+		count := binary.BigEndian.Uint32(body[countOffset:])
+		binary.BigEndian.PutUint32(body[countOffset:], count+2)
+	case Object:
+	default:
+		return nil, errNotMetadata
+	}
+
+	times := make([]float64, len(keyframes))
+	positions := make([]float64, len(keyframes))
+	for i, k := range keyframes {
+		times[i] = k.time
+		positions[i] = float64(k.position)
+	}
+	w.writeKey(keyframesKey)
+	w.writeObjectStart()
+	w.writeKey("filepositions")
+	w.writeNumberArray(positions)
+	w.writeKey("times")
+	w.writeNumberArray(times)
+	w.writeObjectEnd()
+
+	// the padding fills the room of the unused keyframes, so that the size of
+	// the tag never changes after it is written.
+	padding := 0
+	if size > 0 {
+		padding = size - w.Len() - (2 + len(keyframesPaddingKey) + 5) - len(amfObjectEnd)
+		if padding < 0 {
+			return nil, errors.New("not enough room for the keyframe index")
+		}
+	}
+	w.writeKey(keyframesPaddingKey)
+	w.writeLongString(strings.Repeat(" ", padding))
+	w.writeObjectEnd()
+	return w.Bytes(), nil
+}
+
+// sampleKeyframes keeps at most n keyframes evenly.
+func sampleKeyframes(keyframes []keyframe, n int) []keyframe {
+	if len(keyframes) <= n {
+		return keyframes
+	}
+	sampled := make([]keyframe, n)
+	for i := range sampled {
+		sampled[i] = keyframes[i*len(keyframes)/n]
+	}
+	return sampled
+}
+
+// reserveMetadata reads the body of the script tag, and returns the onMetaData
+// with room for the keyframe index.
+func (p *Parser) reserveMetadata(length uint32) ([]byte, error) {
+	origin := make([]byte, length)
+	if _, err := io.ReadFull(p.i, origin); err != nil {
+		return nil, err
+	}
+	body, err := encodeKeyframeIndex(origin, make([]keyframe, keyframeIndexCapacity), 0)
+	if err != nil {
+		return origin, err
+	}
+	p.metadata = &metadataPlaceholder{
+		origin: origin,
+		size:   len(body),
+	}
+	return body, nil
+}
+
+// flushKeyframeIndex fills the keyframe index into the reserved onMetaData.
+func (p *Parser) flushKeyframeIndex(f *os.File) error {
+	if p.metadata == nil || len(p.keyframes) == 0 {
+		return nil
+	}
+	body, err := encodeKeyframeIndex(p.metadata.origin, sampleKeyframes(p.keyframes, keyframeIndexCapacity), p.metadata.size)
+	if err != nil {
+		return err
+	}
+	if w, ok := p.output.Load().(*hashWriter); ok {
+		w.markRewritten()
+	}
+	_, err = f.WriteAt(body, p.metadata.offset)
+	return err
+}
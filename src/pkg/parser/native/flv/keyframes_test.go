@@ -0,0 +1,122 @@
+package flv
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hr3lxphr6j/bililive-go/src/instance"
+	"github.com/hr3lxphr6j/bililive-go/src/interfaces"
+	"github.com/hr3lxphr6j/bililive-go/src/pkg/counter"
+	"github.com/hr3lxphr6j/bililive-go/src/pkg/reader"
+)
+
+func buildTag(typ uint8, timestamp uint32, body []byte) []byte {
+	b := []byte{
+		typ,
+		byte(len(body) >> 16), byte(len(body) >> 8), byte(len(body)),
+		byte(timestamp >> 16), byte(timestamp >> 8), byte(timestamp), byte(timestamp >> 24),
+		0, 0, 0,
+	}
+	b = append(b, body...)
+	size := make([]byte, 4)
+	binary.BigEndian.PutUint32(size, uint32(len(b)))
+	return append(b, size...)
+}
+
+func buildFlv() []byte {
+	w := new(amfWriter)
+	w.Write(onMetaData)
+	w.WriteByte(byte(ECMAArray))
+	binary.Write(w, binary.BigEndian, uint32(1))
+	w.writeKey("duration")
+	w.writeNumber(0)
+	w.writeObjectEnd()
+
+	b := []byte{'F', 'L', 'V', 1, 5, 0, 0, 0, 9, 0, 0, 0, 0}
+	b = append(b, buildTag(scriptTag, 0, w.Bytes())...)
+	b = append(b, buildTag(videoTag, 0, []byte{0x17, 0, 0, 0, 0, 1, 2, 3})...)
+	for i := uint32(0); i < 5; i++ {
+		b = append(b, buildTag(videoTag, i*2000, []byte{0x17, 1, 0, 0, 0, 4, 5, 6})...)
+		b = append(b, buildTag(audioTag, i*2000, []byte{0xaf, 1, 7, 8})...)
+		b = append(b, buildTag(videoTag, i*2000+1000, []byte{0x27, 1, 0, 0, 0, 9})...)
+	}
+	return b
+}
+
+func readNumberArray(t *testing.T, body []byte, key string) []float64 {
+	idx := bytes.Index(body, []byte(key))
+	assert.True(t, idx >= 0, key)
+	b := body[idx+len(key):]
+	assert.Equal(t, byte(StrictArray), b[0])
+	n := binary.BigEndian.Uint32(b[1:])
+	ns := make([]float64, n)
+	for i := range ns {
+		offset := 5 + i*9
+		assert.Equal(t, byte(Number), b[offset])
+		ns[i] = math.Float64frombits(binary.BigEndian.Uint64(b[offset+1:]))
+	}
+	return ns
+}
+
+func TestWriteKeyframeIndex(t *testing.T) {
+	f, err := ioutil.TempFile("", "keyframes-*.flv")
+	assert.NoError(t, err)
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	ctx := context.WithValue(context.Background(), instance.Key, &instance.Instance{
+		Logger: &interfaces.Logger{Logger: logrus.New()},
+	})
+	p, err := new(builder).Build(map[string]string{"write_keyframe_index": "true"})
+	assert.NoError(t, err)
+	parser := p.(*Parser)
+	parser.i = reader.New(bytes.NewReader(buildFlv()))
+	parser.o = counter.NewCountWriter(f)
+	assert.Equal(t, io.EOF, parser.doParse(ctx))
+	assert.NoError(t, parser.flushKeyframeIndex(f))
+
+	out, err := ioutil.ReadFile(f.Name())
+	assert.NoError(t, err)
+
+	// every PreviousTagSize still matches the tag before it
+	offset := 13
+	for offset+11 <= len(out) {
+		size := int(out[offset+1])<<16 | int(out[offset+2])<<8 | int(out[offset+3])
+		if offset+11+size+4 > len(out) {
+			break
+		}
+		assert.Equal(t, uint32(size+11), binary.BigEndian.Uint32(out[offset+11+size:]))
+		offset += 11 + size + 4
+	}
+
+	metaSize := int(out[14])<<16 | int(out[15])<<8 | int(out[16])
+	meta := out[24 : 24+metaSize]
+	assert.Equal(t, uint32(3), binary.BigEndian.Uint32(meta[len(onMetaData)+1:]))
+	positions := readNumberArray(t, meta, "filepositions")
+	times := readNumberArray(t, meta, "times")
+	assert.Equal(t, []float64{0, 2, 4, 6, 8}, times)
+	assert.Len(t, positions, 5)
+	for _, pos := range positions {
+		assert.Equal(t, videoTag, out[int(pos)])
+		assert.Equal(t, byte(KeyFrame), out[int(pos)+11]>>4)
+	}
+}
+
+func TestSampleKeyframes(t *testing.T) {
+	keyframes := make([]keyframe, 10)
+	for i := range keyframes {
+		keyframes[i].time = float64(i)
+	}
+	assert.Equal(t, keyframes, sampleKeyframes(keyframes, 10))
+	sampled := sampleKeyframes(keyframes, 5)
+	assert.Equal(t, []keyframe{{time: 0}, {time: 2}, {time: 4}, {time: 6}, {time: 8}}, sampled)
+}
@@ -0,0 +1,161 @@
+package flv
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/hr3lxphr6j/bililive-go/src/instance"
+)
+
+// segmenter splits the output into a new file at the first keyframe after maxDuration,
+// the timestamps of the new files start from 0.
+type segmenter struct {
+	sync.Mutex
+	maxDuration uint32 // in milliseconds, 0 means disabled
+
+	// written to the beginning of every new file, the tags are kept without the PreviousTagSize
+	header         []byte
+	metadata       []byte
+	videoSeqHeader []byte
+	audioSeqHeader []byte
+
+	started      bool
+	start        uint32 // timestamp of the first keyframe of the current file
+	base         uint32 // subtracted from the timestamps of the tags of the current file
+	count        int
+	onNewSegment func(file string)
+}
+
+func (s *segmenter) enabled() bool {
+	return s.maxDuration > 0
+}
+
+// relative returns the timestamp in the current file.
+func (s *segmenter) relative(timestamp uint32) uint32 {
+	if timestamp < s.base {
+		return 0
+	}
+	return timestamp - s.base
+}
+
+// shouldSplit reports whether the keyframe of timestamp starts a new file.
+func (s *segmenter) shouldSplit(timestamp uint32) bool {
+	if !s.enabled() {
+		return false
+	}
+	if !s.started {
+		s.started, s.start = true, timestamp
+		return false
+	}
+	return timestamp >= s.start && timestamp-s.start >= s.maxDuration
+}
+
+func (s *segmenter) handler() func(string) {
+	s.Lock()
+	defer s.Unlock()
+	return s.onNewSegment
+}
+
+// OnNewSegment sets the callback called with the name of every new file opened by the segmentation.
+func (p *Parser) OnNewSegment(fn func(file string)) {
+	p.segment.Lock()
+	defer p.segment.Unlock()
+	p.segment.onNewSegment = fn
+}
+
+// segmentFileName returns the name of the nth new file, e.g. "a_PART1.flv" for "a.flv".
+func segmentFileName(file string, n int) string {
+	ext := filepath.Ext(file)
+	return fmt.Sprintf("%s_PART%d%s", strings.TrimSuffix(file, ext), n, ext)
+}
+
+// setTimestamp writes timestamp into the tag header.
+func setTimestamp(header []byte, timestamp uint32) {
+	header[4], header[5], header[6], header[7] = byte(timestamp>>16), byte(timestamp>>8), byte(timestamp), byte(timestamp>>24)
+}
+
+// writeTag writes the buffered tag header and the body of length bytes,
+// the whole tag is also kept in cache when it's not nil.
+func (p *Parser) writeTag(ctx context.Context, length uint32, cache *[]byte) error {
+	if cache == nil {
+		if err := p.doWrite(ctx, p.i.AllBytes()); err != nil {
+			return err
+		}
+		p.i.Reset()
+		return p.doCopy(ctx, length)
+	}
+	header := append([]byte(nil), p.i.AllBytes()...)
+	p.i.Reset()
+	body := make([]byte, length)
+	if _, err := io.ReadFull(p.i, body); err != nil {
+		return err
+	}
+	*cache = append(append([]byte(nil), header[4:]...), body...)
+	if err := p.doWrite(ctx, header); err != nil {
+		return err
+	}
+	return p.doWrite(ctx, body)
+}
+
+// newSegment closes the current file and continues in a new one from the keyframe of timestamp,
+// whose header is still buffered.
+func (p *Parser) newSegment(ctx context.Context, timestamp uint32) error {
+	if err := p.flushKeyframeIndex(p.f); err != nil {
+		instance.GetInstance(ctx).Logger.WithError(err).Warn("failed to write keyframe index")
+	}
+	if err := p.f.Close(); err != nil {
+		return err
+	}
+	p.segment.count++
+	file := segmentFileName(p.file, p.segment.count)
+	if err := p.openFile(file); err != nil {
+		return err
+	}
+	p.keyframes = nil
+	if err := p.doWrite(ctx, p.segment.header); err != nil {
+		return err
+	}
+
+	metadata := p.segment.metadata
+	if p.metadata != nil {
+		body, err := encodeKeyframeIndex(p.metadata.origin, make([]keyframe, keyframeIndexCapacity), 0)
+		if err != nil {
+			return err
+		}
+		size := len(body)
+		metadata = append([]byte{scriptTag, byte(size >> 16), byte(size >> 8), byte(size), 0, 0, 0, 0, 0, 0, 0}, body...)
+		p.metadata = &metadataPlaceholder{
+			origin: p.metadata.origin,
+			offset: int64(p.o.Count()) + 4 + 11,
+			size:   size,
+		}
+	}
+	prevTagSize := uint32(0)
+	for _, tag := range [][]byte{metadata, p.segment.videoSeqHeader, p.segment.audioSeqHeader} {
+		if tag == nil {
+			continue
+		}
+		b := make([]byte, 4, 4+len(tag))
+		binary.BigEndian.PutUint32(b, prevTagSize)
+		b = append(b, tag...)
+		setTimestamp(b[4:], 0)
+		if err := p.doWrite(ctx, b); err != nil {
+			return err
+		}
+		prevTagSize = uint32(len(tag))
+	}
+
+	header := p.i.AllBytes()
+	binary.BigEndian.PutUint32(header, prevTagSize)
+	setTimestamp(header[4:], 0)
+	p.segment.start, p.segment.base = timestamp, timestamp
+	if fn := p.segment.handler(); fn != nil {
+		fn(file)
+	}
+	return nil
+}
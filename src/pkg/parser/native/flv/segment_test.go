@@ -0,0 +1,154 @@
+package flv
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hr3lxphr6j/bililive-go/src/instance"
+	"github.com/hr3lxphr6j/bililive-go/src/interfaces"
+	"github.com/hr3lxphr6j/bililive-go/src/pkg/reader"
+)
+
+type testTag struct {
+	typ       uint8
+	timestamp uint32
+	body      []byte
+}
+
+// readTags checks the PreviousTagSize of every tag and returns them.
+func readTags(t *testing.T, b []byte) []testTag {
+	assert.Equal(t, flvSign, b[:4])
+	var tags []testTag
+	prevTagSize := uint32(0)
+	for offset := 9; offset+15 <= len(b); {
+		assert.Equal(t, prevTagSize, binary.BigEndian.Uint32(b[offset:]))
+		h := b[offset+4:]
+		size := uint32(h[1])<<16 | uint32(h[2])<<8 | uint32(h[3])
+		tags = append(tags, testTag{
+			typ:       h[0],
+			timestamp: uint32(h[4])<<16 | uint32(h[5])<<8 | uint32(h[6]) | uint32(h[7])<<24,
+			body:      h[11 : 11+size],
+		})
+		prevTagSize = size + 11
+		offset += 4 + int(prevTagSize)
+	}
+	return tags
+}
+
+func TestSegmentFileName(t *testing.T) {
+	assert.Equal(t, "/a/b_PART1.flv", segmentFileName("/a/b.flv", 1))
+	assert.Equal(t, "b_PART12", segmentFileName("b", 12))
+}
+
+func TestSegmentByKeyframe(t *testing.T) {
+	dir, err := ioutil.TempDir("", "segment")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	videoSeqHeader := []byte{0x17, 0, 0, 0, 0, 1, 2, 3}
+	audioSeqHeader := []byte{0xaf, 0, 0x12, 0x10}
+	b := []byte{'F', 'L', 'V', 1, 5, 0, 0, 0, 9, 0, 0, 0, 0}
+	b = append(b, buildTag(scriptTag, 0, []byte{2, 0, 1, 'm'})...)
+	b = append(b, buildTag(videoTag, 0, videoSeqHeader)...)
+	b = append(b, buildTag(audioTag, 0, audioSeqHeader)...)
+	// keyframe every 2s, an inter frame and an audio frame every 500ms
+	for ts := uint32(1000); ts < 11000; ts += 500 {
+		if ts%2000 == 0 {
+			b = append(b, buildTag(videoTag, ts, []byte{0x17, 1, 0, 0, 0, 4})...)
+		} else {
+			b = append(b, buildTag(videoTag, ts, []byte{0x27, 1, 0, 0, 0, 5})...)
+		}
+		b = append(b, buildTag(audioTag, ts, []byte{0xaf, 1, 6})...)
+	}
+
+	ctx := context.WithValue(context.Background(), instance.Key, &instance.Instance{
+		Logger: &interfaces.Logger{Logger: logrus.New()},
+	})
+	p, err := new(builder).Build(map[string]string{"max_duration_ms": "3000"})
+	assert.NoError(t, err)
+	parser := p.(*Parser)
+	var segments []string
+	parser.OnNewSegment(func(file string) { segments = append(segments, file) })
+	parser.i = reader.New(bytes.NewReader(b))
+	parser.file = filepath.Join(dir, "record.flv")
+	assert.NoError(t, parser.openFile(parser.file))
+	assert.Equal(t, io.EOF, parser.doParse(ctx))
+	assert.NoError(t, parser.f.Close())
+
+	// the first keyframe is at 2s, so the splits are at the keyframes of 6s and 10s
+	assert.Equal(t, []string{filepath.Join(dir, "record_PART1.flv"), filepath.Join(dir, "record_PART2.flv")}, segments)
+	files := append([]string{parser.file}, segments...)
+	firstTimestamps := []uint32{1000, 6000, 10000}
+	for i, file := range files {
+		out, err := ioutil.ReadFile(file)
+		assert.NoError(t, err)
+		tags := readTags(t, out)
+		if i > 0 {
+			// the headers are copied to the beginning of every new file
+			assert.Equal(t, scriptTag, tags[0].typ)
+			assert.Equal(t, videoSeqHeader, tags[1].body)
+			assert.Equal(t, audioSeqHeader, tags[2].body)
+			tags = tags[3:]
+			// the new file starts with the keyframe at 0
+			assert.Equal(t, videoTag, tags[0].typ)
+			assert.Equal(t, byte(KeyFrame), tags[0].body[0]>>4)
+			assert.Equal(t, uint32(0), tags[0].timestamp)
+		} else {
+			tags = tags[3:]
+		}
+		last := tags[len(tags)-1]
+		var base uint32
+		if i > 0 {
+			base = firstTimestamps[i]
+		}
+		assert.Equal(t, firstTimestamps[i], tags[0].timestamp+base, file)
+		// within one keyframe interval past the max duration
+		assert.True(t, last.timestamp+base-firstTimestamps[i] < 3000+2000, file)
+	}
+}
+
+func TestSegmentWithKeyframeIndex(t *testing.T) {
+	dir, err := ioutil.TempDir("", "segment")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	ctx := context.WithValue(context.Background(), instance.Key, &instance.Instance{
+		Logger: &interfaces.Logger{Logger: logrus.New()},
+	})
+	p, err := new(builder).Build(map[string]string{"write_keyframe_index": "true", "max_duration_ms": "3000"})
+	assert.NoError(t, err)
+	parser := p.(*Parser)
+	parser.i = reader.New(bytes.NewReader(buildFlv()))
+	parser.file = filepath.Join(dir, "record.flv")
+	assert.NoError(t, parser.openFile(parser.file))
+	assert.Equal(t, io.EOF, parser.doParse(ctx))
+	assert.NoError(t, parser.flushKeyframeIndex(parser.f))
+	assert.NoError(t, parser.f.Close())
+
+	// keyframes are at 0, 2, 4, 6 and 8s
+	for file, times := range map[string][]float64{
+		"record.flv":       {0, 2},
+		"record_PART1.flv": {0, 2},
+		"record_PART2.flv": {0},
+	} {
+		out, err := ioutil.ReadFile(filepath.Join(dir, file))
+		assert.NoError(t, err)
+		tags := readTags(t, out)
+		assert.Equal(t, scriptTag, tags[0].typ)
+		assert.Equal(t, times, readNumberArray(t, tags[0].body, "times"), file)
+		positions := readNumberArray(t, tags[0].body, "filepositions")
+		for _, pos := range positions {
+			assert.Equal(t, videoTag, out[int(pos)])
+			assert.Equal(t, byte(KeyFrame), out[int(pos)+11]>>4)
+		}
+	}
+}
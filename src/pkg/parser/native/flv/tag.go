@@ -1,6 +1,9 @@
 package flv
 
-import "context"
+import (
+	"context"
+	"encoding/binary"
+)
 
 func (p *Parser) parseTag(ctx context.Context) error {
 	p.tagCount += 1
@@ -9,10 +12,17 @@ func (p *Parser) parseTag(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
+	if p.prevTagSize != 0 {
+		binary.BigEndian.PutUint32(b[:4], p.prevTagSize)
+		p.prevTagSize = 0
+	}
 
 	tagType := uint8(b[4])
 	length := uint32(b[5])<<16 | uint32(b[6])<<8 | uint32(b[7])
 	timeStamp := uint32(b[8])<<16 | uint32(b[9])<<8 | uint32(b[10]) | uint32(b[11])<<24
+	if p.segment.enabled() {
+		setTimestamp(b[4:], p.segment.relative(timeStamp))
+	}
 
 	switch tagType {
 	case audioTag:
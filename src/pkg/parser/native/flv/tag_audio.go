@@ -69,13 +69,12 @@ func (p *Parser) parseAudioTag(ctx context.Context, length, timestamp uint32) (*
 		tag.AACPacketType = AACPacketType(b)
 	}
 
-	// write tag header && audio tag header & AACPacketType
-	if err := p.doWrite(ctx, p.i.AllBytes()); err != nil {
-		return nil, err
+	// write tag header && audio tag header & AACPacketType & body
+	var cache *[]byte
+	if p.segment.enabled() && tag.SoundFormat == AAC && tag.AACPacketType == AACSeqHeader {
+		cache = &p.segment.audioSeqHeader
 	}
-	p.i.Reset()
-	// write body
-	if err := p.doCopy(ctx, l); err != nil {
+	if err := p.writeTag(ctx, l, cache); err != nil {
 		return nil, err
 	}
 
@@ -20,15 +20,40 @@ const (
 )
 
 func (p *Parser) parseScriptTag(ctx context.Context, length uint32) error {
+	if p.writeKeyframeIndex && !p.metadataChecked {
+		p.metadataChecked = true
+		return p.parseMetadataTag(ctx, length)
+	}
 	// TODO: parse script tag content
-	// write tag header
-	if err := p.doWrite(ctx, p.i.AllBytes()); err != nil {
+	// write tag header & body, the first one is kept as the metadata of the new segments
+	var cache *[]byte
+	if p.segment.enabled() && p.segment.metadata == nil {
+		cache = &p.segment.metadata
+	}
+	return p.writeTag(ctx, length, cache)
+}
+
+// parseMetadataTag writes the first script tag with room reserved for the keyframe index.
+func (p *Parser) parseMetadataTag(ctx context.Context, length uint32) error {
+	body, err := p.reserveMetadata(length)
+	if err != nil && err != errNotMetadata {
+		return err
+	}
+	// tag header with the new DataSize
+	header := p.i.AllBytes()
+	size := uint32(len(body))
+	header[5], header[6], header[7] = byte(size>>16), byte(size>>8), byte(size)
+	offset := int64(p.o.Count()) + int64(len(header))
+	if err := p.doWrite(ctx, header); err != nil {
 		return err
 	}
 	p.i.Reset()
-	// write body
-	if err := p.doCopy(ctx, length); err != nil {
+	if err := p.doWrite(ctx, body); err != nil {
 		return err
 	}
+	if p.metadata != nil {
+		p.metadata.offset = offset
+	}
+	p.prevTagSize = size + 11
 	return nil
 }
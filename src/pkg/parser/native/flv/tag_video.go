@@ -3,6 +3,9 @@ package flv
 import (
 	"context"
 	"errors"
+	"time"
+
+	"github.com/hr3lxphr6j/bililive-go/src/instance"
 )
 
 type (
@@ -77,13 +80,36 @@ func (p *Parser) parseVideoTag(ctx context.Context, length, timestamp uint32) (*
 		}
 	}
 
-	// write tag header && video tag header & AVCPacketType & CompositionTime
-	if err := p.doWrite(ctx, p.i.AllBytes()); err != nil {
-		return nil, err
+	isSeqHeader := tag.CodeID == AVCCode && tag.AVCPacketType == AVCSeqHeader
+	if !isSeqHeader {
+		if d := p.discontinuities.feed(timestamp, time.Now()); d != nil {
+			instance.GetInstance(ctx).Logger.Warnf("stream discontinuity detected, pts gap: %s", d.PTSGap)
+			if fn := p.discontinuities.handler(); fn != nil {
+				fn(*d)
+			}
+		}
+	}
+
+	if tag.FrameType == KeyFrame && !isSeqHeader && p.segment.shouldSplit(timestamp) {
+		if err := p.newSegment(ctx, timestamp); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.writeKeyframeIndex && tag.FrameType == KeyFrame && !isSeqHeader {
+		p.keyframes = append(p.keyframes, keyframe{
+			time: float64(p.segment.relative(timestamp)) / 1000,
+			// skip the PreviousTagSize
+			position: int64(p.o.Count()) + 4,
+		})
+	}
+
+	// write tag header && video tag header & AVCPacketType & CompositionTime & body
+	var cache *[]byte
+	if p.segment.enabled() && isSeqHeader {
+		cache = &p.segment.videoSeqHeader
 	}
-	p.i.Reset()
-	// write body
-	if err := p.doCopy(ctx, l); err != nil {
+	if err := p.writeTag(ctx, l, cache); err != nil {
 		return nil, err
 	}
 
@@ -5,6 +5,7 @@ import (
 	"context"
 	"errors"
 	"net/url"
+	"time"
 
 	"github.com/hr3lxphr6j/bililive-go/src/live"
 )
@@ -23,6 +24,35 @@ type StatusParser interface {
 	Status() (map[string]string, error)
 }
 
+// Discontinuity is a gap of the stream timestamps, which usually happens
+// when the stream switches cdn or the upstream server restarts.
+type Discontinuity struct {
+	Timestamp time.Time
+	PTSGap    time.Duration
+}
+
+// DiscontinuityParser is a parser which can detect the discontinuities of the stream.
+type DiscontinuityParser interface {
+	Parser
+	OnDiscontinuity(fn func(Discontinuity))
+	Discontinuities() []Discontinuity
+	DiscontinuityCount() int
+}
+
+// SegmentParser is a parser which splits the output into several files by itself.
+type SegmentParser interface {
+	Parser
+	OnNewSegment(fn func(file string))
+}
+
+// HashParser is a parser which hashes the output file while writing it.
+type HashParser interface {
+	Parser
+	// MD5 returns the file being written and the md5 of the content written to it,
+	// the sum is empty when it's unknown, e.g. the content is modified in place.
+	MD5() (file, sum string)
+}
+
 var m = make(map[string]Builder)
 
 func Register(name string, b Builder) {
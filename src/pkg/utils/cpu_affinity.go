@@ -0,0 +1,5 @@
+package utils
+
+import "errors"
+
+var ErrCPUAffinityNotSupported = errors.New("cpu affinity is only supported on linux")
@@ -0,0 +1,20 @@
+package utils
+
+import (
+	"os/exec"
+)
+
+// ApplyCPUAffinity makes the command run on the cpus in mask (e.g. "0-3,6") by taskset.
+// It must be called before the command is started.
+func ApplyCPUAffinity(cmd *exec.Cmd, mask string) error {
+	if mask == "" {
+		return nil
+	}
+	taskset, err := exec.LookPath("taskset")
+	if err != nil {
+		return err
+	}
+	cmd.Args = append([]string{taskset, "-c", mask}, cmd.Args...)
+	cmd.Path = taskset
+	return nil
+}
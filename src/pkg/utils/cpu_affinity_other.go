@@ -0,0 +1,15 @@
+//go:build !linux
+
+package utils
+
+import (
+	"os/exec"
+)
+
+// ApplyCPUAffinity is not supported on this platform, the command is left untouched.
+func ApplyCPUAffinity(cmd *exec.Cmd, mask string) error {
+	if mask == "" {
+		return nil
+	}
+	return ErrCPUAffinityNotSupported
+}
@@ -0,0 +1,26 @@
+package utils
+
+import (
+	"os/exec"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyCPUAffinity(t *testing.T) {
+	cmd := exec.Command("/usr/bin/ffmpeg", "-i", "input")
+	assert.NoError(t, ApplyCPUAffinity(cmd, ""))
+	assert.Equal(t, []string{"/usr/bin/ffmpeg", "-i", "input"}, cmd.Args)
+
+	err := ApplyCPUAffinity(cmd, "0-1")
+	if runtime.GOOS != "linux" {
+		assert.Equal(t, ErrCPUAffinityNotSupported, err)
+		assert.Equal(t, []string{"/usr/bin/ffmpeg", "-i", "input"}, cmd.Args)
+		return
+	}
+	if err != nil {
+		t.Skip("taskset not found")
+	}
+	assert.Equal(t, []string{cmd.Path, "-c", "0-1", "/usr/bin/ffmpeg", "-i", "input"}, cmd.Args)
+}
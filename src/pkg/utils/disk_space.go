@@ -0,0 +1,143 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/hr3lxphr6j/bililive-go/src/instance"
+	"github.com/hr3lxphr6j/bililive-go/src/pkg/events"
+)
+
+const (
+	DiskFull           events.EventType = "DiskFull"
+	DiskSpaceRecovered events.EventType = "DiskSpaceRecovered"
+
+	diskSpaceCheckInterval = 30 * time.Second
+	mb                     = 1024 * 1024
+)
+
+var ErrDiskSpaceNotSupported = errors.New("disk space is not supported on this platform")
+
+// for test
+var statfs = getDiskSpace
+
+// DiskSpace is the usage of the disk of the out put path, it's the object of the disk space events.
+type DiskSpace struct {
+	Path                string `json:"path"`
+	FreeBytes           uint64 `json:"free_bytes"`
+	TotalBytes          uint64 `json:"total_bytes"`
+	CriticalFreeSpaceMB int64  `json:"critical_free_space_mb"`
+	RecoveryFreeSpaceMB int64  `json:"recovery_free_space_mb"`
+	Paused              bool   `json:"paused"`
+}
+
+// DiskSpaceManager polls the free space of the out put path, dispatches DiskFull when it's
+// below the critical threshold, and DiskSpaceRecovered when it's back above the recovery one.
+type DiskSpaceManager struct {
+	lock      sync.Mutex
+	paused    bool
+	stop      chan struct{}
+	closeOnce sync.Once
+}
+
+func NewDiskSpaceManager(ctx context.Context) *DiskSpaceManager {
+	m := &DiskSpaceManager{stop: make(chan struct{})}
+	instance.GetInstance(ctx).DiskSpaceManager = m
+	return m
+}
+
+func (m *DiskSpaceManager) Start(ctx context.Context) error {
+	if instance.GetInstance(ctx).Config.DiskSpace.CriticalFreeSpaceMB <= 0 {
+		return nil
+	}
+	m.check(ctx)
+	go func() {
+		ticker := time.NewTicker(diskSpaceCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-m.stop:
+				return
+			case <-ticker.C:
+				m.check(ctx)
+			}
+		}
+	}()
+	return nil
+}
+
+func (m *DiskSpaceManager) Close(ctx context.Context) {
+	m.closeOnce.Do(func() {
+		close(m.stop)
+	})
+}
+
+// Status returns the current usage of the disk.
+func (m *DiskSpaceManager) Status(ctx context.Context) (DiskSpace, error) {
+	cfg := instance.GetInstance(ctx).Config
+	space := DiskSpace{
+		Path:                cfg.OutPutPath,
+		CriticalFreeSpaceMB: cfg.DiskSpace.CriticalFreeSpaceMB,
+		RecoveryFreeSpaceMB: cfg.DiskSpace.RecoveryFreeSpaceMB,
+	}
+	if space.RecoveryFreeSpaceMB < space.CriticalFreeSpaceMB {
+		space.RecoveryFreeSpaceMB = space.CriticalFreeSpaceMB
+	}
+	m.lock.Lock()
+	space.Paused = m.paused
+	m.lock.Unlock()
+	var err error
+	space.FreeBytes, space.TotalBytes, err = statfs(space.Path)
+	return space, err
+}
+
+// Pause pauses all the recorders as the disk is full, e.g. the recording failed to be written, they're
+// resumed once the free space is above the recovery threshold, which needs critical_free_space_mb.
+func (m *DiskSpaceManager) Pause(ctx context.Context) {
+	inst := instance.GetInstance(ctx)
+	m.lock.Lock()
+	if m.paused {
+		m.lock.Unlock()
+		return
+	}
+	m.paused = true
+	m.lock.Unlock()
+	space, err := m.Status(ctx)
+	if err != nil {
+		inst.Logger.WithError(err).Warn("failed to get the free space of the out put path")
+	}
+	inst.Logger.Errorf("failed to write to %s, all the recorders are paused", space.Path)
+	if ed, ok := inst.EventDispatcher.(events.Dispatcher); ok {
+		ed.DispatchEvent(events.NewEvent(DiskFull, space))
+	}
+}
+
+func (m *DiskSpaceManager) check(ctx context.Context) {
+	inst := instance.GetInstance(ctx)
+	space, err := m.Status(ctx)
+	if err != nil {
+		inst.Logger.WithError(err).Warn("failed to get the free space of the out put path")
+		return
+	}
+	m.lock.Lock()
+	var evtType events.EventType
+	switch {
+	case !m.paused && space.FreeBytes < uint64(space.CriticalFreeSpaceMB)*mb:
+		m.paused, evtType = true, DiskFull
+		inst.Logger.Errorf("free space of %s is %d MB, below %d MB, all the recorders are paused",
+			space.Path, space.FreeBytes/mb, space.CriticalFreeSpaceMB)
+	case m.paused && space.FreeBytes >= uint64(space.RecoveryFreeSpaceMB)*mb:
+		m.paused, evtType = false, DiskSpaceRecovered
+		inst.Logger.Infof("free space of %s is recovered to %d MB, the recorders are resumed", space.Path, space.FreeBytes/mb)
+	}
+	space.Paused = m.paused
+	m.lock.Unlock()
+	if evtType == "" {
+		return
+	}
+	if ed, ok := inst.EventDispatcher.(events.Dispatcher); ok {
+		ed.DispatchEvent(events.NewEvent(evtType, space))
+	}
+}
@@ -0,0 +1,68 @@
+package utils
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hr3lxphr6j/bililive-go/src/configs"
+	"github.com/hr3lxphr6j/bililive-go/src/instance"
+	"github.com/hr3lxphr6j/bililive-go/src/interfaces"
+	"github.com/hr3lxphr6j/bililive-go/src/pkg/events"
+)
+
+func TestDiskSpaceManager(t *testing.T) {
+	free := uint64(2048 * mb)
+	backup := statfs
+	statfs = func(path string) (uint64, uint64, error) { return free, 4096 * mb, nil }
+	defer func() { statfs = backup }()
+
+	config := configs.NewConfig()
+	config.DiskSpace = configs.DiskSpace{CriticalFreeSpaceMB: 1024, RecoveryFreeSpaceMB: 1536}
+	inst := &instance.Instance{
+		Config: config,
+		Logger: &interfaces.Logger{Logger: logrus.New()},
+	}
+	ctx := context.WithValue(context.Background(), instance.Key, inst)
+	ed := events.NewDispatcher(ctx)
+	received := make(chan *events.Event, 4)
+	handler := events.NewEventListener(func(event *events.Event) { received <- event })
+	ed.AddEventListener(DiskFull, handler)
+	ed.AddEventListener(DiskSpaceRecovered, handler)
+	m := NewDiskSpaceManager(ctx)
+	assert.Equal(t, m, inst.DiskSpaceManager)
+
+	m.check(ctx)
+	assert.Len(t, received, 0)
+
+	free = 512 * mb
+	m.check(ctx)
+	e := <-received
+	assert.Equal(t, DiskFull, e.Type)
+	assert.True(t, e.Object.(DiskSpace).Paused)
+	m.check(ctx)
+	assert.Len(t, received, 0)
+
+	// between the thresholds, still paused
+	free = 1200 * mb
+	m.check(ctx)
+	assert.Len(t, received, 0)
+	space, err := m.Status(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, DiskSpace{
+		Path:                config.OutPutPath,
+		FreeBytes:           1200 * mb,
+		TotalBytes:          4096 * mb,
+		CriticalFreeSpaceMB: 1024,
+		RecoveryFreeSpaceMB: 1536,
+		Paused:              true,
+	}, space)
+
+	free = 1536 * mb
+	m.check(ctx)
+	e = <-received
+	assert.Equal(t, DiskSpaceRecovered, e.Type)
+	assert.False(t, e.Object.(DiskSpace).Paused)
+}
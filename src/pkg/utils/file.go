@@ -0,0 +1,96 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"os"
+)
+
+var ErrChecksumMismatch = errors.New("checksum of the copied file mismatch")
+
+// for test
+var (
+	rename   = os.Rename
+	copyFile = func(src, dst string) error {
+		in, err := os.Open(src)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		stat, err := in.Stat()
+		if err != nil {
+			return err
+		}
+		out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, stat.Mode())
+		if err != nil {
+			return err
+		}
+		if _, err = io.Copy(out, in); err != nil {
+			out.Close()
+			return err
+		}
+		if err = out.Sync(); err != nil {
+			out.Close()
+			return err
+		}
+		return out.Close()
+	}
+)
+
+// SafeRename moves src to dst, when they are on different filesystems,
+// it falls back to copy then delete, the source is only deleted after
+// the sha256 of the copy is verified.
+func SafeRename(src, dst string) error {
+	err := rename(src, dst)
+	if err == nil || !isCrossDeviceError(err) {
+		return err
+	}
+	if err := copyFile(src, dst); err != nil {
+		os.Remove(dst)
+		return err
+	}
+	srcSum, err := fileSha256(src)
+	if err != nil {
+		os.Remove(dst)
+		return err
+	}
+	dstSum, err := fileSha256(dst)
+	if err != nil {
+		os.Remove(dst)
+		return err
+	}
+	if !bytes.Equal(srcSum, dstSum) {
+		os.Remove(dst)
+		return ErrChecksumMismatch
+	}
+	return os.Remove(src)
+}
+
+// CopyFile copies src into a temp file next to dst and moves it to dst, so that dst is never partial.
+func CopyFile(src, dst string) error {
+	tmp := dst + ".tmp"
+	if err := copyFile(src, tmp); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := SafeRename(tmp, dst); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+func fileSha256(file string) ([]byte, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
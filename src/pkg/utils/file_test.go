@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTempDirs(t *testing.T) (string, string) {
+	srcDir, err := ioutil.TempDir("", "safe-rename-src")
+	assert.NoError(t, err)
+	dstDir, err := ioutil.TempDir("", "safe-rename-dst")
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		os.RemoveAll(srcDir)
+		os.RemoveAll(dstDir)
+	})
+	return srcDir, dstDir
+}
+
+func mockCrossDeviceRename(t *testing.T) {
+	backup := rename
+	rename = func(src, dst string) error {
+		return &os.LinkError{Op: "rename", Old: src, New: dst, Err: syscall.EXDEV}
+	}
+	t.Cleanup(func() { rename = backup })
+}
+
+func TestSafeRename(t *testing.T) {
+	srcDir, dstDir := newTempDirs(t)
+	src, dst := filepath.Join(srcDir, "a.flv"), filepath.Join(dstDir, "a.flv")
+	assert.NoError(t, ioutil.WriteFile(src, []byte("foobar"), 0644))
+	assert.NoError(t, SafeRename(src, dst))
+	b, err := ioutil.ReadFile(dst)
+	assert.NoError(t, err)
+	assert.Equal(t, "foobar", string(b))
+	_, err = os.Stat(src)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestSafeRenameCrossDevice(t *testing.T) {
+	mockCrossDeviceRename(t)
+	srcDir, dstDir := newTempDirs(t)
+	src, dst := filepath.Join(srcDir, "a.flv"), filepath.Join(dstDir, "a.flv")
+	assert.NoError(t, ioutil.WriteFile(src, []byte("foobar"), 0644))
+	assert.NoError(t, SafeRename(src, dst))
+	b, err := ioutil.ReadFile(dst)
+	assert.NoError(t, err)
+	assert.Equal(t, "foobar", string(b))
+	_, err = os.Stat(src)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestSafeRenameCorruptedCopy(t *testing.T) {
+	mockCrossDeviceRename(t)
+	backup := copyFile
+	copyFile = func(src, dst string) error {
+		return ioutil.WriteFile(dst, []byte("fooba"), 0644)
+	}
+	defer func() { copyFile = backup }()
+	srcDir, dstDir := newTempDirs(t)
+	src, dst := filepath.Join(srcDir, "a.flv"), filepath.Join(dstDir, "a.flv")
+	assert.NoError(t, ioutil.WriteFile(src, []byte("foobar"), 0644))
+	assert.Equal(t, ErrChecksumMismatch, SafeRename(src, dst))
+	_, err := os.Stat(src)
+	assert.NoError(t, err)
+	_, err = os.Stat(dst)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestCopyFile(t *testing.T) {
+	srcDir, dstDir := newTempDirs(t)
+	src, dst := filepath.Join(srcDir, "a.flv"), filepath.Join(dstDir, "a.flv")
+	assert.NoError(t, ioutil.WriteFile(src, []byte("foobar"), 0644))
+	assert.NoError(t, CopyFile(src, dst))
+	b, err := ioutil.ReadFile(dst)
+	assert.NoError(t, err)
+	assert.Equal(t, "foobar", string(b))
+	// the source is kept
+	_, err = os.Stat(src)
+	assert.NoError(t, err)
+	_, err = os.Stat(dst + ".tmp")
+	assert.True(t, os.IsNotExist(err))
+}
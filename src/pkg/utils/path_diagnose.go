@@ -0,0 +1,91 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"syscall"
+)
+
+// for test
+var inContainer = func() bool {
+	_, err := os.Stat("/.dockerenv")
+	return err == nil
+}
+
+// PathDiagnosis is the result of DiagnosePath.
+type PathDiagnosis struct {
+	Name      string `json:"name"`
+	Path      string `json:"path"`
+	Exists    bool   `json:"exists"`
+	Writable  bool   `json:"writable"`
+	Mode      string `json:"mode,omitempty"`
+	OwnerUid  int    `json:"owner_uid"`
+	OwnerGid  int    `json:"owner_gid"`
+	Uid       int    `json:"uid"`
+	Gid       int    `json:"gid"`
+	FreeBytes uint64 `json:"free_bytes"`
+	Error     string `json:"error,omitempty"`
+	Message   string `json:"message,omitempty"`
+}
+
+// DiagnosePath checks whether the directory exists and can be written by doing a test write,
+// the message tells how to fix it when it can not, the uid and gid fields are -1 on the
+// platforms without them.
+func DiagnosePath(name, path string) PathDiagnosis {
+	d := PathDiagnosis{
+		Name:     name,
+		Path:     path,
+		OwnerUid: -1,
+		OwnerGid: -1,
+		Uid:      os.Getuid(),
+		Gid:      os.Getgid(),
+	}
+	stat, err := os.Stat(path)
+	if err != nil {
+		d.Error = err.Error()
+		if os.IsNotExist(err) {
+			d.Message = "the directory does not exist, create it or mount a volume on it"
+		} else {
+			d.Message = "the directory can not be accessed"
+		}
+		return d
+	}
+	d.Exists = true
+	d.Mode = stat.Mode().String()
+	d.OwnerUid, d.OwnerGid = fileOwner(stat)
+	if !stat.IsDir() {
+		d.Message = "the path is not a directory"
+		return d
+	}
+	if free, _, err := statfs(path); err == nil {
+		d.FreeBytes = free
+	}
+	f, err := ioutil.TempFile(path, ".bililive-go-diagnose-*")
+	if err != nil {
+		d.Error = err.Error()
+		d.Message = writeFailureMessage(d, err)
+		return d
+	}
+	f.Close()
+	os.Remove(f.Name())
+	d.Writable = true
+	return d
+}
+
+func writeFailureMessage(d PathDiagnosis, err error) string {
+	switch {
+	case errors.Is(err, syscall.EROFS):
+		return "the file system is mounted read-only"
+	case errors.Is(err, syscall.ENOSPC):
+		return "no space left on the device"
+	case !os.IsPermission(err):
+		return "failed to write a test file into the directory"
+	case inContainer():
+		return fmt.Sprintf("container user (uid %d) lacks write permission on mounted volume owned by uid %d, "+
+			"chown the directory on the host or run the container as that user", d.Uid, d.OwnerUid)
+	default:
+		return fmt.Sprintf("user (uid %d) lacks write permission on the directory owned by uid %d", d.Uid, d.OwnerUid)
+	}
+}
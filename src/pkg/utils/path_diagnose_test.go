@@ -0,0 +1,47 @@
+package utils
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiagnosePath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diagnose")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	d := DiagnosePath("out_put_path", dir)
+	assert.True(t, d.Exists)
+	assert.True(t, d.Writable)
+	assert.Empty(t, d.Message)
+	files, _ := ioutil.ReadDir(dir)
+	assert.Empty(t, files, "the test file should be removed")
+
+	d = DiagnosePath("out_put_path", filepath.Join(dir, "not_exist"))
+	assert.False(t, d.Exists)
+	assert.Contains(t, d.Message, "does not exist")
+
+	file := filepath.Join(dir, "file")
+	assert.NoError(t, ioutil.WriteFile(file, nil, 0644))
+	d = DiagnosePath("out_put_path", file)
+	assert.False(t, d.Writable)
+	assert.Equal(t, "the path is not a directory", d.Message)
+
+	if runtime.GOOS == "windows" || os.Getuid() == 0 {
+		return
+	}
+	readOnly := filepath.Join(dir, "read_only")
+	assert.NoError(t, os.Mkdir(readOnly, 0555))
+	backup := inContainer
+	inContainer = func() bool { return true }
+	defer func() { inContainer = backup }()
+	d = DiagnosePath("out_put_path", readOnly)
+	assert.True(t, d.Exists)
+	assert.False(t, d.Writable)
+	assert.Contains(t, d.Message, "container user")
+}
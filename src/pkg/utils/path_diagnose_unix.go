@@ -0,0 +1,15 @@
+//go:build !windows
+
+package utils
+
+import (
+	"os"
+	"syscall"
+)
+
+func fileOwner(stat os.FileInfo) (uid, gid int) {
+	if st, ok := stat.Sys().(*syscall.Stat_t); ok {
+		return int(st.Uid), int(st.Gid)
+	}
+	return -1, -1
+}
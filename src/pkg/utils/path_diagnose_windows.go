@@ -0,0 +1,9 @@
+//go:build windows
+
+package utils
+
+import "os"
+
+func fileOwner(stat os.FileInfo) (uid, gid int) {
+	return -1, -1
+}
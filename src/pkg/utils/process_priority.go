@@ -0,0 +1,34 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+)
+
+// the io scheduling classes of ioprio_set, 0 means unchanged
+const (
+	IOClassBestEffort = 2
+	IOClassIdle       = 3
+)
+
+var ErrIOPriorityNotSupported = errors.New("io priority is only supported on linux")
+
+// CheckProcessPriority returns an error if the nice value or the io scheduling class is invalid.
+func CheckProcessPriority(nice, ioClass int) error {
+	if nice < -20 || nice > 19 {
+		return fmt.Errorf("invalid nice value: %d, should be in [-20, 19]", nice)
+	}
+	switch ioClass {
+	case 0, IOClassBestEffort, IOClassIdle:
+		return nil
+	default:
+		return fmt.Errorf("invalid io scheduling class: %d, should be %d or %d", ioClass, IOClassBestEffort, IOClassIdle)
+	}
+}
+
+// wrapCommand makes the command run by the wrapper, e.g. nice -n 10 ffmpeg ...
+func wrapCommand(cmd *exec.Cmd, wrapper string, args ...string) {
+	cmd.Args = append(append([]string{wrapper}, args...), cmd.Args...)
+	cmd.Path = wrapper
+}
@@ -0,0 +1,29 @@
+package utils
+
+import (
+	"os/exec"
+	"strconv"
+)
+
+// ApplyProcessPriority makes the command run with the nice value by nice, and in the io
+// scheduling class by ionice, 0 means unchanged. It must be called before the command is started.
+func ApplyProcessPriority(cmd *exec.Cmd, nice, ioClass int) error {
+	if err := CheckProcessPriority(nice, ioClass); err != nil {
+		return err
+	}
+	if ioClass != 0 {
+		ionice, err := exec.LookPath("ionice")
+		if err != nil {
+			return err
+		}
+		wrapCommand(cmd, ionice, "-c", strconv.Itoa(ioClass))
+	}
+	if nice != 0 {
+		nicePath, err := exec.LookPath("nice")
+		if err != nil {
+			return err
+		}
+		wrapCommand(cmd, nicePath, "-n", strconv.Itoa(nice))
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+//go:build !linux && !windows
+
+package utils
+
+import (
+	"os/exec"
+	"strconv"
+)
+
+// ApplyProcessPriority makes the command run with the nice value by nice, 0 means unchanged.
+// The io scheduling class is not supported. It must be called before the command is started.
+func ApplyProcessPriority(cmd *exec.Cmd, nice, ioClass int) error {
+	if err := CheckProcessPriority(nice, ioClass); err != nil {
+		return err
+	}
+	if nice != 0 {
+		nicePath, err := exec.LookPath("nice")
+		if err != nil {
+			return err
+		}
+		wrapCommand(cmd, nicePath, "-n", strconv.Itoa(nice))
+	}
+	if ioClass != 0 {
+		return ErrIOPriorityNotSupported
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"os/exec"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckProcessPriority(t *testing.T) {
+	assert.NoError(t, CheckProcessPriority(0, 0))
+	assert.NoError(t, CheckProcessPriority(19, IOClassIdle))
+	assert.NoError(t, CheckProcessPriority(-20, IOClassBestEffort))
+	assert.Error(t, CheckProcessPriority(20, 0))
+	// the realtime class
+	assert.Error(t, CheckProcessPriority(0, 1))
+}
+
+func TestApplyProcessPriority(t *testing.T) {
+	cmd := exec.Command("/usr/bin/ffmpeg", "-i", "input")
+	assert.NoError(t, ApplyProcessPriority(cmd, 0, 0))
+	assert.Equal(t, []string{"/usr/bin/ffmpeg", "-i", "input"}, cmd.Args)
+	assert.Error(t, ApplyProcessPriority(cmd, 100, 0))
+
+	if runtime.GOOS != "linux" {
+		return
+	}
+	nice, err1 := exec.LookPath("nice")
+	ionice, err2 := exec.LookPath("ionice")
+	if err1 != nil || err2 != nil {
+		t.Skip("nice or ionice not found")
+	}
+	assert.NoError(t, ApplyProcessPriority(cmd, 10, IOClassIdle))
+	assert.Equal(t, []string{nice, "-n", "10", ionice, "-c", "3", "/usr/bin/ffmpeg", "-i", "input"}, cmd.Args)
+	assert.Equal(t, nice, cmd.Path)
+}
@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// the process creation flags of the priority classes
+const (
+	idlePriorityClass        = 0x00000040
+	belowNormalPriorityClass = 0x00004000
+	aboveNormalPriorityClass = 0x00008000
+	highPriorityClass        = 0x00000080
+)
+
+// ApplyProcessPriority makes the command run in the priority class closest to the nice value,
+// 0 means unchanged. The io scheduling class is not supported. It must be called before the command is started.
+func ApplyProcessPriority(cmd *exec.Cmd, nice, ioClass int) error {
+	if err := CheckProcessPriority(nice, ioClass); err != nil {
+		return err
+	}
+	if nice != 0 {
+		if cmd.SysProcAttr == nil {
+			cmd.SysProcAttr = new(syscall.SysProcAttr)
+		}
+		cmd.SysProcAttr.CreationFlags |= priorityClass(nice)
+	}
+	if ioClass != 0 {
+		return ErrIOPriorityNotSupported
+	}
+	return nil
+}
+
+func priorityClass(nice int) uint32 {
+	switch {
+	case nice >= 15:
+		return idlePriorityClass
+	case nice > 0:
+		return belowNormalPriorityClass
+	case nice <= -15:
+		return highPriorityClass
+	default:
+		return aboveNormalPriorityClass
+	}
+}
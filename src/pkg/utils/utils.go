@@ -6,11 +6,13 @@ import (
 	"encoding/hex"
 	"errors"
 	"math/rand"
+	"net/http"
 	"net/url"
 	"os"
 	"os/exec"
 	"regexp"
 	"runtime/debug"
+	"sort"
 	"strings"
 
 	"github.com/hr3lxphr6j/bililive-go/src/instance"
@@ -39,6 +41,42 @@ func IsFFmpegExist(ctx context.Context) bool {
 	return err == nil
 }
 
+// FFmpegHeaderArgs converts the headers into the input options of ffmpeg. User-Agent and Referer
+// have options of their own, the others are joined into a single -headers, as ffmpeg only keeps
+// the last one when -headers is given more than once.
+func FFmpegHeaderArgs(headers map[string]string) []string {
+	var (
+		args    = make([]string, 0, 6)
+		keys    = make([]string, 0, len(headers))
+		ua, ref string
+	)
+	for k, v := range headers {
+		switch http.CanonicalHeaderKey(k) {
+		case "User-Agent":
+			ua = v
+		case "Referer":
+			ref = v
+		default:
+			keys = append(keys, k)
+		}
+	}
+	if ua != "" {
+		args = append(args, "-user_agent", ua)
+	}
+	if ref != "" {
+		args = append(args, "-referer", ref)
+	}
+	if len(keys) == 0 {
+		return args
+	}
+	sort.Strings(keys)
+	buf := new(strings.Builder)
+	for _, k := range keys {
+		buf.WriteString(k + ": " + headers[k] + "\r\n")
+	}
+	return append(args, "-headers", buf.String())
+}
+
 func GetMd5String(b []byte) string {
 	md5Obj := md5.New()
 	md5Obj.Write(b)
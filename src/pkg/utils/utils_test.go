@@ -0,0 +1,21 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFFmpegHeaderArgs(t *testing.T) {
+	assert.Equal(t, []string{}, FFmpegHeaderArgs(nil))
+	assert.Equal(t, []string{
+		"-user_agent", "test",
+		"-referer", "https://example.com",
+		"-headers", "Cookie: a=b\r\nOrigin: https://example.com\r\n",
+	}, FFmpegHeaderArgs(map[string]string{
+		"referer":    "https://example.com",
+		"User-Agent": "test",
+		"Origin":     "https://example.com",
+		"Cookie":     "a=b",
+	}))
+}
@@ -0,0 +1,166 @@
+package recorders
+
+import (
+	"bytes"
+	"crypto/md5"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/hr3lxphr6j/bililive-go/src/configs"
+	"github.com/hr3lxphr6j/bililive-go/src/pkg/events"
+)
+
+// the files with the same size and the same md5 of the head are compared entirely
+const dedupHeadSize = 1024 * 1024
+
+// Reasons of RecordFileDedupedParam.
+const (
+	DedupReasonEmpty     = "empty"
+	DedupReasonTiny      = "tiny"
+	DedupReasonDuplicate = "duplicate"
+)
+
+// sessionFile is a finished file of the session, kept to find the duplicates of the later ones.
+type sessionFile struct {
+	file string
+	size int64
+	head [md5.Size]byte
+}
+
+// sessionFiles are the finished files of a recorder, which lasts for a recording session.
+type sessionFiles struct {
+	sync.Mutex
+	files []sessionFile
+}
+
+func headMD5(file string) ([md5.Size]byte, error) {
+	var sum [md5.Size]byte
+	f, err := os.Open(file)
+	if err != nil {
+		return sum, err
+	}
+	defer f.Close()
+	h := md5.New()
+	if _, err := io.CopyN(h, f, dedupHeadSize); err != nil && err != io.EOF {
+		return sum, err
+	}
+	copy(sum[:], h.Sum(nil))
+	return sum, nil
+}
+
+// sameContent compares the files byte by byte.
+func sameContent(a, b string) (bool, error) {
+	fa, err := os.Open(a)
+	if err != nil {
+		return false, err
+	}
+	defer fa.Close()
+	fb, err := os.Open(b)
+	if err != nil {
+		return false, err
+	}
+	defer fb.Close()
+	bufA, bufB := make([]byte, 32*1024), make([]byte, 32*1024)
+	for {
+		na, errA := io.ReadFull(fa, bufA)
+		nb, errB := io.ReadFull(fb, bufB)
+		if na != nb || !bytes.Equal(bufA[:na], bufB[:nb]) {
+			return false, nil
+		}
+		if errA == io.EOF || errA == io.ErrUnexpectedEOF {
+			return errB == io.EOF || errB == io.ErrUnexpectedEOF, nil
+		}
+		if errA != nil {
+			return false, errA
+		}
+		if errB != nil {
+			return false, errB
+		}
+	}
+}
+
+// findDuplicate returns the earlier file of the session with the same content, and remembers
+// the file otherwise. The earlier files which are gone, e.g. converted to mp4, are forgotten.
+func (s *sessionFiles) findDuplicate(file string, size int64) (string, error) {
+	head, err := headMD5(file)
+	if err != nil {
+		return "", err
+	}
+	s.Lock()
+	defer s.Unlock()
+	kept := s.files[:0]
+	original := ""
+	for _, f := range s.files {
+		if stat, err := os.Stat(f.file); err != nil || stat.Size() != f.size {
+			continue
+		}
+		kept = append(kept, f)
+		if original != "" || f.size != size || f.head != head {
+			continue
+		}
+		if same, err := sameContent(f.file, file); err == nil && same {
+			original = f.file
+		}
+	}
+	s.files = kept
+	if original == "" {
+		s.files = append(s.files, sessionFile{file: file, size: size, head: head})
+	}
+	return original, nil
+}
+
+// dedupFile cleans up the file when it's empty, tiny or a duplicate of an earlier file of the session,
+// it returns true when the file is cleaned up and the rest of the post processing should be skipped.
+func (r *recorder) dedupFile(file string) bool {
+	cfg := r.config.OnRecordFinished.Dedup
+	stat, err := os.Stat(file)
+	if err != nil {
+		return os.IsNotExist(err)
+	}
+	param := RecordFileDedupedParam{Live: r.Live, File: file, Action: configs.DedupActionRemove}
+	switch {
+	case stat.Size() == 0:
+		param.Reason = DedupReasonEmpty
+	case stat.Size() < int64(cfg.MinSizeKB)*1024:
+		param.Reason = DedupReasonTiny
+	default:
+		original, err := r.sessionFiles.findDuplicate(file, stat.Size())
+		if err != nil {
+			r.getLogger().WithError(err).Warnf("failed to check whether %s is a duplicate", file)
+			return false
+		}
+		if original == "" {
+			return false
+		}
+		param.Reason, param.Original = DedupReasonDuplicate, original
+		if cfg.Action == configs.DedupActionHardlink {
+			param.Action = configs.DedupActionHardlink
+		}
+	}
+	if param.Action == configs.DedupActionHardlink {
+		// linked to a temp file first, so the duplicate is never lost if it fails
+		if err := os.Link(param.Original, file+".link"); err != nil {
+			r.getLogger().WithError(err).Warnf("failed to hard link %s to %s", file, param.Original)
+			return false
+		}
+		if err := os.Rename(file+".link", file); err != nil {
+			os.Remove(file + ".link")
+			r.getLogger().WithError(err).Warnf("failed to hard link %s to %s", file, param.Original)
+			return false
+		}
+		r.getLogger().Infof("%s is a duplicate of %s, replaced with a hard link", file, param.Original)
+	} else {
+		if err := os.Remove(file); err != nil {
+			r.getLogger().WithError(err).Warnf("failed to remove the %s fragment %s", param.Reason, file)
+			return false
+		}
+		if param.Original != "" {
+			r.getLogger().Infof("%s is a duplicate of %s, removed", file, param.Original)
+		} else {
+			r.getLogger().Infof("removed the %s fragment %s", param.Reason, file)
+		}
+	}
+	r.ed.DispatchEvent(events.NewEvent(RecordFileDeduped, param))
+	return true
+}
@@ -0,0 +1,148 @@
+package recorders
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bluele/gcache"
+	"github.com/golang/mock/gomock"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hr3lxphr6j/bililive-go/src/configs"
+	"github.com/hr3lxphr6j/bililive-go/src/interfaces"
+	"github.com/hr3lxphr6j/bililive-go/src/live/mock"
+	"github.com/hr3lxphr6j/bililive-go/src/pkg/events"
+	evtmock "github.com/hr3lxphr6j/bililive-go/src/pkg/events/mock"
+)
+
+func TestSameContent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dedup")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	data := bytes.Repeat([]byte("0123456789"), 10000)
+	write := func(name string, b []byte) string {
+		file := filepath.Join(dir, name)
+		assert.NoError(t, ioutil.WriteFile(file, b, 0644))
+		return file
+	}
+	a, b := write("a.flv", data), write("b.flv", data)
+	changed := append([]byte{}, data...)
+	changed[len(changed)-1] = 'x'
+	c := write("c.flv", changed)
+	d := write("d.flv", data[:len(data)-1])
+
+	same, err := sameContent(a, b)
+	assert.NoError(t, err)
+	assert.True(t, same)
+	same, err = sameContent(a, c)
+	assert.NoError(t, err)
+	assert.False(t, same)
+	same, err = sameContent(a, d)
+	assert.NoError(t, err)
+	assert.False(t, same)
+}
+
+func TestDedupFile(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	dir, err := ioutil.TempDir("", "dedup")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	l := mock.NewMockLive(ctrl)
+	ed := evtmock.NewMockDispatcher(ctrl)
+	cfg := configs.NewConfig()
+	cfg.OnRecordFinished.Dedup = configs.FragmentDedup{Enable: true, MinSizeKB: 1}
+	r := &recorder{
+		Live:   l,
+		config: cfg,
+		ed:     ed,
+		cache:  gcache.New(4).LRU().Build(),
+		logger: &interfaces.Logger{Logger: logrus.New()},
+	}
+	var params []RecordFileDedupedParam
+	ed.EXPECT().DispatchEvent(gomock.Any()).Do(func(e *events.Event) {
+		assert.Equal(t, RecordFileDeduped, e.Type)
+		params = append(params, e.Object.(RecordFileDedupedParam))
+	}).AnyTimes()
+	write := func(name string, b []byte) string {
+		file := filepath.Join(dir, name)
+		assert.NoError(t, ioutil.WriteFile(file, b, 0644))
+		return file
+	}
+	exists := func(file string) bool {
+		_, err := os.Stat(file)
+		return err == nil
+	}
+	data := bytes.Repeat([]byte("flv"), 1000)
+
+	empty := write("empty.flv", nil)
+	assert.True(t, r.dedupFile(empty))
+	assert.False(t, exists(empty))
+	tiny := write("tiny.flv", []byte("FLV"))
+	assert.True(t, r.dedupFile(tiny))
+	assert.False(t, exists(tiny))
+
+	first := write("1.flv", data)
+	assert.False(t, r.dedupFile(first))
+	// the same size and head, but differs at the end
+	changed := append([]byte{}, data...)
+	changed[len(changed)-1] = 'x'
+	second := write("2.flv", changed)
+	assert.False(t, r.dedupFile(second))
+	third := write("3.flv", data)
+	assert.True(t, r.dedupFile(third))
+	assert.False(t, exists(third))
+	assert.True(t, exists(first))
+	assert.True(t, exists(second))
+
+	cfg.OnRecordFinished.Dedup.Action = configs.DedupActionHardlink
+	fourth := write("4.flv", changed)
+	assert.True(t, r.dedupFile(fourth))
+	s1, _ := os.Stat(second)
+	s2, _ := os.Stat(fourth)
+	assert.True(t, os.SameFile(s1, s2))
+
+	assert.Equal(t, []RecordFileDedupedParam{
+		{Live: l, File: empty, Reason: DedupReasonEmpty, Action: configs.DedupActionRemove},
+		{Live: l, File: tiny, Reason: DedupReasonTiny, Action: configs.DedupActionRemove},
+		{Live: l, File: third, Reason: DedupReasonDuplicate, Action: configs.DedupActionRemove, Original: first},
+		{Live: l, File: fourth, Reason: DedupReasonDuplicate, Action: configs.DedupActionHardlink, Original: second},
+	}, params)
+}
+
+func TestFinishFileWithPostProcessingDisabled(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	dir, err := ioutil.TempDir("", "dedup")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	l := mock.NewMockLive(ctrl)
+	l.EXPECT().GetRawUrl().Return("https://live.bilibili.com/1").AnyTimes()
+	cfg := configs.NewConfig()
+	cfg.OnRecordFinished.Dedup = configs.FragmentDedup{Enable: true, MinSizeKB: 1}
+	cfg.OnRecordFinished.VerifyRecording = true
+	cfg.LiveRooms = []configs.LiveRoom{{Url: "https://live.bilibili.com/1", DisablePostProcessing: true}}
+	cfg.RefreshLiveRoomIndexCache()
+	// no event is expected, neither deduped nor suspect
+	r := &recorder{
+		Live:   l,
+		config: cfg,
+		ed:     evtmock.NewMockDispatcher(ctrl),
+		cache:  gcache.New(4).LRU().Build(),
+		logger: &interfaces.Logger{Logger: logrus.New()},
+	}
+	// a tiny fragment, which would be removed otherwise
+	file := filepath.Join(dir, "tiny.flv")
+	assert.NoError(t, ioutil.WriteFile(file, []byte("FLV"), 0644))
+	r.finishFile(context.Background(), file, time.Now())
+	_, err = os.Stat(file)
+	assert.NoError(t, err)
+}
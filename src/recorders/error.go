@@ -6,4 +6,8 @@ var (
 	ErrRecorderExist          = errors.New("recorder is exist")
 	ErrRecorderNotExist       = errors.New("recorder is not exist")
 	ErrParserNotSupportStatus = errors.New("parser not support get status")
+	ErrStreamUrlNotFound      = errors.New("stream url not found")
+	ErrNotRegularFile         = errors.New("not a regular file")
+	ErrRecordersPaused        = errors.New("recorders are paused")
+	ErrEncryptedStream        = errors.New("encrypted stream (DRM), cannot record")
 )
@@ -1,9 +1,106 @@
 package recorders
 
-import "github.com/hr3lxphr6j/bililive-go/src/pkg/events"
+import (
+	"net/url"
+
+	"github.com/hr3lxphr6j/bililive-go/src/live"
+	"github.com/hr3lxphr6j/bililive-go/src/pkg/events"
+	"github.com/hr3lxphr6j/bililive-go/src/pkg/parser"
+)
 
 const (
 	RecorderStart events.EventType = "RecorderStart"
 	RecorderStop  events.EventType = "RecorderStop"
 	RecorderRestart  events.EventType = "RecorderRestart"
+
+	RecorderStreamDiscontinuity events.EventType = "RecorderStreamDiscontinuity"
+	RecordFileStarted           events.EventType = "RecordFileStarted"
+	ParserNewSegment            events.EventType = "ParserNewSegment"
+	RecorderStreamUnsupported   events.EventType = "RecorderStreamUnsupported"
+	RecordFileSuspect           events.EventType = "RecordFileSuspect"
+	RecordFileMirrored          events.EventType = "RecordFileMirrored"
+	RecordWriteFailed           events.EventType = "RecordWriteFailed"
+	RecordFileDeduped           events.EventType = "RecordFileDeduped"
+	ParserExited                events.EventType = "ParserExited"
+)
+
+// Reasons of RecorderStopParam, empty means stopped normally.
+const (
+	StopReasonMaxDurationCap = "max_duration_cap" // reached max_recording_duration
+	StopReasonWriteFailed    = "write_failed"     // the disk failed to be written, by the stop policy
 )
+
+// RecorderStopParam is the object of the RecorderStop event, it embeds the live,
+// so that the listeners can still take the object as a live.Live.
+type RecorderStopParam struct {
+	live.Live
+	Reason string
+}
+
+// RecordFileStartedParam is the object of the RecordFileStarted event.
+type RecordFileStartedParam struct {
+	Live      live.Live
+	File      string
+	StreamUrl *url.URL
+}
+
+// StreamDiscontinuityParam is the object of the RecorderStreamDiscontinuity event.
+type StreamDiscontinuityParam struct {
+	Live          live.Live
+	Discontinuity parser.Discontinuity
+}
+
+// StreamUnsupportedParam is the object of the RecorderStreamUnsupported event.
+type StreamUnsupportedParam struct {
+	Live   live.Live
+	Reason string
+}
+
+// NewSegmentParam is the object of the ParserNewSegment event.
+type NewSegmentParam struct {
+	Live         live.Live
+	File         string // the new file
+	FinishedFile string // the file before the new one
+}
+
+// ParserExitedParam is the object of the ParserExited event, the parser exited with the error
+// while the recorder is running, e.g. the connection is lost or ffmpeg failed, it's restarted then.
+type ParserExitedParam struct {
+	Live live.Live
+	Err  error
+}
+
+// RecordFileSuspectParam is the object of the RecordFileSuspect event,
+// the file failed the verification and is likely corrupt.
+type RecordFileSuspectParam struct {
+	Live   live.Live
+	File   string
+	Reason string
+}
+
+// RecordFileMirroredParam is the object of the RecordFileMirrored event, one result for each mirror output path.
+type RecordFileMirroredParam struct {
+	Live    live.Live
+	File    string
+	Results []MirrorResult
+}
+
+// RecordWriteFailedParam is the object of the RecordWriteFailed event, Policy is the
+// write_fail_policy taken, OutputPath is the new out put path of the switch policy.
+type RecordWriteFailedParam struct {
+	Live       live.Live
+	File       string
+	Err        error
+	Policy     string
+	OutputPath string
+}
+
+// RecordFileDedupedParam is the object of the RecordFileDeduped event, the file is cleaned up as a
+// fragment of the session, Original is the earlier file with the same content of a duplicate.
+type RecordFileDedupedParam struct {
+	Live     live.Live
+	File     string
+	Reason   string
+	Action   string
+	Original string
+}
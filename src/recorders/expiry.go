@@ -0,0 +1,73 @@
+package recorders
+
+import (
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/hr3lxphr6j/bililive-go/src/live"
+	"github.com/hr3lxphr6j/bililive-go/src/pkg/parser"
+)
+
+// for test
+var (
+	// the stream is resolved again this long before the url expires
+	streamUrlRefreshLead = time.Minute
+)
+
+// refreshBeforeExpiry resolves the stream again shortly before the url expires, the fresh urls are
+// sent to the returned channel for the next attempt, nothing is sent if the platform doesn't expose
+// the expiry of the url. An open flv connection keeps working after the expiry as the signature is
+// only checked on connect, so the parser is left alone, while the hls parser is stopped to go on with
+// the fresh urls in a new file, as it keeps fetching the playlist with the expiring url.
+func (r *recorder) refreshBeforeExpiry(p parser.Parser, u *url.URL, done <-chan struct{}) <-chan []*url.URL {
+	refreshed := make(chan []*url.URL, 1)
+	expirer, ok := r.Live.(live.StreamUrlExpirer)
+	if !ok {
+		return refreshed
+	}
+	expiry, ok := expirer.GetStreamUrlExpiry(u)
+	if !ok {
+		return refreshed
+	}
+	wait := time.Until(expiry) - streamUrlRefreshLead
+	if wait <= 0 {
+		// about to expire already, resolving again wouldn't help
+		return refreshed
+	}
+	go func() {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-done:
+			return
+		case <-timer.C:
+		}
+		urls, err := r.Live.GetStreamUrls()
+		if err != nil || len(urls) == 0 {
+			r.getLogger().WithError(err).Warn("failed to resolve the stream before the url expires")
+			return
+		}
+		select {
+		case <-done:
+			return
+		default:
+		}
+		refreshed <- urls
+		if !strings.Contains(u.Path, "m3u8") {
+			r.getLogger().Debugf("the stream url expires at %s, the fresh one is kept for reconnecting", expiry.Format(time.RFC3339))
+			return
+		}
+		r.getLogger().Infof("the stream url expires at %s, switch to the fresh one", expiry.Format(time.RFC3339))
+		if err := p.Stop(); err != nil {
+			r.getLogger().WithError(err).Warn("failed to stop the parser of the expiring url")
+		}
+	}()
+	return refreshed
+}
+
+// urlExpired reports whether the url has expired, false when the expiry is unknown.
+func urlExpired(expirer live.StreamUrlExpirer, u *url.URL) bool {
+	expiry, ok := expirer.GetStreamUrlExpiry(u)
+	return ok && !time.Now().Before(expiry)
+}
@@ -0,0 +1,101 @@
+package recorders
+
+import (
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/bluele/gcache"
+	"github.com/golang/mock/gomock"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hr3lxphr6j/bililive-go/src/interfaces"
+	"github.com/hr3lxphr6j/bililive-go/src/live"
+	"github.com/hr3lxphr6j/bililive-go/src/live/mock"
+	"github.com/hr3lxphr6j/bililive-go/src/pkg/parser"
+)
+
+type expiringLive struct {
+	*mock.MockLive
+}
+
+func (l expiringLive) GetStreamUrlExpiry(u *url.URL) (time.Time, bool) {
+	return live.ParseUnixExpiry(u, "expires", 10)
+}
+
+type stopParser struct {
+	parser.Parser
+	stopped chan struct{}
+}
+
+func (p *stopParser) Stop() error {
+	close(p.stopped)
+	return nil
+}
+
+func TestRefreshBeforeExpiry(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	backup := streamUrlRefreshLead
+	defer func() { streamUrlRefreshLead = backup }()
+	// the expiry is in seconds, the url is resolved again within a second
+	streamUrlRefreshLead = 2 * time.Second
+
+	expires := time.Now().Unix() + 3
+	u, _ := url.Parse("https://example.com/live.m3u8?expires=" + strconv.FormatInt(expires, 10))
+	fresh, _ := url.Parse("https://example.com/live.m3u8?expires=" + strconv.FormatInt(expires+3600, 10))
+	l := expiringLive{mock.NewMockLive(ctrl)}
+	l.EXPECT().GetStreamUrls().Return([]*url.URL{fresh}, nil)
+	r := &recorder{
+		Live:   l,
+		cache:  gcache.New(4).LRU().Build(),
+		logger: &interfaces.Logger{Logger: logrus.New()},
+	}
+
+	p := &stopParser{stopped: make(chan struct{})}
+	refreshed := r.refreshBeforeExpiry(p, u, make(chan struct{}))
+	select {
+	case <-p.stopped:
+	case <-time.After(3 * time.Second):
+		t.Fatal("the parser is not stopped before the url expires")
+	}
+	assert.Equal(t, []*url.URL{fresh}, <-refreshed)
+
+	// the flv parser is not stopped, the fresh urls are kept for reconnecting
+	expires = time.Now().Unix() + 3
+	flvUrl, _ := url.Parse("https://example.com/live.flv?expires=" + strconv.FormatInt(expires, 10))
+	freshFlv, _ := url.Parse("https://example.com/live.flv?expires=" + strconv.FormatInt(expires+3600, 10))
+	l.EXPECT().GetStreamUrls().Return([]*url.URL{freshFlv}, nil)
+	p = &stopParser{stopped: make(chan struct{})}
+	refreshed = r.refreshBeforeExpiry(p, flvUrl, make(chan struct{}))
+	select {
+	case urls := <-refreshed:
+		assert.Equal(t, []*url.URL{freshFlv}, urls)
+	case <-time.After(3 * time.Second):
+		t.Fatal("the url is not resolved before it expires")
+	}
+	time.Sleep(10 * time.Millisecond)
+	select {
+	case <-p.stopped:
+		t.Fatal("the flv parser should not be stopped")
+	default:
+	}
+
+	// the fresh urls which expired too are not used
+	assert.False(t, urlExpired(l, freshFlv))
+	expired, _ := url.Parse("https://example.com/live.flv?expires=1")
+	r.refreshedUrls = []*url.URL{expired}
+	l.EXPECT().GetStreamUrls().Return([]*url.URL{freshFlv}, nil)
+	urls, err := r.nextStreamUrls()
+	assert.NoError(t, err)
+	assert.Equal(t, []*url.URL{freshFlv}, urls)
+
+	// the url without expiry is left alone
+	plain, _ := url.Parse("https://example.com/live.flv")
+	p = &stopParser{stopped: make(chan struct{})}
+	refreshed = r.refreshBeforeExpiry(p, plain, make(chan struct{}))
+	time.Sleep(10 * time.Millisecond)
+	assert.Len(t, refreshed, 0)
+}
@@ -0,0 +1,76 @@
+package recorders
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var hlsProbeClient = &http.Client{Timeout: 10 * time.Second}
+
+// isEncryptedHLS reports whether the segments of the hls stream are encrypted,
+// the first variant is checked when the playlist is a master playlist.
+func isEncryptedHLS(u *url.URL, headers map[string]string) (bool, error) {
+	for depth := 0; depth < 2; depth++ {
+		b, err := fetchPlaylist(u, headers)
+		if err != nil {
+			return false, err
+		}
+		encrypted, variant, err := scanPlaylistEncryption(u, b)
+		if err != nil || encrypted || variant == nil {
+			return encrypted, err
+		}
+		u = variant
+	}
+	return false, nil
+}
+
+// scanPlaylistEncryption returns whether the playlist has an EXT-X-KEY whose method is not NONE,
+// and the first variant if it's a master playlist.
+func scanPlaylistEncryption(base *url.URL, b []byte) (encrypted bool, variant *url.URL, err error) {
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	isVariant := false
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "#EXT-X-KEY:") || strings.HasPrefix(line, "#EXT-X-SESSION-KEY:"):
+			for _, attr := range strings.Split(line[strings.Index(line, ":")+1:], ",") {
+				if kv := strings.SplitN(attr, "=", 2); len(kv) == 2 && kv[0] == "METHOD" && kv[1] != "NONE" {
+					return true, nil, nil
+				}
+			}
+		case strings.HasPrefix(line, "#EXT-X-STREAM-INF:"):
+			isVariant = true
+		case line == "" || strings.HasPrefix(line, "#"):
+		case isVariant && variant == nil:
+			if variant, err = base.Parse(line); err != nil {
+				return false, nil, err
+			}
+		}
+	}
+	return false, variant, scanner.Err()
+}
+
+func fetchPlaylist(u *url.URL, headers map[string]string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := hlsProbeClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch %s, status code: %d", u, resp.StatusCode)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
@@ -0,0 +1,85 @@
+package recorders
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/bluele/gcache"
+	"github.com/golang/mock/gomock"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hr3lxphr6j/bililive-go/src/interfaces"
+	"github.com/hr3lxphr6j/bililive-go/src/live/mock"
+	"github.com/hr3lxphr6j/bililive-go/src/pkg/events"
+	evtmock "github.com/hr3lxphr6j/bililive-go/src/pkg/events/mock"
+)
+
+func TestIsEncryptedHLS(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/master.m3u8":
+			fmt.Fprint(w, "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=1000\nencrypted.m3u8\n")
+		case "/encrypted.m3u8":
+			fmt.Fprint(w, "#EXTM3U\n#EXT-X-KEY:METHOD=AES-128,URI=\"key\"\n#EXTINF:2,\n0.ts\n")
+		case "/plain.m3u8":
+			fmt.Fprint(w, "#EXTM3U\n#EXT-X-KEY:METHOD=NONE\n#EXTINF:2,\n0.ts\n")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	for path, expected := range map[string]bool{
+		"/master.m3u8":    true,
+		"/encrypted.m3u8": true,
+		"/plain.m3u8":     false,
+	} {
+		u, _ := url.Parse(server.URL + path)
+		encrypted, err := isEncryptedHLS(u, nil)
+		assert.NoError(t, err, path)
+		assert.Equal(t, expected, encrypted, path)
+	}
+	u, _ := url.Parse(server.URL + "/not_found.m3u8")
+	_, err := isEncryptedHLS(u, nil)
+	assert.Error(t, err)
+}
+
+func TestSkipEncryptedStream(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	encrypted := true
+	backupProbe, backupInterval := probeEncryptedHLS, unsupportedRecheckInterval
+	probeEncryptedHLS = func(*url.URL, map[string]string) (bool, error) { return encrypted, nil }
+	unsupportedRecheckInterval = time.Millisecond
+	defer func() { probeEncryptedHLS, unsupportedRecheckInterval = backupProbe, backupInterval }()
+
+	l := mock.NewMockLive(ctrl)
+	l.EXPECT().GetHeadersForDownloader().Return(nil).AnyTimes()
+	ed := evtmock.NewMockDispatcher(ctrl)
+	r := &recorder{
+		Live:   l,
+		ed:     ed,
+		cache:  gcache.New(4).LRU().Build(),
+		logger: &interfaces.Logger{Logger: logrus.New()},
+		stop:   make(chan struct{}),
+	}
+	u, _ := url.Parse("https://example.com/live.m3u8")
+
+	// notified only once
+	ed.EXPECT().DispatchEvent(gomock.Any()).Do(func(e *events.Event) {
+		assert.Equal(t, RecorderStreamUnsupported, e.Type)
+		assert.Equal(t, StreamUnsupportedParam{Live: l, Reason: ErrEncryptedStream.Error()}, e.Object)
+	})
+	assert.True(t, r.skipEncryptedStream(u))
+	assert.True(t, r.skipEncryptedStream(u))
+	assert.Equal(t, ErrEncryptedStream.Error(), r.UnsupportedReason())
+
+	encrypted = false
+	assert.False(t, r.skipEncryptedStream(u))
+	assert.Empty(t, r.UnsupportedReason())
+}
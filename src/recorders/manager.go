@@ -11,12 +11,17 @@ import (
 	"github.com/hr3lxphr6j/bililive-go/src/listeners"
 	"github.com/hr3lxphr6j/bililive-go/src/live"
 	"github.com/hr3lxphr6j/bililive-go/src/pkg/events"
+	"github.com/hr3lxphr6j/bililive-go/src/pkg/parser"
+	"github.com/hr3lxphr6j/bililive-go/src/pkg/utils"
 )
 
 func NewManager(ctx context.Context) Manager {
 	rm := &manager{
-		savers: make(map[live.ID]Recorder),
-		cfg:    instance.GetInstance(ctx).Config,
+		savers:    make(map[live.ID]Recorder),
+		manual:    make(map[live.ID]bool),
+		pending:   make(map[live.ID]*time.Timer),
+		deadlines: make(map[live.ID]time.Time),
+		cfg:       instance.GetInstance(ctx).Config,
 	}
 	instance.GetInstance(ctx).RecorderManager = rm
 
@@ -28,8 +33,13 @@ type Manager interface {
 	AddRecorder(ctx context.Context, live live.Live) error
 	RemoveRecorder(ctx context.Context, liveId live.ID) error
 	RestartRecorder(ctx context.Context, liveId live.Live) error
+	StartManualRecorder(ctx context.Context, live live.Live) error
 	GetRecorder(ctx context.Context, liveId live.ID) (Recorder, error)
 	HasRecorder(ctx context.Context, liveId live.ID) bool
+	IsReconnecting(ctx context.Context, liveId live.ID) bool
+	PauseAllRecorders(ctx context.Context)
+	ResumeAllRecorders(ctx context.Context)
+	RemainingRecordingDuration(ctx context.Context, liveId live.ID) (time.Duration, bool)
 }
 
 // for test
@@ -38,14 +48,26 @@ var (
 )
 
 type manager struct {
-	lock   sync.RWMutex
-	savers map[live.ID]Recorder
-	cfg    *configs.Config
+	lock    sync.RWMutex
+	savers  map[live.ID]Recorder
+	manual  map[live.ID]bool        // recorders started by user, not stopped by the listener events
+	pending map[live.ID]*time.Timer // recorders waiting for the end grace period before being removed
+	// when the recorders are stopped by max_recording_duration, kept over the restarts
+	deadlines map[live.ID]time.Time
+	cfg       *configs.Config
+
+	paused bool
+	// the lives being recorded when paused, the value is true for the manual ones
+	pausedLives map[live.ID]bool
 }
 
 func (m *manager) registryListener(ctx context.Context, ed events.Dispatcher) {
 	ed.AddEventListener(listeners.LiveStart, events.NewEventListener(func(event *events.Event) {
 		live := event.Object.(live.Live)
+		if m.cancelPendingRemoval(live.GetLiveId()) {
+			// the recorder is still running, it resumes recording once the stream is back
+			return
+		}
 		if err := m.AddRecorder(ctx, live); err != nil {
 			instance.GetInstance(ctx).Logger.Errorf("failed to add recorder, err: %v", err)
 		}
@@ -61,17 +83,111 @@ func (m *manager) registryListener(ctx context.Context, ed events.Dispatcher) {
 		}
 	}))
 
-	removeEvtListener := events.NewEventListener(func(event *events.Event) {
+	ed.AddEventListener(listeners.LiveEnd, events.NewEventListener(func(event *events.Event) {
 		live := event.Object.(live.Live)
-		if !m.HasRecorder(ctx, live.GetLiveId()) {
+		if !m.HasRecorder(ctx, live.GetLiveId()) || m.isManual(live.GetLiveId()) {
+			return
+		}
+		if grace := m.cfg.EndGracePeriod; grace > 0 {
+			m.removeRecorderAfter(ctx, live.GetLiveId(), grace)
 			return
 		}
 		if err := m.RemoveRecorder(ctx, live.GetLiveId()); err != nil {
 			instance.GetInstance(ctx).Logger.Errorf("failed to remove recorder, err: %v", err)
 		}
+	}))
+
+	ed.AddEventListener(utils.DiskFull, events.NewEventListener(func(event *events.Event) {
+		m.PauseAllRecorders(ctx)
+	}))
+
+	ed.AddEventListener(utils.DiskSpaceRecovered, events.NewEventListener(func(event *events.Event) {
+		m.ResumeAllRecorders(ctx)
+	}))
+
+	ed.AddEventListener(RecordWriteFailed, events.NewEventListener(func(event *events.Event) {
+		param := event.Object.(RecordWriteFailedParam)
+		switch param.Policy {
+		case configs.WriteFailPolicyStop:
+			m.stopOnWriteFailure(ctx, param.Live.GetLiveId())
+		case configs.WriteFailPolicyPauseAll:
+			// through the disk space manager, so that they're resumed once the space is recovered
+			if dm, ok := instance.GetInstance(ctx).DiskSpaceManager.(*utils.DiskSpaceManager); ok {
+				dm.Pause(ctx)
+			} else {
+				m.PauseAllRecorders(ctx)
+			}
+		}
+	}))
+
+	ed.AddEventListener(listeners.ListenStop, events.NewEventListener(func(event *events.Event) {
+		live := event.Object.(live.Live)
+		if !m.HasRecorder(ctx, live.GetLiveId()) || m.isManual(live.GetLiveId()) {
+			return
+		}
+		if err := m.RemoveRecorder(ctx, live.GetLiveId()); err != nil {
+			instance.GetInstance(ctx).Logger.Errorf("failed to remove recorder, err: %v", err)
+		}
+	}))
+}
+
+// stopOnWriteFailure removes the recorder which failed to write the disk, by the stop write_fail_policy.
+func (m *manager) stopOnWriteFailure(ctx context.Context, liveId live.ID) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	r, ok := m.savers[liveId]
+	if !ok {
+		return
+	}
+	if rec, ok := r.(*recorder); ok {
+		rec.stopReason = StopReasonWriteFailed
+	}
+	if err := m.removeRecorder(liveId); err != nil {
+		instance.GetInstance(ctx).Logger.Errorf("failed to remove recorder, err: %v", err)
+	}
+}
+
+// removeRecorderAfter keeps the recorder reconnecting during the grace period,
+// and removes it when the live does not start again before the period ends.
+func (m *manager) removeRecorderAfter(ctx context.Context, liveId live.ID, grace time.Duration) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if _, ok := m.pending[liveId]; ok {
+		return
+	}
+	var timer *time.Timer
+	timer = time.AfterFunc(grace, func() {
+		m.lock.Lock()
+		defer m.lock.Unlock()
+		if m.pending[liveId] != timer {
+			return
+		}
+		if err := m.removeRecorder(liveId); err != nil {
+			instance.GetInstance(ctx).Logger.Errorf("failed to remove recorder, err: %v", err)
+		}
 	})
-	ed.AddEventListener(listeners.LiveEnd, removeEvtListener)
-	ed.AddEventListener(listeners.ListenStop, removeEvtListener)
+	m.pending[liveId] = timer
+}
+
+// cancelPendingRemoval returns true if the recorder was waiting to be removed.
+func (m *manager) cancelPendingRemoval(liveId live.ID) bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	timer, ok := m.pending[liveId]
+	if !ok {
+		return false
+	}
+	timer.Stop()
+	delete(m.pending, liveId)
+	return true
+}
+
+// IsReconnecting returns true if the live is ended and the recorder is waiting for it in the grace period.
+func (m *manager) IsReconnecting(ctx context.Context, liveId live.ID) bool {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	_, ok := m.pending[liveId]
+	return ok
 }
 
 func (m *manager) Start(ctx context.Context) error {
@@ -94,9 +210,84 @@ func (m *manager) Close(ctx context.Context) {
 	inst.WaitGroup.Done()
 }
 
+// PauseAllRecorders stops all the recorders, and no recorder can be added until ResumeAllRecorders.
+func (m *manager) PauseAllRecorders(ctx context.Context) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.paused {
+		return
+	}
+	m.paused = true
+	m.pausedLives = make(map[live.ID]bool, len(m.savers))
+	for id := range m.savers {
+		m.pausedLives[id] = m.manual[id]
+	}
+	for id := range m.pausedLives {
+		if err := m.removeRecorder(id); err != nil {
+			instance.GetInstance(ctx).Logger.Errorf("failed to remove recorder, err: %v", err)
+		}
+	}
+}
+
+// ResumeAllRecorders restarts the recorders stopped by PauseAllRecorders, except the ones whose
+// live is ended while paused, and starts the ones of the listened lives which went live while paused,
+// as the listeners don't send LiveStart again for them.
+func (m *manager) ResumeAllRecorders(ctx context.Context) {
+	m.lock.Lock()
+	if !m.paused {
+		m.lock.Unlock()
+		return
+	}
+	m.paused = false
+	pausedLives := m.pausedLives
+	m.pausedLives = nil
+	m.lock.Unlock()
+
+	inst := instance.GetInstance(ctx)
+	for id, manual := range pausedLives {
+		l, ok := inst.Lives[id]
+		if !ok {
+			continue
+		}
+		var err error
+		if manual {
+			err = m.StartManualRecorder(ctx, l)
+		} else if obj, cacheErr := inst.Cache.Get(l); cacheErr == nil && obj.(*live.Info).Status {
+			err = m.AddRecorder(ctx, l)
+		}
+		if err != nil && err != ErrRecorderExist {
+			inst.Logger.Errorf("failed to resume recorder, err: %v", err)
+		}
+	}
+	lm, ok := inst.ListenerManager.(listeners.Manager)
+	if !ok {
+		return
+	}
+	for id, l := range inst.Lives {
+		if _, ok := pausedLives[id]; ok || !lm.HasListener(ctx, id) {
+			continue
+		}
+		if obj, err := inst.Cache.Get(l); err != nil || !obj.(*live.Info).Status {
+			continue
+		}
+		if err := m.AddRecorder(ctx, l); err != nil && err != ErrRecorderExist {
+			inst.Logger.Errorf("failed to start recorder after resuming, err: %v", err)
+		}
+	}
+}
+
 func (m *manager) AddRecorder(ctx context.Context, live live.Live) error {
+	return m.addRecorder(ctx, live, time.Time{})
+}
+
+// addRecorder adds the recorder which is stopped at the deadline,
+// a zero deadline is computed from the max_recording_duration of the room.
+func (m *manager) addRecorder(ctx context.Context, live live.Live, deadline time.Time) error {
 	m.lock.Lock()
 	defer m.lock.Unlock()
+	if m.paused {
+		return ErrRecordersPaused
+	}
 	if _, ok := m.savers[live.GetLiveId()]; ok {
 		return ErrRecorderExist
 	}
@@ -106,18 +297,55 @@ func (m *manager) AddRecorder(ctx context.Context, live live.Live) error {
 	}
 	m.savers[live.GetLiveId()] = recorder
 
+	if maxRec := m.cfg.GetMaxRecordingDuration(live.GetRawUrl()); deadline.IsZero() && maxRec > 0 {
+		deadline = time.Now().Add(maxRec)
+	}
+	if !deadline.IsZero() {
+		m.deadlines[live.GetLiveId()] = deadline
+		time.AfterFunc(time.Until(deadline), func() {
+			m.stopAtDeadline(ctx, live, recorder)
+		})
+	}
 	if maxDur := m.cfg.VideoSplitStrategies.MaxDuration; maxDur != 0 {
 		go m.cronRestart(ctx, live)
 	}
 	return recorder.Start(ctx)
 }
 
+// StartManualRecorder starts recording the live immediately, regardless of whether
+// it is listened or detected as living. The recorder is only stopped by RemoveRecorder.
+func (m *manager) StartManualRecorder(ctx context.Context, live live.Live) error {
+	if m.HasRecorder(ctx, live.GetLiveId()) {
+		return ErrRecorderExist
+	}
+	urls, err := live.GetStreamUrls()
+	if err != nil {
+		return err
+	}
+	if len(urls) == 0 {
+		return ErrStreamUrlNotFound
+	}
+	if err := m.AddRecorder(ctx, live); err != nil {
+		return err
+	}
+	m.lock.Lock()
+	m.manual[live.GetLiveId()] = true
+	m.lock.Unlock()
+	return nil
+}
+
+func (m *manager) isManual(liveId live.ID) bool {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return m.manual[liveId]
+}
+
 func (m *manager) cronRestart(ctx context.Context, live live.Live) {
 	recorder, err := m.GetRecorder(ctx, live.GetLiveId())
 	if err != nil {
 		return
 	}
-	if time.Now().Sub(recorder.StartTime()) < m.cfg.VideoSplitStrategies.MaxDuration {
+	if time.Now().Sub(recorder.StartTime()) < m.cfg.VideoSplitStrategies.MaxDuration || splitsByParser(recorder) {
 		time.AfterFunc(time.Minute/4, func() {
 			m.cronRestart(ctx, live)
 		})
@@ -128,25 +356,104 @@ func (m *manager) cronRestart(ctx context.Context, live live.Live) {
 	}
 }
 
+// splitsByParser reports whether the parser of the recorder splits the file by itself.
+func splitsByParser(r Recorder) bool {
+	rec, ok := r.(*recorder)
+	if !ok {
+		return false
+	}
+	_, ok = rec.getParser().(parser.SegmentParser)
+	return ok
+}
+
+// stopAtDeadline stops the recorder which reaches the max_recording_duration,
+// and starts a new one if configured and the live is still living.
+func (m *manager) stopAtDeadline(ctx context.Context, l live.Live, r Recorder) {
+	inst := instance.GetInstance(ctx)
+	m.lock.Lock()
+	if m.savers[l.GetLiveId()] != r {
+		// removed or restarted, the new recorder has its own timer
+		m.lock.Unlock()
+		return
+	}
+	manual := m.manual[l.GetLiveId()]
+	if rec, ok := r.(*recorder); ok {
+		rec.stopReason = StopReasonMaxDurationCap
+	}
+	err := m.removeRecorder(l.GetLiveId())
+	m.lock.Unlock()
+	if err != nil {
+		inst.Logger.Errorf("failed to remove recorder, err: %v", err)
+		return
+	}
+	inst.Logger.Infof("recorder of %s is stopped by max_recording_duration", l.GetRawUrl())
+	if !m.cfg.RestartAfterMaxRecordingDuration {
+		return
+	}
+	if manual {
+		err = m.StartManualRecorder(ctx, l)
+	} else if obj, cacheErr := inst.Cache.Get(l); cacheErr == nil && obj.(*live.Info).Status {
+		err = m.AddRecorder(ctx, l)
+	}
+	if err != nil {
+		inst.Logger.Errorf("failed to restart recorder, err: %v", err)
+	}
+}
+
+// RemainingRecordingDuration returns the time left before the recorder is stopped
+// by max_recording_duration, false if the recorder has no limit.
+func (m *manager) RemainingRecordingDuration(ctx context.Context, liveId live.ID) (time.Duration, bool) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	deadline, ok := m.deadlines[liveId]
+	if !ok {
+		return 0, false
+	}
+	if remaining := time.Until(deadline); remaining > 0 {
+		return remaining, true
+	}
+	return 0, true
+}
+
 func (m *manager) RestartRecorder(ctx context.Context, live live.Live) error {
+	manual := m.isManual(live.GetLiveId())
+	m.lock.RLock()
+	deadline := m.deadlines[live.GetLiveId()]
+	m.lock.RUnlock()
 	if err := m.RemoveRecorder(ctx, live.GetLiveId()); err != nil {
 		return err
 	}
-	if err := m.AddRecorder(ctx, live); err != nil {
+	if err := m.addRecorder(ctx, live, deadline); err != nil {
 		return err
 	}
+	if manual {
+		m.lock.Lock()
+		m.manual[live.GetLiveId()] = true
+		m.lock.Unlock()
+	}
 	return nil
 }
 
 func (m *manager) RemoveRecorder(ctx context.Context, liveId live.ID) error {
 	m.lock.Lock()
 	defer m.lock.Unlock()
+	return m.removeRecorder(liveId)
+}
+
+// removeRecorder must be called with the lock held.
+func (m *manager) removeRecorder(liveId live.ID) error {
+	if timer, ok := m.pending[liveId]; ok {
+		timer.Stop()
+		delete(m.pending, liveId)
+	}
 	recorder, ok := m.savers[liveId]
 	if !ok {
 		return ErrRecorderNotExist
 	}
 	recorder.Close()
 	delete(m.savers, liveId)
+	delete(m.manual, liveId)
+	delete(m.deadlines, liveId)
 	return nil
 }
 
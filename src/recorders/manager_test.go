@@ -2,15 +2,23 @@ package recorders
 
 import (
 	"context"
+	"net/url"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/bluele/gcache"
 	"github.com/golang/mock/gomock"
+	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 
 	"github.com/hr3lxphr6j/bililive-go/src/configs"
 	"github.com/hr3lxphr6j/bililive-go/src/instance"
+	"github.com/hr3lxphr6j/bililive-go/src/interfaces"
+	"github.com/hr3lxphr6j/bililive-go/src/listeners"
 	"github.com/hr3lxphr6j/bililive-go/src/live"
 	livemock "github.com/hr3lxphr6j/bililive-go/src/live/mock"
+	"github.com/hr3lxphr6j/bililive-go/src/pkg/events"
 )
 
 func TestManagerAddAndRemoveRecorder(t *testing.T) {
@@ -31,6 +39,7 @@ func TestManagerAddAndRemoveRecorder(t *testing.T) {
 	defer func() { newRecorder = backup }()
 	l := livemock.NewMockLive(ctrl)
 	l.EXPECT().GetLiveId().Return(live.ID("test")).AnyTimes()
+	l.EXPECT().GetRawUrl().Return("https://example.com/test").AnyTimes()
 	assert.NoError(t, m.AddRecorder(context.Background(), l))
 	assert.Equal(t, ErrRecorderExist, m.AddRecorder(context.Background(), l))
 	ln, err := m.GetRecorder(context.Background(), "test")
@@ -44,3 +53,222 @@ func TestManagerAddAndRemoveRecorder(t *testing.T) {
 	assert.Equal(t, ErrRecorderNotExist, err)
 	assert.False(t, m.HasRecorder(context.Background(), "test"))
 }
+
+func TestManagerStartManualRecorder(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.WithValue(context.Background(), instance.Key, &instance.Instance{
+		Config: new(configs.Config),
+	})
+	m := NewManager(ctx).(*manager)
+	backup := newRecorder
+	newRecorder = func(ctx context.Context, live live.Live) (Recorder, error) {
+		r := NewMockRecorder(ctrl)
+		r.EXPECT().Start(gomock.Any()).Return(nil)
+		r.EXPECT().Close()
+		return r, nil
+	}
+	defer func() { newRecorder = backup }()
+
+	l := livemock.NewMockLive(ctrl)
+	l.EXPECT().GetLiveId().Return(live.ID("test")).AnyTimes()
+	l.EXPECT().GetRawUrl().Return("https://example.com/test").AnyTimes()
+	l.EXPECT().GetStreamUrls().Return(nil, nil)
+	assert.Equal(t, ErrStreamUrlNotFound, m.StartManualRecorder(context.Background(), l))
+	assert.False(t, m.HasRecorder(context.Background(), "test"))
+
+	u, _ := url.Parse("https://example.com/live.flv")
+	l.EXPECT().GetStreamUrls().Return([]*url.URL{u}, nil)
+	assert.NoError(t, m.StartManualRecorder(context.Background(), l))
+	assert.True(t, m.isManual("test"))
+	assert.Equal(t, ErrRecorderExist, m.StartManualRecorder(context.Background(), l))
+
+	assert.NoError(t, m.RestartRecorder(context.Background(), l))
+	assert.True(t, m.isManual("test"))
+
+	assert.NoError(t, m.RemoveRecorder(context.Background(), "test"))
+	assert.False(t, m.isManual("test"))
+}
+
+func TestManagerEndGracePeriod(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	inst := &instance.Instance{
+		Config: &configs.Config{EndGracePeriod: 100 * time.Millisecond},
+	}
+	ctx := context.WithValue(context.Background(), instance.Key, inst)
+	ed := events.NewDispatcher(ctx)
+	m := NewManager(ctx).(*manager)
+	m.registryListener(ctx, ed)
+	backup := newRecorder
+	newRecorder = func(ctx context.Context, live live.Live) (Recorder, error) {
+		r := NewMockRecorder(ctrl)
+		r.EXPECT().Start(gomock.Any()).Return(nil)
+		r.EXPECT().Close()
+		return r, nil
+	}
+	defer func() { newRecorder = backup }()
+	l := livemock.NewMockLive(ctrl)
+	l.EXPECT().GetLiveId().Return(live.ID("test")).AnyTimes()
+	l.EXPECT().GetRawUrl().Return("https://example.com/test").AnyTimes()
+	assert.NoError(t, m.AddRecorder(ctx, l))
+
+	// the live starts again in the grace period, the recorder is kept
+	ed.DispatchEvent(events.NewEvent(listeners.LiveEnd, l))
+	assert.Eventually(t, func() bool { return m.IsReconnecting(ctx, "test") }, time.Second, 10*time.Millisecond)
+	ed.DispatchEvent(events.NewEvent(listeners.LiveStart, l))
+	assert.Eventually(t, func() bool { return !m.IsReconnecting(ctx, "test") }, time.Second, 10*time.Millisecond)
+	time.Sleep(150 * time.Millisecond)
+	assert.True(t, m.HasRecorder(ctx, "test"))
+
+	// the recorder is removed after the grace period
+	ed.DispatchEvent(events.NewEvent(listeners.LiveEnd, l))
+	assert.Eventually(t, func() bool { return m.IsReconnecting(ctx, "test") }, time.Second, 10*time.Millisecond)
+	assert.Eventually(t, func() bool { return !m.HasRecorder(ctx, "test") }, time.Second, 10*time.Millisecond)
+	assert.False(t, m.IsReconnecting(ctx, "test"))
+}
+
+func TestManagerPauseAndResumeAllRecorders(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	inst := &instance.Instance{
+		Config: new(configs.Config),
+		Cache:  gcache.New(4).LRU().Build(),
+	}
+	ctx := context.WithValue(context.Background(), instance.Key, inst)
+	m := NewManager(ctx).(*manager)
+	backup := newRecorder
+	newRecorder = func(ctx context.Context, live live.Live) (Recorder, error) {
+		r := NewMockRecorder(ctrl)
+		r.EXPECT().Start(gomock.Any()).Return(nil)
+		r.EXPECT().Close()
+		return r, nil
+	}
+	defer func() { newRecorder = backup }()
+
+	newLive := func(id live.ID, status bool) live.Live {
+		l := livemock.NewMockLive(ctrl)
+		l.EXPECT().GetLiveId().Return(id).AnyTimes()
+		l.EXPECT().GetRawUrl().Return("https://example.com/" + string(id)).AnyTimes()
+		assert.NoError(t, inst.Cache.Set(l, &live.Info{Live: l, Status: status}))
+		return l
+	}
+	living, ended, manual := newLive("living", true), newLive("ended", false), newLive("manual", false)
+	inst.Lives = map[live.ID]live.Live{"living": living, "ended": ended, "manual": manual}
+	u, _ := url.Parse("https://example.com/live.flv")
+	manual.(*livemock.MockLive).EXPECT().GetStreamUrls().Return([]*url.URL{u}, nil).Times(2)
+	assert.NoError(t, m.AddRecorder(ctx, living))
+	assert.NoError(t, m.AddRecorder(ctx, ended))
+	assert.NoError(t, m.StartManualRecorder(ctx, manual))
+
+	m.PauseAllRecorders(ctx)
+	assert.False(t, m.HasRecorder(ctx, "living"))
+	assert.False(t, m.HasRecorder(ctx, "manual"))
+	assert.Equal(t, ErrRecordersPaused, m.AddRecorder(ctx, living))
+
+	// the ended live is not resumed
+	m.ResumeAllRecorders(ctx)
+	assert.True(t, m.HasRecorder(ctx, "living"))
+	assert.False(t, m.HasRecorder(ctx, "ended"))
+	assert.True(t, m.isManual("manual"))
+
+	assert.NoError(t, m.RemoveRecorder(ctx, "living"))
+	assert.NoError(t, m.RemoveRecorder(ctx, "manual"))
+}
+
+// listeningManager is a listeners.Manager which listens the given lives.
+type listeningManager struct {
+	listeners.Manager
+	listening map[live.ID]bool
+}
+
+func (l *listeningManager) HasListener(ctx context.Context, liveId live.ID) bool {
+	return l.listening[liveId]
+}
+
+func TestManagerResumeLiveStartedWhilePaused(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	inst := &instance.Instance{
+		Config: new(configs.Config),
+		Cache:  gcache.New(4).LRU().Build(),
+	}
+	ctx := context.WithValue(context.Background(), instance.Key, inst)
+	m := NewManager(ctx).(*manager)
+	backup := newRecorder
+	newRecorder = func(ctx context.Context, live live.Live) (Recorder, error) {
+		r := NewMockRecorder(ctrl)
+		r.EXPECT().Start(gomock.Any()).Return(nil)
+		r.EXPECT().Close()
+		return r, nil
+	}
+	defer func() { newRecorder = backup }()
+
+	newLive := func(id live.ID) live.Live {
+		l := livemock.NewMockLive(ctrl)
+		l.EXPECT().GetLiveId().Return(id).AnyTimes()
+		l.EXPECT().GetRawUrl().Return("https://example.com/" + string(id)).AnyTimes()
+		assert.NoError(t, inst.Cache.Set(l, &live.Info{Live: l, Status: false}))
+		return l
+	}
+	started, notListened, offline := newLive("started"), newLive("not_listened"), newLive("offline")
+	inst.Lives = map[live.ID]live.Live{"started": started, "not_listened": notListened, "offline": offline}
+	inst.ListenerManager = &listeningManager{listening: map[live.ID]bool{"started": true, "offline": true}}
+
+	m.PauseAllRecorders(ctx)
+	// the live starts while paused, the LiveStart is rejected and not sent again
+	assert.NoError(t, inst.Cache.Set(started, &live.Info{Live: started, Status: true}))
+	assert.NoError(t, inst.Cache.Set(notListened, &live.Info{Live: notListened, Status: true}))
+	assert.Equal(t, ErrRecordersPaused, m.AddRecorder(ctx, started))
+
+	m.ResumeAllRecorders(ctx)
+	assert.True(t, m.HasRecorder(ctx, "started"))
+	assert.False(t, m.HasRecorder(ctx, "not_listened"))
+	assert.False(t, m.HasRecorder(ctx, "offline"))
+	assert.NoError(t, m.RemoveRecorder(ctx, "started"))
+}
+
+func TestManagerMaxRecordingDuration(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	inst := &instance.Instance{
+		Config: &configs.Config{MaxRecordingDuration: 100 * time.Millisecond, RestartAfterMaxRecordingDuration: true},
+		Cache:  gcache.New(4).LRU().Build(),
+		Logger: &interfaces.Logger{Logger: logrus.New()},
+	}
+	ctx := context.WithValue(context.Background(), instance.Key, inst)
+	m := NewManager(ctx).(*manager)
+	backup := newRecorder
+	var created int32
+	newRecorder = func(ctx context.Context, live live.Live) (Recorder, error) {
+		atomic.AddInt32(&created, 1)
+		r := NewMockRecorder(ctrl)
+		r.EXPECT().Start(gomock.Any()).Return(nil)
+		r.EXPECT().Close()
+		return r, nil
+	}
+	defer func() { newRecorder = backup }()
+	l := livemock.NewMockLive(ctrl)
+	l.EXPECT().GetLiveId().Return(live.ID("test")).AnyTimes()
+	l.EXPECT().GetRawUrl().Return("https://example.com/test").AnyTimes()
+	assert.NoError(t, inst.Cache.Set(l, &live.Info{Live: l, Status: true}))
+
+	_, ok := m.RemainingRecordingDuration(ctx, "test")
+	assert.False(t, ok)
+	assert.NoError(t, m.AddRecorder(ctx, l))
+	remaining, ok := m.RemainingRecordingDuration(ctx, "test")
+	assert.True(t, ok)
+	assert.True(t, remaining > 0 && remaining <= 100*time.Millisecond)
+
+	// the restart of the split keeps the deadline
+	assert.NoError(t, m.RestartRecorder(ctx, l))
+	restarted, _ := m.RemainingRecordingDuration(ctx, "test")
+	assert.True(t, restarted <= remaining)
+
+	// stopped at the deadline, and restarted as the live is still living
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&created) == 3 }, time.Second, 10*time.Millisecond)
+	assert.True(t, m.HasRecorder(ctx, "test"))
+	assert.NoError(t, m.RemoveRecorder(ctx, "test"))
+	_, ok = m.RemainingRecordingDuration(ctx, "test")
+	assert.False(t, ok)
+}
@@ -0,0 +1,56 @@
+package recorders
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"text/template"
+	"time"
+
+	"github.com/hr3lxphr6j/bililive-go/src/configs"
+	"github.com/hr3lxphr6j/bililive-go/src/live"
+	"github.com/hr3lxphr6j/bililive-go/src/pkg/utils"
+)
+
+// defaultMetadataTmpl is used when no metadata template is configured.
+var defaultMetadataTmpl = map[string]string{
+	"title":   `{{ .RoomName }}`,
+	"artist":  `{{ .DisplayName }}`,
+	"date":    `{{ .StartTime | date "2006-01-02T15:04:05Z07:00" }}`,
+	"comment": `{{ .Live.GetRawUrl }}`,
+}
+
+// getMetadataArgs renders the metadata templates with the info of the live,
+// and returns them as the "-metadata key=value" args of ffmpeg.
+func getMetadataArgs(config *configs.Config, info *live.Info, startTime time.Time) ([]string, error) {
+	tmpls := config.OnRecordFinished.Metadata
+	if len(tmpls) == 0 {
+		tmpls = defaultMetadataTmpl
+	}
+	keys := make([]string, 0, len(tmpls))
+	for key := range tmpls {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	data := struct {
+		*live.Info
+		StartTime time.Time
+	}{
+		Info:      info,
+		StartTime: startTime,
+	}
+	args := make([]string, 0, len(keys)*2)
+	for _, key := range keys {
+		tmpl, err := template.New("metadata").Funcs(utils.GetFuncMap(config)).Parse(tmpls[key])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse metadata template of %s: %w", key, err)
+		}
+		buf := new(bytes.Buffer)
+		if err := tmpl.Execute(buf, data); err != nil {
+			return nil, fmt.Errorf("failed to render metadata template of %s: %w", key, err)
+		}
+		args = append(args, "-metadata", key+"="+buf.String())
+	}
+	return args, nil
+}
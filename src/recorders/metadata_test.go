@@ -0,0 +1,41 @@
+package recorders
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hr3lxphr6j/bililive-go/src/configs"
+	"github.com/hr3lxphr6j/bililive-go/src/live"
+	"github.com/hr3lxphr6j/bililive-go/src/live/mock"
+)
+
+func TestGetMetadataArgs(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	l := mock.NewMockLive(ctrl)
+	l.EXPECT().GetRawUrl().Return("https://live.bilibili.com/1").AnyTimes()
+	info := &live.Info{Live: l, HostName: "host", RoomName: "room"}
+	startTime := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	config := configs.NewConfig()
+	args, err := getMetadataArgs(config, info, startTime)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"-metadata", "artist=host",
+		"-metadata", "comment=https://live.bilibili.com/1",
+		"-metadata", "date=2021-01-02T03:04:05Z",
+		"-metadata", "title=room",
+	}, args)
+
+	config.OnRecordFinished.Metadata = map[string]string{"title": "{{ .DisplayName }} - {{ .RoomName }}"}
+	args, err = getMetadataArgs(config, info, startTime)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"-metadata", "title=host - room"}, args)
+
+	config.OnRecordFinished.Metadata = map[string]string{"title": "{{ .RoomName"}
+	_, err = getMetadataArgs(config, info, startTime)
+	assert.Error(t, err)
+}
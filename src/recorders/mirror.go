@@ -0,0 +1,33 @@
+package recorders
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/hr3lxphr6j/bililive-go/src/pkg/utils"
+)
+
+// MirrorResult is the result of copying a finished file into a mirror output path.
+type MirrorResult struct {
+	File string // the copy
+	Err  error
+}
+
+// mirrorFile copies the file into each of the mirror paths, keeping its path relative to the out
+// put path, the files outside of the out put path are copied into the root of the mirror paths.
+func mirrorFile(outputPath string, mirrorPaths []string, file string) []MirrorResult {
+	rel, err := filepath.Rel(outputPath, file)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		rel = filepath.Base(file)
+	}
+	results := make([]MirrorResult, 0, len(mirrorPaths))
+	for _, mirrorPath := range mirrorPaths {
+		dst := filepath.Join(mirrorPath, rel)
+		err := mkdir(filepath.Dir(dst))
+		if err == nil {
+			err = utils.CopyFile(file, dst)
+		}
+		results = append(results, MirrorResult{File: dst, Err: err})
+	}
+	return results
+}
@@ -0,0 +1,103 @@
+package recorders
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/bluele/gcache"
+	"github.com/golang/mock/gomock"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hr3lxphr6j/bililive-go/src/configs"
+	"github.com/hr3lxphr6j/bililive-go/src/instance"
+	"github.com/hr3lxphr6j/bililive-go/src/interfaces"
+	"github.com/hr3lxphr6j/bililive-go/src/live"
+	"github.com/hr3lxphr6j/bililive-go/src/live/mock"
+	"github.com/hr3lxphr6j/bililive-go/src/pkg/events"
+	evtmock "github.com/hr3lxphr6j/bililive-go/src/pkg/events/mock"
+)
+
+func TestMirrorFile(t *testing.T) {
+	root, err := ioutil.TempDir("", "mirror")
+	assert.NoError(t, err)
+	defer os.RemoveAll(root)
+	outputPath := filepath.Join(root, "output")
+	file := filepath.Join(outputPath, "platform", "host", "a.flv")
+	assert.NoError(t, os.MkdirAll(filepath.Dir(file), os.ModePerm))
+	assert.NoError(t, ioutil.WriteFile(file, []byte("flv"), 0644))
+	// a file can not be created under a regular file
+	broken := filepath.Join(root, "broken")
+	assert.NoError(t, ioutil.WriteFile(broken, nil, 0644))
+
+	results := mirrorFile(outputPath, []string{filepath.Join(root, "mirror"), broken}, file)
+	assert.Len(t, results, 2)
+	assert.NoError(t, results[0].Err)
+	assert.Equal(t, filepath.Join(root, "mirror", "platform", "host", "a.flv"), results[0].File)
+	b, err := ioutil.ReadFile(results[0].File)
+	assert.NoError(t, err)
+	assert.Equal(t, "flv", string(b))
+	_, err = os.Stat(results[0].File + ".tmp")
+	assert.True(t, os.IsNotExist(err))
+	assert.Error(t, results[1].Err)
+
+	// outside of the out put path
+	results = mirrorFile(filepath.Join(root, "other"), []string{filepath.Join(root, "mirror")}, file)
+	assert.NoError(t, results[0].Err)
+	assert.Equal(t, filepath.Join(root, "mirror", "a.flv"), results[0].File)
+}
+
+func TestFinishFileMirrorsConverted(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake ffmpeg is a shell script")
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	root, err := ioutil.TempDir("", "mirror")
+	assert.NoError(t, err)
+	defer os.RemoveAll(root)
+	// copies the input into the output, the last argument
+	ffmpeg := filepath.Join(root, "ffmpeg")
+	assert.NoError(t, ioutil.WriteFile(ffmpeg, []byte("#!/bin/sh\nfor out; do :; done\ncp \"$3\" \"$out\"\n"), 0755))
+
+	outputPath := filepath.Join(root, "output")
+	file := filepath.Join(outputPath, "a.flv")
+	assert.NoError(t, os.MkdirAll(outputPath, os.ModePerm))
+	assert.NoError(t, ioutil.WriteFile(file, []byte("flv"), 0644))
+	cfg := configs.NewConfig()
+	cfg.FfmpegPath = ffmpeg
+	cfg.MirrorOutputPaths = []string{filepath.Join(root, "mirror")}
+	cfg.OnRecordFinished.ConvertToMp4 = true
+	cfg.OnRecordFinished.DeleteFlvAfterConvert = true
+
+	l := mock.NewMockLive(ctrl)
+	l.EXPECT().GetRawUrl().Return("https://live.bilibili.com/1").AnyTimes()
+	ed := evtmock.NewMockDispatcher(ctrl)
+	ed.EXPECT().DispatchEvent(gomock.Any()).Do(func(e *events.Event) {
+		assert.Equal(t, RecordFileMirrored, e.Type)
+		assert.Equal(t, filepath.Join(outputPath, "a.mp4"), e.Object.(RecordFileMirroredParam).File)
+	})
+	r := &recorder{
+		Live:       l,
+		OutPutPath: outputPath,
+		config:     cfg,
+		ed:         ed,
+		cache:      gcache.New(4).LRU().Build(),
+		logger:     &interfaces.Logger{Logger: logrus.New()},
+	}
+	r.cache.Set(l, &live.Info{Live: l})
+	ctx := context.WithValue(context.Background(), instance.Key, &instance.Instance{Config: cfg})
+	r.finishFile(ctx, file, time.Now())
+
+	// only the final artifact is mirrored, the flv is deleted after converted
+	b, err := ioutil.ReadFile(filepath.Join(root, "mirror", "a.mp4"))
+	assert.NoError(t, err)
+	assert.Equal(t, "flv", string(b))
+	_, err = os.Stat(filepath.Join(root, "mirror", "a.flv"))
+	assert.True(t, os.IsNotExist(err))
+}
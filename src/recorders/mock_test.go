@@ -6,6 +6,7 @@ package recorders
 
 import (
 	context "context"
+	url "net/url"
 	reflect "reflect"
 	time "time"
 
@@ -63,6 +64,35 @@ func (mr *MockRecorderMockRecorder) GetStatus() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStatus", reflect.TypeOf((*MockRecorder)(nil).GetStatus))
 }
 
+// RecordingFile mocks base method.
+func (m *MockRecorder) RecordingFile() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordingFile")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// RecordingFile indicates an expected call of RecordingFile.
+func (mr *MockRecorderMockRecorder) RecordingFile() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordingFile", reflect.TypeOf((*MockRecorder)(nil).RecordingFile))
+}
+
+// RecordingFileMD5 mocks base method.
+func (m *MockRecorder) RecordingFileMD5() (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordingFileMD5")
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RecordingFileMD5 indicates an expected call of RecordingFileMD5.
+func (mr *MockRecorderMockRecorder) RecordingFileMD5() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordingFileMD5", reflect.TypeOf((*MockRecorder)(nil).RecordingFileMD5))
+}
+
 // Start mocks base method.
 func (m *MockRecorder) Start(arg0 context.Context) error {
 	m.ctrl.T.Helper()
@@ -91,6 +121,34 @@ func (mr *MockRecorderMockRecorder) StartTime() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StartTime", reflect.TypeOf((*MockRecorder)(nil).StartTime))
 }
 
+// StreamUrl mocks base method.
+func (m *MockRecorder) StreamUrl() *url.URL {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StreamUrl")
+	ret0, _ := ret[0].(*url.URL)
+	return ret0
+}
+
+// StreamUrl indicates an expected call of StreamUrl.
+func (mr *MockRecorderMockRecorder) StreamUrl() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StreamUrl", reflect.TypeOf((*MockRecorder)(nil).StreamUrl))
+}
+
+// UnsupportedReason mocks base method.
+func (m *MockRecorder) UnsupportedReason() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UnsupportedReason")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// UnsupportedReason indicates an expected call of UnsupportedReason.
+func (mr *MockRecorderMockRecorder) UnsupportedReason() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UnsupportedReason", reflect.TypeOf((*MockRecorder)(nil).UnsupportedReason))
+}
+
 // MockManager is a mock of Manager interface.
 type MockManager struct {
 	ctrl     *gomock.Controller
@@ -169,6 +227,47 @@ func (mr *MockManagerMockRecorder) HasRecorder(arg0, arg1 interface{}) *gomock.C
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HasRecorder", reflect.TypeOf((*MockManager)(nil).HasRecorder), arg0, arg1)
 }
 
+// IsReconnecting mocks base method.
+func (m *MockManager) IsReconnecting(arg0 context.Context, arg1 live.ID) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IsReconnecting", arg0, arg1)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// IsReconnecting indicates an expected call of IsReconnecting.
+func (mr *MockManagerMockRecorder) IsReconnecting(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsReconnecting", reflect.TypeOf((*MockManager)(nil).IsReconnecting), arg0, arg1)
+}
+
+// PauseAllRecorders mocks base method.
+func (m *MockManager) PauseAllRecorders(arg0 context.Context) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "PauseAllRecorders", arg0)
+}
+
+// PauseAllRecorders indicates an expected call of PauseAllRecorders.
+func (mr *MockManagerMockRecorder) PauseAllRecorders(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PauseAllRecorders", reflect.TypeOf((*MockManager)(nil).PauseAllRecorders), arg0)
+}
+
+// RemainingRecordingDuration mocks base method.
+func (m *MockManager) RemainingRecordingDuration(arg0 context.Context, arg1 live.ID) (time.Duration, bool) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemainingRecordingDuration", arg0, arg1)
+	ret0, _ := ret[0].(time.Duration)
+	ret1, _ := ret[1].(bool)
+	return ret0, ret1
+}
+
+// RemainingRecordingDuration indicates an expected call of RemainingRecordingDuration.
+func (mr *MockManagerMockRecorder) RemainingRecordingDuration(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemainingRecordingDuration", reflect.TypeOf((*MockManager)(nil).RemainingRecordingDuration), arg0, arg1)
+}
+
 // RemoveRecorder mocks base method.
 func (m *MockManager) RemoveRecorder(arg0 context.Context, arg1 live.ID) error {
 	m.ctrl.T.Helper()
@@ -197,6 +296,18 @@ func (mr *MockManagerMockRecorder) RestartRecorder(arg0, arg1 interface{}) *gomo
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestartRecorder", reflect.TypeOf((*MockManager)(nil).RestartRecorder), arg0, arg1)
 }
 
+// ResumeAllRecorders mocks base method.
+func (m *MockManager) ResumeAllRecorders(arg0 context.Context) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "ResumeAllRecorders", arg0)
+}
+
+// ResumeAllRecorders indicates an expected call of ResumeAllRecorders.
+func (mr *MockManagerMockRecorder) ResumeAllRecorders(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResumeAllRecorders", reflect.TypeOf((*MockManager)(nil).ResumeAllRecorders), arg0)
+}
+
 // Start mocks base method.
 func (m *MockManager) Start(arg0 context.Context) error {
 	m.ctrl.T.Helper()
@@ -210,3 +321,17 @@ func (mr *MockManagerMockRecorder) Start(arg0 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Start", reflect.TypeOf((*MockManager)(nil).Start), arg0)
 }
+
+// StartManualRecorder mocks base method.
+func (m *MockManager) StartManualRecorder(arg0 context.Context, arg1 live.Live) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StartManualRecorder", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// StartManualRecorder indicates an expected call of StartManualRecorder.
+func (mr *MockManagerMockRecorder) StartManualRecorder(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StartManualRecorder", reflect.TypeOf((*MockManager)(nil).StartManualRecorder), arg0, arg1)
+}
@@ -0,0 +1,132 @@
+package recorders
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/hr3lxphr6j/bililive-go/src/configs"
+	"github.com/hr3lxphr6j/bililive-go/src/instance"
+	"github.com/hr3lxphr6j/bililive-go/src/live"
+	"github.com/hr3lxphr6j/bililive-go/src/pkg/utils"
+)
+
+// PostProcessFile runs the on_record_finished actions on an existing recorded file of the live,
+// the modification time of the file is used as the start time of the record.
+func PostProcessFile(ctx context.Context, l live.Live, fileName string) error {
+	stat, err := os.Stat(fileName)
+	if err != nil {
+		return err
+	}
+	if stat.IsDir() {
+		return ErrNotRegularFile
+	}
+	inst := instance.GetInstance(ctx)
+	obj, err := inst.Cache.Get(l)
+	if err != nil {
+		return err
+	}
+	info := obj.(*live.Info)
+	logger := inst.Logger.WithFields(map[string]interface{}{
+		"host": info.HostName,
+		"room": info.RoomName,
+		"file": fileName,
+	})
+	postProcess(ctx, inst.Config, logger, info, fileName, stat.ModTime())
+	return nil
+}
+
+// postProcess runs the on_record_finished actions on the recorded file,
+// the mp4 file is returned when the file is converted.
+func postProcess(ctx context.Context, config *configs.Config, logger *logrus.Entry, info *live.Info, fileName string, startTime time.Time) (converted string) {
+	ffmpegPath, err := utils.GetFFmpegPath(ctx)
+	if err != nil {
+		logger.WithError(err).Error("failed to find ffmpeg")
+		return ""
+	}
+	cmdStr := strings.Trim(config.OnRecordFinished.CustomCommandline, "")
+	if len(cmdStr) > 0 {
+		tmpl, err := template.New("custom_commandline").Funcs(utils.GetFuncMap(config)).Parse(cmdStr)
+		if err != nil {
+			logger.WithError(err).Error("custom commandline parse failure")
+			return ""
+		}
+
+		buf := new(bytes.Buffer)
+		if err := tmpl.Execute(buf, struct {
+			*live.Info
+			FileName string
+			Ffmpeg   string
+		}{
+			Info:     info,
+			FileName: fileName,
+			Ffmpeg:   ffmpegPath,
+		}); err != nil {
+			logger.WithError(err).Errorln("failed to render custom commandline")
+			return ""
+		}
+		bash := ""
+		args := []string{}
+		switch runtime.GOOS {
+		case "linux":
+			bash = "sh"
+			args = []string{"-c"}
+		case "windows":
+			bash = "cmd"
+			args = []string{"/C"}
+		default:
+			logger.Warnln("Unsupport system ", runtime.GOOS)
+		}
+		args = append(args, buf.String())
+		logger.Debugf("start executing custom_commandline: %s", args[1])
+		cmd := exec.Command(bash, args...)
+		if config.Debug {
+			cmd.Stdout = os.Stdout
+			cmd.Stderr = os.Stderr
+		}
+		if err = cmd.Run(); err != nil {
+			logger.WithError(err).Debugf("custom commandline execute failure (%s %s)\n", bash, strings.Join(args, " "))
+		} else if config.OnRecordFinished.DeleteFlvAfterConvert {
+			os.Remove(fileName)
+		}
+		logger.Debugf("end executing custom_commandline: %s", args[1])
+	} else if config.OnRecordFinished.ConvertToMp4 && !strings.EqualFold(filepath.Ext(fileName), ".mp4") {
+		// the files recorded into mp4 directly are skipped
+		//格式转换时去除原本后缀名
+		newFileName := fileName[0:strings.LastIndex(fileName, ".")]
+		args := []string{
+			"-hide_banner",
+			"-i",
+			fileName,
+			"-c",
+			"copy",
+		}
+		if config.OnRecordFinished.EmbedMetadata {
+			metadataArgs, err := getMetadataArgs(config, info, startTime)
+			if err != nil {
+				logger.WithError(err).Warn("failed to get metadata, skip embedding")
+			}
+			args = append(args, metadataArgs...)
+		}
+		args = append(args, newFileName+".mp4")
+		convertCmd := exec.Command(ffmpegPath, args...)
+		if err = convertCmd.Run(); err != nil {
+			convertCmd.Process.Kill()
+			logger.Debugln(err)
+			return ""
+		}
+		if config.OnRecordFinished.DeleteFlvAfterConvert {
+			os.Remove(fileName)
+		}
+		return newFileName + ".mp4"
+	}
+	return ""
+}
@@ -7,9 +7,7 @@ import (
 	"fmt"
 	"net/url"
 	"os"
-	"os/exec"
 	"path/filepath"
-	"runtime"
 	"strconv"
 	"strings"
 	"sync"
@@ -40,6 +38,11 @@ const (
 
 // for test
 var (
+	fileStartedCheckInterval = time.Second
+	// the unsupported streams are checked again in this interval instead of retrying
+	unsupportedRecheckInterval = 5 * time.Minute
+	probeEncryptedHLS          = isEncryptedHLS
+
 	newParser = func(u *url.URL, useNativeFlvParser bool, cfg map[string]string) (parser.Parser, error) {
 		parserName := ffmpeg.Name
 		if strings.Contains(u.Path, ".flv") && useNativeFlvParser {
@@ -61,13 +64,17 @@ var (
 
 func getDefaultFileNameTmpl(config *configs.Config) *template.Template {
 	return template.Must(template.New("filename").Funcs(utils.GetFuncMap(config)).
-		Parse(`{{ .Live.GetPlatformCNName }}/{{ .HostName | filenameFilter }}/[{{ now | date "2006-01-02 15-04-05"}}][{{ .HostName | filenameFilter }}][{{ .RoomName | filenameFilter }}].flv`))
+		Parse(`{{ .Live.GetPlatformCNName }}/{{ .DisplayName | filenameFilter }}/[{{ now | date "2006-01-02 15-04-05"}}][{{ .DisplayName | filenameFilter }}][{{ .RoomName | filenameFilter }}].flv`))
 }
 
 type Recorder interface {
 	Start(ctx context.Context) error
 	StartTime() time.Time
 	GetStatus() (map[string]string, error)
+	RecordingFile() string
+	StreamUrl() *url.URL
+	RecordingFileMD5() (string, error)
+	UnsupportedReason() string
 	Close()
 }
 
@@ -82,6 +89,15 @@ type recorder struct {
 	startTime  time.Time
 	parser     parser.Parser
 	parserLock *sync.RWMutex
+	// the transcoding can not keep up, record with stream-copy since then
+	transcodeDisabled bool
+	recordingFile     recordingFile
+	stopReason        string       // set before Close, carried by the RecorderStop event
+	alternativeUrls   []*url.URL   // the other urls of the failed stream, tried before resolving again
+	refreshedUrls     []*url.URL   // resolved again before the url expired, used by the next attempt
+	unsupported       atomic.Value // string, why the stream can not be recorded
+	streamUrl         atomic.Value // *url.URL, the url being recorded
+	sessionFiles      sessionFiles // the finished files, to find the duplicates
 
 	stop  chan struct{}
 	state uint32
@@ -104,15 +120,22 @@ func NewRecorder(ctx context.Context, live live.Live) (Recorder, error) {
 }
 
 func (r *recorder) tryRecord(ctx context.Context) {
-	urls, err := r.Live.GetStreamUrls()
+	urls, err := r.nextStreamUrls()
 	if err != nil || len(urls) == 0 {
 		r.getLogger().WithError(err).Warn("failed to get stream url, will retry after 5s...")
 		time.Sleep(5 * time.Second)
 		return
 	}
+	if strings.Contains(urls[0].Path, "m3u8") && r.skipEncryptedStream(urls[0]) {
+		return
+	}
 
-	obj, _ := r.cache.Get(r.Live)
-	info := obj.(*live.Info)
+	info, err := live.GetCachedInfo(r.cache, r.Live)
+	if err != nil {
+		r.getLogger().WithError(err).Warn("failed to get live info, will retry after 5s...")
+		time.Sleep(5 * time.Second)
+		return
+	}
 
 	tmpl := getDefaultFileNameTmpl(r.config)
 	if r.config.OutputTmpl != "" {
@@ -134,104 +157,305 @@ func (r *recorder) tryRecord(ctx context.Context) {
 		fileName = fileName[:len(fileName)-4] + ".ts"
 	}
 
+	container := r.config.GetContainer(r.Live.GetRawUrl())
 	if info.AudioOnly {
 		fileName = fileName[:strings.LastIndex(fileName, ".")] + ".aac"
+	} else if container == configs.ContainerMp4 || container == configs.ContainerFmp4 {
+		fileName = fileName[:strings.LastIndex(fileName, ".")] + ".mp4"
 	}
 
 	if err = mkdir(outputPath); err != nil {
 		r.getLogger().WithError(err).Errorf("failed to create output path[%s]", outputPath)
 		return
 	}
+	nice, ioClass := r.config.GetFfmpegPriority(r.Live.GetRawUrl())
 	parserCfg := map[string]string{
 		"timeout_in_us": strconv.Itoa(r.config.TimeoutInUs),
+		"hwaccel":       r.config.Feature.HWAccel,
+		"cpu_affinity":  r.config.Feature.FfmpegCPUAffinity,
+		"nice":          strconv.Itoa(nice),
+		"io_class":      strconv.Itoa(ioClass),
 	}
 	if r.config.Debug {
 		parserCfg["debug"] = "true"
 	}
-	p, err := newParser(url, r.config.Feature.UseNativeFlvParser, parserCfg)
+	if extraArgs := r.config.GetFfmpegExtraArgs(r.Live.GetRawUrl()); extraArgs.Input != "" || extraArgs.Output != "" {
+		parserCfg["extra_input_args"] = extraArgs.Input
+		parserCfg["extra_output_args"] = extraArgs.Output
+	}
+	if r.config.Feature.WriteFlvKeyframeIndex {
+		parserCfg["write_keyframe_index"] = "true"
+	}
+	if maxDur := r.config.VideoSplitStrategies.MaxDuration; maxDur > 0 {
+		// the native flv parser splits the file by itself
+		parserCfg["max_duration_ms"] = strconv.FormatInt(maxDur.Milliseconds(), 10)
+	}
+	// transcoding and muxing into mp4 are done by ffmpeg
+	useNativeFlvParser := r.config.Feature.UseNativeFlvParser
+	if !info.AudioOnly && container != configs.ContainerFlv {
+		parserCfg["container"] = container
+		useNativeFlvParser = false
+	}
+	if t := r.config.GetTranscode(r.Live.GetRawUrl()); t != nil && !info.AudioOnly && !r.transcodeDisabled {
+		parserCfg["transcode_video_bitrate"] = t.VideoBitrate
+		parserCfg["transcode_audio_bitrate"] = t.AudioBitrate
+		parserCfg["transcode_height"] = strconv.Itoa(t.Height)
+		parserCfg["transcode_video_codec"] = t.VideoCodec
+		parserCfg["transcode_preset"] = t.Preset
+		useNativeFlvParser = false
+	}
+	p, err := newParser(url, useNativeFlvParser, parserCfg)
 	if err != nil {
 		r.getLogger().WithError(err).Error("failed to init parse")
 		return
 	}
+	if dp, ok := p.(parser.DiscontinuityParser); ok {
+		dp.OnDiscontinuity(func(d parser.Discontinuity) {
+			r.ed.DispatchEvent(events.NewEvent(RecorderStreamDiscontinuity, StreamDiscontinuityParam{
+				Live:          r.Live,
+				Discontinuity: d,
+			}))
+		})
+	}
 	r.setAndCloseParser(p)
 	r.startTime = time.Now()
+	segmentFile, segmentStartTime := fileName, r.startTime
+	parseDone := make(chan struct{})
+	if sp, ok := p.(parser.SegmentParser); ok {
+		// called in the goroutine of ParseLiveStream
+		sp.OnNewSegment(func(file string) {
+			finished, startTime := segmentFile, segmentStartTime
+			segmentFile, segmentStartTime = file, time.Now()
+			r.recordingFile.set(file)
+			r.ed.DispatchEvent(events.NewEvent(ParserNewSegment, NewSegmentParam{
+				Live:         r.Live,
+				File:         file,
+				FinishedFile: finished,
+			}))
+			// the segments are files started as well, for the listeners which don't know about the segmentation
+			go r.notifyFileStarted(url, file, parseDone)
+			go r.finishFile(ctx, finished, startTime)
+		})
+	}
 	r.getLogger().Debugln("Start ParseLiveStream(" + url.String() + ", " + fileName + ")")
-	r.getLogger().Println(r.parser.ParseLiveStream(ctx, url, r.Live, fileName))
+	r.streamUrl.Store(url)
+	go r.notifyFileStarted(url, fileName, parseDone)
+	refreshed := r.refreshBeforeExpiry(p, url, parseDone)
+	if r.config.PeriodicThumbnailIntervalSec > 0 && !info.AudioOnly {
+		interval := time.Duration(r.config.PeriodicThumbnailIntervalSec) * time.Second
+		go r.captureThumbnails(ctx, url, fileName, interval, parseDone)
+	}
+	parseErr := r.parser.ParseLiveStream(ctx, url, r.Live, fileName)
+	r.getLogger().Println(parseErr)
+	if parseErr != nil && atomic.LoadUint32(&r.state) == running {
+		r.ed.DispatchEvent(events.NewEvent(ParserExited, ParserExitedParam{Live: r.Live, Err: parseErr}))
+	}
+	close(parseDone)
+	r.keepAlternatives(urls, parseErr)
+	select {
+	case r.refreshedUrls = <-refreshed:
+	default:
+	}
 	r.getLogger().Debugln("End ParseLiveStream(" + url.String() + ", " + fileName + ")")
-	removeEmptyFile(fileName)
-	ffmpegPath, err := utils.GetFFmpegPath(ctx)
+	if fp, ok := p.(*ffmpeg.Parser); ok && fp.TranscodeFellBack() {
+		r.transcodeDisabled = true
+	}
+	removeEmptyFile(segmentFile)
+	go r.finishFile(ctx, segmentFile, segmentStartTime)
+	if isDiskWriteError(parseErr) {
+		r.handleWriteFailure(segmentFile, parseErr)
+	}
+}
+
+// finishFile runs the on_record_finished actions on the finished file: cleaning up the fragment,
+// the verification and the post processing, none of them for the rooms with disable_post_processing.
+// The final files are mirrored anyway, which is not an on_record_finished action.
+// It's run off the recording path, so that reconnecting is not held by the slow actions.
+func (r *recorder) finishFile(ctx context.Context, file string, startTime time.Time) {
+	if r.config.IsPostProcessingDisabled(r.Live.GetRawUrl()) {
+		r.mirrorFiles(file)
+		return
+	}
+	if r.config.OnRecordFinished.Dedup.Enable && r.dedupFile(file) {
+		return
+	}
+	if r.config.OnRecordFinished.VerifyRecording {
+		r.verifyFile(ctx, file, time.Since(startTime))
+	}
+	info, err := live.GetCachedInfo(r.cache, r.Live)
 	if err != nil {
-		r.getLogger().WithError(err).Error("failed to find ffmpeg")
+		r.getLogger().WithError(err).Warn("failed to get live info, skip the post processing")
+		r.mirrorFiles(file)
 		return
 	}
-	cmdStr := strings.Trim(r.config.OnRecordFinished.CustomCommandline, "")
-	if len(cmdStr) > 0 {
-		tmpl, err := template.New("custom_commandline").Funcs(utils.GetFuncMap(r.config)).Parse(cmdStr)
-		if err != nil {
-			r.getLogger().WithError(err).Error("custom commandline parse failure")
-			return
-		}
+	// the flv may be deleted after converted
+	r.mirrorFiles(file, postProcess(ctx, r.config, r.getLogger(), info, file, startTime))
+}
 
-		obj, _ := r.cache.Get(r.Live)
-		info := obj.(*live.Info)
-
-		buf := new(bytes.Buffer)
-		if err := tmpl.Execute(buf, struct {
-			*live.Info
-			FileName string
-			Ffmpeg   string
-		}{
-			Info:     info,
-			FileName: fileName,
-			Ffmpeg:   ffmpegPath,
-		}); err != nil {
-			r.getLogger().WithError(err).Errorln("failed to render custom commandline")
-			return
+// mirrorFiles mirrors the existing ones of the files.
+func (r *recorder) mirrorFiles(files ...string) {
+	if len(r.config.MirrorOutputPaths) == 0 {
+		return
+	}
+	for _, file := range files {
+		if file != "" {
+			r.mirrorFile(file)
 		}
-		bash := ""
-		args := []string{}
-		switch runtime.GOOS {
-		case "linux":
-			bash = "sh"
-			args = []string{"-c"}
-		case "windows":
-			bash = "cmd"
-			args = []string{"/C"}
-		default:
-			r.getLogger().Warnln("Unsupport system ", runtime.GOOS)
+	}
+}
+
+// mirrorFile copies the file into the mirror output paths and dispatches RecordFileMirrored,
+// the failures are logged only, the file in the out put path is kept anyway.
+func (r *recorder) mirrorFile(file string) {
+	if _, err := os.Stat(file); os.IsNotExist(err) {
+		return
+	}
+	results := mirrorFile(r.OutPutPath, r.config.MirrorOutputPaths, file)
+	for _, result := range results {
+		if result.Err != nil {
+			r.getLogger().WithError(result.Err).Errorf("failed to mirror %s to %s", file, result.File)
+		} else {
+			r.getLogger().Infof("mirrored %s to %s", file, result.File)
 		}
-		args = append(args, buf.String())
-		r.getLogger().Debugf("start executing custom_commandline: %s", args[1])
-		cmd := exec.Command(bash, args...)
-		if r.config.Debug {
-			cmd.Stdout = os.Stdout
-			cmd.Stderr = os.Stderr
+	}
+	r.ed.DispatchEvent(events.NewEvent(RecordFileMirrored, RecordFileMirroredParam{
+		Live:    r.Live,
+		File:    file,
+		Results: results,
+	}))
+}
+
+// verifyFile dispatches RecordFileSuspect when the file is likely corrupt.
+func (r *recorder) verifyFile(ctx context.Context, file string, wallTime time.Duration) {
+	if _, err := os.Stat(file); os.IsNotExist(err) {
+		// the empty file is removed already
+		return
+	}
+	ffmpegPath, err := utils.GetFFmpegPath(ctx)
+	if err != nil {
+		r.getLogger().WithError(err).Warn("failed to find ffmpeg, skip verifying the recording")
+		return
+	}
+	reason, err := verifyRecording(ctx, ffmpegPath, file, wallTime)
+	if err != nil {
+		r.getLogger().WithError(err).WithField("file", file).Warn("skip verifying the recording")
+		return
+	}
+	if reason == "" {
+		return
+	}
+	r.getLogger().WithField("file", file).Errorf("the recording is likely corrupt: %s", reason)
+	r.ed.DispatchEvent(events.NewEvent(RecordFileSuspect, RecordFileSuspectParam{
+		Live:   r.Live,
+		File:   file,
+		Reason: reason,
+	}))
+}
+
+// nextStreamUrls returns the urls resolved before the last one expired, or the alternatives of the
+// failed stream url if any, so that the recording continues from another cdn at once, otherwise
+// resolves the urls again.
+func (r *recorder) nextStreamUrls() ([]*url.URL, error) {
+	if urls := r.refreshedUrls; len(urls) > 0 {
+		r.refreshedUrls = nil
+		// the flv connection may outlive the fresh urls as well
+		if expirer, ok := r.Live.(live.StreamUrlExpirer); !ok || !urlExpired(expirer, urls[0]) {
+			return urls, nil
 		}
-		if err = cmd.Run(); err != nil {
-			r.getLogger().WithError(err).Debugf("custom commandline execute failure (%s %s)\n", bash, strings.Join(args, " "))
-		} else if r.config.OnRecordFinished.DeleteFlvAfterConvert {
-			os.Remove(fileName)
+	}
+	if urls := r.alternativeUrls; len(urls) > 0 {
+		r.alternativeUrls = nil
+		r.getLogger().Infof("the stream failed, switch to the alternative %s", urls[0].Host)
+		return urls, nil
+	}
+	return r.Live.GetStreamUrls()
+}
+
+// keepAlternatives keeps the urls after the one failed for nextStreamUrls.
+func (r *recorder) keepAlternatives(urls []*url.URL, parseErr error) {
+	r.alternativeUrls = nil
+	if parseErr != nil && len(urls) > 1 {
+		r.alternativeUrls = urls[1:]
+	}
+}
+
+// notifyFileStarted dispatches RecordFileStarted once the file has data,
+// nothing is dispatched if the parser exits before that.
+func (r *recorder) notifyFileStarted(url *url.URL, fileName string, done <-chan struct{}) {
+	ticker := time.NewTicker(fileStartedCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if fi, err := os.Stat(fileName); err == nil && fi.Size() > 0 {
+				r.recordingFile.set(fileName)
+				r.ed.DispatchEvent(events.NewEvent(RecordFileStarted, RecordFileStartedParam{
+					Live:      r.Live,
+					File:      fileName,
+					StreamUrl: url,
+				}))
+				return
+			}
 		}
-		r.getLogger().Debugf("end executing custom_commandline: %s", args[1])
-	} else if r.config.OnRecordFinished.ConvertToMp4 {
-		//格式转换时去除原本后缀名
-		newFileName := fileName[0:strings.LastIndex(fileName, ".")]
-		convertCmd := exec.Command(
-			ffmpegPath,
-			"-hide_banner",
-			"-i",
-			fileName,
-			"-c",
-			"copy",
-			newFileName+".mp4",
-		)
-		if err = convertCmd.Run(); err != nil {
-			convertCmd.Process.Kill()
-			r.getLogger().Debugln(err)
-		} else if r.config.OnRecordFinished.DeleteFlvAfterConvert {
-			os.Remove(fileName)
+	}
+}
+
+// skipEncryptedStream reports whether the stream is encrypted, which can not be recorded,
+// it waits for unsupportedRecheckInterval instead of retrying, and only the first detection is notified.
+func (r *recorder) skipEncryptedStream(u *url.URL) bool {
+	encrypted, err := probeEncryptedHLS(u, r.Live.GetHeadersForDownloader())
+	if err != nil {
+		// leave it to the parser
+		r.getLogger().WithError(err).Debug("failed to probe the encryption of the hls stream")
+		return false
+	}
+	if !encrypted {
+		r.unsupported.Store("")
+		return false
+	}
+	if r.UnsupportedReason() == "" {
+		r.unsupported.Store(ErrEncryptedStream.Error())
+		r.getLogger().Error(ErrEncryptedStream)
+		r.ed.DispatchEvent(events.NewEvent(RecorderStreamUnsupported, StreamUnsupportedParam{
+			Live:   r.Live,
+			Reason: ErrEncryptedStream.Error(),
+		}))
+	}
+	select {
+	case <-r.stop:
+	case <-time.After(unsupportedRecheckInterval):
+	}
+	return true
+}
+
+// UnsupportedReason returns why the stream can not be recorded, empty if it can.
+func (r *recorder) UnsupportedReason() string {
+	reason, _ := r.unsupported.Load().(string)
+	return reason
+}
+
+// RecordingFile returns the file being written, or the last written one after the recording is stopped.
+func (r *recorder) RecordingFile() string {
+	return r.recordingFile.getPath()
+}
+
+// StreamUrl returns the url of the stream being recorded, or the last one, nil before recording.
+func (r *recorder) StreamUrl() *url.URL {
+	u, _ := r.streamUrl.Load().(*url.URL)
+	return u
+}
+
+// RecordingFileMD5 returns the md5 of the recording file, which is hashed by the parser while
+// writing, the file is only read when the parser doesn't know it.
+func (r *recorder) RecordingFileMD5() (string, error) {
+	if p, ok := r.getParser().(parser.HashParser); ok {
+		if file, sum := p.MD5(); sum != "" && file == r.recordingFile.getPath() {
+			return sum, nil
 		}
 	}
+	return r.recordingFile.md5()
 }
 
 func (r *recorder) run(ctx context.Context) {
@@ -288,7 +512,7 @@ func (r *recorder) Close() {
 		}
 	}
 	r.getLogger().Info("Record End")
-	r.ed.DispatchEvent(events.NewEvent(RecorderStop, r.Live))
+	r.ed.DispatchEvent(events.NewEvent(RecorderStop, RecorderStopParam{Live: r.Live, Reason: r.stopReason}))
 }
 
 func (r *recorder) getLogger() *logrus.Entry {
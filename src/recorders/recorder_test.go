@@ -0,0 +1,106 @@
+package recorders
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/bluele/gcache"
+	"github.com/golang/mock/gomock"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hr3lxphr6j/bililive-go/src/configs"
+	"github.com/hr3lxphr6j/bililive-go/src/interfaces"
+	"github.com/hr3lxphr6j/bililive-go/src/live"
+	"github.com/hr3lxphr6j/bililive-go/src/live/mock"
+	"github.com/hr3lxphr6j/bililive-go/src/pkg/events"
+	evtmock "github.com/hr3lxphr6j/bililive-go/src/pkg/events/mock"
+)
+
+func TestDefaultFileNameTmplWithNickName(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	l := mock.NewMockLive(ctrl)
+	l.EXPECT().GetPlatformCNName().Return("platform").AnyTimes()
+
+	info := &live.Info{Live: l, HostName: "host", RoomName: "room", NickName: "nick"}
+	buf := new(bytes.Buffer)
+	assert.NoError(t, getDefaultFileNameTmpl(configs.NewConfig()).Execute(buf, info))
+	assert.Contains(t, buf.String(), "platform/nick/")
+	assert.Contains(t, buf.String(), "[nick][room].flv")
+	assert.NotContains(t, buf.String(), "host")
+}
+
+func TestNotifyFileStarted(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	backup := fileStartedCheckInterval
+	fileStartedCheckInterval = 10 * time.Millisecond
+	defer func() { fileStartedCheckInterval = backup }()
+
+	f, err := ioutil.TempFile("", "record-*.flv")
+	assert.NoError(t, err)
+	f.Close()
+	defer os.Remove(f.Name())
+
+	l := mock.NewMockLive(ctrl)
+	ed := evtmock.NewMockDispatcher(ctrl)
+	r := &recorder{Live: l, ed: ed}
+	u, _ := url.Parse("https://example.com/live.flv")
+
+	// the parser exits before any data is written
+	done := make(chan struct{})
+	close(done)
+	r.notifyFileStarted(u, f.Name(), done)
+
+	dispatched := make(chan *events.Event, 1)
+	ed.EXPECT().DispatchEvent(gomock.Any()).Do(func(e *events.Event) { dispatched <- e })
+	go r.notifyFileStarted(u, f.Name(), make(chan struct{}))
+	time.Sleep(30 * time.Millisecond)
+	assert.Len(t, dispatched, 0)
+	assert.NoError(t, ioutil.WriteFile(f.Name(), []byte("FLV"), 0644))
+	select {
+	case e := <-dispatched:
+		assert.Equal(t, RecordFileStarted, e.Type)
+		assert.Equal(t, RecordFileStartedParam{Live: l, File: f.Name(), StreamUrl: u}, e.Object)
+	case <-time.After(time.Second):
+		t.Fatal("RecordFileStarted is not dispatched")
+	}
+}
+
+func TestStreamUrlFailover(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	u1, _ := url.Parse("https://cdn1.example.com/live.flv")
+	u2, _ := url.Parse("https://cdn2.example.com/live.flv")
+	u3, _ := url.Parse("https://cdn3.example.com/live.flv")
+	l := mock.NewMockLive(ctrl)
+	l.EXPECT().GetStreamUrls().Return([]*url.URL{u1, u2, u3}, nil).Times(2)
+	r := &recorder{
+		Live:   l,
+		cache:  gcache.New(4).LRU().Build(),
+		logger: &interfaces.Logger{Logger: logrus.New()},
+	}
+
+	urls, err := r.nextStreamUrls()
+	assert.NoError(t, err)
+	assert.Equal(t, []*url.URL{u1, u2, u3}, urls)
+	r.keepAlternatives(urls, errors.New("EOF"))
+	urls, _ = r.nextStreamUrls()
+	assert.Equal(t, []*url.URL{u2, u3}, urls)
+	r.keepAlternatives(urls, errors.New("EOF"))
+	urls, _ = r.nextStreamUrls()
+	assert.Equal(t, []*url.URL{u3}, urls)
+	// no alternative left, resolve again
+	r.keepAlternatives(urls, errors.New("EOF"))
+	urls, _ = r.nextStreamUrls()
+	assert.Equal(t, []*url.URL{u1, u2, u3}, urls)
+	// ended normally
+	r.keepAlternatives(urls, nil)
+	assert.Empty(t, r.alternativeUrls)
+}
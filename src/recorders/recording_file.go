@@ -0,0 +1,76 @@
+package recorders
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// recordingFile tracks the file being written by the recorder, the md5 of it is
+// kept until the file is modified.
+type recordingFile struct {
+	sync.Mutex
+	path    string
+	sum     string
+	size    int64
+	modTime time.Time
+}
+
+func (f *recordingFile) set(path string) {
+	f.Lock()
+	defer f.Unlock()
+	f.path = path
+	f.reset()
+}
+
+func (f *recordingFile) reset() {
+	f.sum = ""
+	f.size = 0
+	f.modTime = time.Time{}
+}
+
+func (f *recordingFile) getPath() string {
+	f.Lock()
+	defer f.Unlock()
+	return f.path
+}
+
+// md5 returns the md5 of the current content of the file, for the file not hashed by the parser.
+// It's hashed again from the beginning whenever the file is modified, as the muxer may rewrite
+// the head after appending more data, e.g. the flv header and the metadata are rewritten on close.
+// The file is read without holding the lock.
+func (f *recordingFile) md5() (string, error) {
+	f.Lock()
+	path, sum, size, modTime := f.path, f.sum, f.size, f.modTime
+	f.Unlock()
+	if path == "" {
+		return "", os.ErrNotExist
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if sum != "" && fi.Size() == size && fi.ModTime().Equal(modTime) {
+		return sum, nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	h := md5.New()
+	n, err := io.CopyN(h, file, fi.Size())
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	sum = hex.EncodeToString(h.Sum(nil))
+	f.Lock()
+	if f.path == path {
+		f.sum, f.size, f.modTime = sum, n, fi.ModTime()
+	}
+	f.Unlock()
+	return sum, nil
+}
@@ -0,0 +1,105 @@
+package recorders
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hr3lxphr6j/bililive-go/src/pkg/parser"
+)
+
+func fileMD5(t *testing.T, path string) string {
+	b, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	sum := md5.Sum(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestRecordingFileMD5(t *testing.T) {
+	f, err := ioutil.TempFile("", "recording-*.flv")
+	assert.NoError(t, err)
+	defer os.Remove(f.Name())
+
+	rf := new(recordingFile)
+	_, err = rf.md5()
+	assert.Error(t, err)
+	rf.set(f.Name())
+	assert.Equal(t, f.Name(), rf.getPath())
+
+	// appended while recording
+	for _, chunk := range []string{"FLV\x01", "tag1", "tag2"} {
+		_, err = f.WriteString(chunk)
+		assert.NoError(t, err)
+		sum, err := rf.md5()
+		assert.NoError(t, err)
+		assert.Equal(t, fileMD5(t, f.Name()), sum)
+	}
+
+	// the header is rewritten on close without growing the file
+	_, err = f.WriteAt([]byte("flv\x05"), 0)
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+	later := time.Now().Add(time.Second)
+	assert.NoError(t, os.Chtimes(f.Name(), later, later))
+	sum, err := rf.md5()
+	assert.NoError(t, err)
+	assert.Equal(t, fileMD5(t, f.Name()), sum)
+
+	// the header is rewritten after more data is appended, both the size and the mtime change
+	f, err = os.OpenFile(f.Name(), os.O_WRONLY|os.O_APPEND, 0644)
+	assert.NoError(t, err)
+	_, err = f.WriteString("tag3")
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+	f, err = os.OpenFile(f.Name(), os.O_WRONLY, 0644)
+	assert.NoError(t, err)
+	_, err = f.WriteAt([]byte("FLV\x09"), 0)
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+	later = later.Add(time.Second)
+	assert.NoError(t, os.Chtimes(f.Name(), later, later))
+	sum, err = rf.md5()
+	assert.NoError(t, err)
+	assert.Equal(t, fileMD5(t, f.Name()), sum)
+	// not modified since
+	sum2, err := rf.md5()
+	assert.NoError(t, err)
+	assert.Equal(t, sum, sum2)
+}
+
+type hashParser struct {
+	parser.Parser
+	file, sum string
+}
+
+func (p *hashParser) MD5() (string, string) {
+	return p.file, p.sum
+}
+
+func TestRecordingFileMD5FromParser(t *testing.T) {
+	f, err := ioutil.TempFile("", "recording-*.flv")
+	assert.NoError(t, err)
+	defer os.Remove(f.Name())
+	_, err = f.WriteString("FLV\x01")
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+
+	p := &hashParser{file: f.Name(), sum: "hashed while writing"}
+	r := &recorder{parser: p, parserLock: new(sync.RWMutex)}
+	r.recordingFile.set(f.Name())
+	sum, err := r.RecordingFileMD5()
+	assert.NoError(t, err)
+	assert.Equal(t, "hashed while writing", sum)
+
+	// unknown to the parser, e.g. the head is rewritten
+	p.sum = ""
+	sum, err = r.RecordingFileMD5()
+	assert.NoError(t, err)
+	assert.Equal(t, fileMD5(t, f.Name()), sum)
+}
@@ -0,0 +1,121 @@
+package recorders
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hr3lxphr6j/bililive-go/src/pkg/utils"
+)
+
+const (
+	// bounds the thumbnails of a very long stream, e.g. about 16 hours with the interval of 60s
+	maxPeriodicThumbnails = 1000
+	thumbnailHeight       = 180
+	thumbnailIndexFile    = "index.json"
+)
+
+// Thumbnail is a frame captured during recording, listed in the index.json of the thumbnail dir.
+type Thumbnail struct {
+	File      string    `json:"file"`       // relative to the thumbnail dir
+	OffsetSec int64     `json:"offset_sec"` // since the start of the recording
+	Time      time.Time `json:"time"`
+}
+
+// for test
+var (
+	thumbnailCaptureTimeout = 30 * time.Second
+	captureThumbnail        = func(ctx context.Context, ffmpegPath string, u *url.URL, headers map[string]string, file string) error {
+		stderr := new(bytes.Buffer)
+		cmd := exec.CommandContext(ctx, ffmpegPath, thumbnailArgs(u, headers, file)...)
+		cmd.Stderr = stderr
+		// the thumbnails should never slow the recordings down
+		_ = utils.ApplyProcessPriority(cmd, 19, 0)
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("%v: %s", err, strings.TrimSpace(stderr.String()))
+		}
+		return nil
+	}
+)
+
+// thumbnailArgs are the args of ffmpeg to save a scaled down frame of the stream as a jpeg.
+func thumbnailArgs(u *url.URL, headers map[string]string, file string) []string {
+	args := []string{"-hide_banner", "-v", "error", "-nostdin"}
+	args = append(args, utils.FFmpegHeaderArgs(headers)...)
+	return append(args,
+		"-i", u.String(),
+		"-frames:v", "1",
+		"-vf", fmt.Sprintf("scale=-2:%d", thumbnailHeight),
+		"-q:v", "5",
+		"-y", file,
+	)
+}
+
+// thumbnailDir is where the thumbnails of the recording are stored, next to the file.
+func thumbnailDir(fileName string) string {
+	return strings.TrimSuffix(fileName, filepath.Ext(fileName)) + "_thumbnails"
+}
+
+// writeThumbnailIndex replaces the index of the thumbnail dir, through a temp file so that it's never partial.
+func writeThumbnailIndex(dir string, thumbnails []Thumbnail) error {
+	b, err := json.MarshalIndent(thumbnails, "", "  ")
+	if err != nil {
+		return err
+	}
+	file := filepath.Join(dir, thumbnailIndexFile)
+	if err = ioutil.WriteFile(file+".tmp", b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(file+".tmp", file)
+}
+
+// captureThumbnails captures a frame of the stream in the interval until the parser exits,
+// by a side ffmpeg reading the stream url, the failed captures are skipped.
+func (r *recorder) captureThumbnails(ctx context.Context, u *url.URL, fileName string, interval time.Duration, done <-chan struct{}) {
+	ffmpegPath, err := utils.GetFFmpegPath(ctx)
+	if err != nil {
+		r.getLogger().WithError(err).Warn("failed to find ffmpeg, the thumbnails are not captured")
+		return
+	}
+	dir := thumbnailDir(fileName)
+	startTime := time.Now()
+	thumbnails := make([]Thumbnail, 0)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for len(thumbnails) < maxPeriodicThumbnails {
+		select {
+		case <-done:
+			return
+		case now := <-ticker.C:
+			if err := mkdir(dir); err != nil {
+				r.getLogger().WithError(err).Warnf("failed to create thumbnail dir[%s]", dir)
+				return
+			}
+			name := fmt.Sprintf("%06d.jpg", len(thumbnails)+1)
+			captureCtx, cancel := context.WithTimeout(ctx, thumbnailCaptureTimeout)
+			err := captureThumbnail(captureCtx, ffmpegPath, u, r.Live.GetHeadersForDownloader(), filepath.Join(dir, name))
+			cancel()
+			if err != nil {
+				r.getLogger().WithError(err).Debug("failed to capture thumbnail")
+				continue
+			}
+			thumbnails = append(thumbnails, Thumbnail{
+				File:      name,
+				OffsetSec: int64(now.Sub(startTime) / time.Second),
+				Time:      now,
+			})
+			if err := writeThumbnailIndex(dir, thumbnails); err != nil {
+				r.getLogger().WithError(err).Warn("failed to write thumbnail index")
+			}
+		}
+	}
+	r.getLogger().Infof("%d thumbnails are captured, stop capturing more", maxPeriodicThumbnails)
+}
@@ -0,0 +1,92 @@
+package recorders
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bluele/gcache"
+	"github.com/golang/mock/gomock"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hr3lxphr6j/bililive-go/src/configs"
+	"github.com/hr3lxphr6j/bililive-go/src/instance"
+	"github.com/hr3lxphr6j/bililive-go/src/interfaces"
+	"github.com/hr3lxphr6j/bililive-go/src/live/mock"
+)
+
+func TestCaptureThumbnails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	root, err := ioutil.TempDir("", "thumbnail")
+	assert.NoError(t, err)
+	defer os.RemoveAll(root)
+	u, _ := url.Parse("https://example.com/live.flv")
+	l := mock.NewMockLive(ctrl)
+	l.EXPECT().GetHeadersForDownloader().Return(nil).AnyTimes()
+	cfg := configs.NewConfig()
+	cfg.FfmpegPath = os.Args[0]
+	ctx := context.WithValue(context.Background(), instance.Key, &instance.Instance{Config: cfg})
+	r := &recorder{
+		Live:   l,
+		config: cfg,
+		cache:  gcache.New(4).LRU().Build(),
+		logger: &interfaces.Logger{Logger: logrus.New()},
+	}
+
+	backup := captureThumbnail
+	defer func() { captureThumbnail = backup }()
+	calls, captured := 0, make(chan string, 4)
+	captureThumbnail = func(_ context.Context, ffmpegPath string, got *url.URL, _ map[string]string, file string) error {
+		calls++
+		assert.Equal(t, os.Args[0], ffmpegPath)
+		assert.Equal(t, u, got)
+		if calls == 1 {
+			return errors.New("no frame")
+		}
+		captured <- file
+		return ioutil.WriteFile(file, []byte("jpg"), 0644)
+	}
+
+	fileName := filepath.Join(root, "a.flv")
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		r.captureThumbnails(ctx, u, fileName, 10*time.Millisecond, done)
+		close(finished)
+	}()
+	dir := filepath.Join(root, "a_thumbnails")
+	for _, name := range []string{"000001.jpg", "000002.jpg"} {
+		select {
+		case file := <-captured:
+			assert.Equal(t, filepath.Join(dir, name), file)
+		case <-time.After(time.Second):
+			t.Fatal("thumbnail is not captured")
+		}
+	}
+	close(done)
+	<-finished
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, thumbnailIndexFile))
+	assert.NoError(t, err)
+	var thumbnails []Thumbnail
+	assert.NoError(t, json.Unmarshal(b, &thumbnails))
+	assert.GreaterOrEqual(t, len(thumbnails), 2)
+	assert.Equal(t, "000001.jpg", thumbnails[0].File)
+	assert.Equal(t, "000002.jpg", thumbnails[1].File)
+}
+
+func TestThumbnailArgs(t *testing.T) {
+	u, _ := url.Parse("https://example.com/live.flv")
+	args := thumbnailArgs(u, map[string]string{"Referer": "https://example.com"}, "/tmp/000001.jpg")
+	assert.Equal(t, []string{"-hide_banner", "-v", "error", "-nostdin",
+		"-referer", "https://example.com", "-i", "https://example.com/live.flv", "-frames:v", "1"}, args[:10])
+	assert.Equal(t, "/tmp/000001.jpg", args[len(args)-1])
+}
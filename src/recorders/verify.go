@@ -0,0 +1,78 @@
+package recorders
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+var errVerifyTimeout = errors.New("the verification timed out")
+
+// the recorded duration shorter than this ratio of the wall time is considered truncated
+const minRecordedDurationRatio = 0.5
+
+// the progress line of ffmpeg, e.g. "frame= 100 fps=0.0 ... time=00:01:02.50 bitrate=..."
+var ffmpegTimeRegexp = regexp.MustCompile(`time=(\d+):(\d{2}):(\d{2}(?:\.\d+)?)`)
+
+// for test
+var (
+	// the verification of the huge recording is given up rather than hogging the disk
+	verifyTimeout = 10 * time.Minute
+
+	// decodeRecording reads all the packets of the file by copying the streams into nothing,
+	// which catches the broken container and the truncation without the cost of decoding.
+	decodeRecording = func(ctx context.Context, ffmpegPath, file string) (stderr []byte, err error) {
+		buf := new(bytes.Buffer)
+		cmd := exec.CommandContext(ctx, ffmpegPath, "-hide_banner", "-v", "error", "-stats",
+			"-i", file, "-map", "0", "-c", "copy", "-f", "null", "-")
+		cmd.Stderr = buf
+		err = cmd.Run()
+		return buf.Bytes(), err
+	}
+)
+
+// parseDecodedDuration returns the duration of the last progress line in the output of ffmpeg.
+func parseDecodedDuration(output []byte) (time.Duration, bool) {
+	matches := ffmpegTimeRegexp.FindAllSubmatch(output, -1)
+	if len(matches) == 0 {
+		return 0, false
+	}
+	m := matches[len(matches)-1]
+	hours, _ := strconv.Atoi(string(m[1]))
+	minutes, _ := strconv.Atoi(string(m[2]))
+	seconds, _ := strconv.ParseFloat(string(m[3]), 64)
+	return time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute +
+		time.Duration(seconds*float64(time.Second)), true
+}
+
+// verifyRecording reads the whole file and checks that it's readable and its duration
+// roughly matches the wall time of the recording, a non-empty reason is returned when not.
+// errVerifyTimeout is returned when it takes longer than verifyTimeout, there is no verdict then.
+func verifyRecording(ctx context.Context, ffmpegPath, file string, wallTime time.Duration) (reason string, err error) {
+	if stat, err := os.Stat(file); err != nil || stat.Size() == 0 {
+		return "empty file", nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, verifyTimeout)
+	defer cancel()
+	output, err := decodeRecording(ctx, ffmpegPath, file)
+	if ctx.Err() == context.DeadlineExceeded {
+		return "", errVerifyTimeout
+	}
+	if err != nil {
+		return fmt.Sprintf("failed to decode: %v", err), nil
+	}
+	duration, ok := parseDecodedDuration(output)
+	if !ok || duration <= 0 {
+		return "no decodable frame", nil
+	}
+	if float64(duration) < float64(wallTime)*minRecordedDurationRatio {
+		return fmt.Sprintf("duration %s is much shorter than the recording time %s", duration.Round(time.Second), wallTime.Round(time.Second)), nil
+	}
+	return "", nil
+}
@@ -0,0 +1,74 @@
+package recorders
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDecodedDuration(t *testing.T) {
+	d, ok := parseDecodedDuration([]byte("frame=  10 time=00:00:01.00 bitrate=N/A\rframe= 100 time=01:02:03.50 bitrate=N/A\n"))
+	assert.True(t, ok)
+	assert.Equal(t, time.Hour+2*time.Minute+3500*time.Millisecond, d)
+	_, ok = parseDecodedDuration([]byte("Invalid data found when processing input\n"))
+	assert.False(t, ok)
+}
+
+func TestVerifyRecording(t *testing.T) {
+	f, err := ioutil.TempFile("", "verify_*.flv")
+	assert.NoError(t, err)
+	defer os.Remove(f.Name())
+	f.Close()
+	reason, err := verifyRecording(context.Background(), "ffmpeg", f.Name(), time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, "empty file", reason)
+	assert.NoError(t, ioutil.WriteFile(f.Name(), []byte("FLV"), 0644))
+
+	backup := decodeRecording
+	defer func() { decodeRecording = backup }()
+	var (
+		output    string
+		decodeErr error
+	)
+	decodeRecording = func(context.Context, string, string) ([]byte, error) {
+		return []byte(output), decodeErr
+	}
+
+	verify := func(wallTime time.Duration) string {
+		reason, err := verifyRecording(context.Background(), "ffmpeg", f.Name(), wallTime)
+		assert.NoError(t, err)
+		return reason
+	}
+
+	output = "frame= 100 time=00:00:58.00 bitrate=N/A\n"
+	assert.Empty(t, verify(time.Minute))
+	assert.Contains(t, verify(time.Hour), "much shorter")
+	output = ""
+	assert.Equal(t, "no decodable frame", verify(time.Minute))
+	decodeErr = errors.New("exit status 1")
+	assert.Contains(t, verify(time.Minute), "failed to decode")
+}
+
+func TestVerifyRecordingTimeout(t *testing.T) {
+	f, err := ioutil.TempFile("", "verify_*.flv")
+	assert.NoError(t, err)
+	defer os.Remove(f.Name())
+	f.WriteString("FLV")
+	f.Close()
+
+	backupDecode, backupTimeout := decodeRecording, verifyTimeout
+	defer func() { decodeRecording, verifyTimeout = backupDecode, backupTimeout }()
+	verifyTimeout = 10 * time.Millisecond
+	decodeRecording = func(ctx context.Context, _, _ string) ([]byte, error) {
+		<-ctx.Done()
+		return nil, errors.New("signal: killed")
+	}
+	reason, err := verifyRecording(context.Background(), "ffmpeg", f.Name(), time.Minute)
+	assert.Equal(t, errVerifyTimeout, err)
+	assert.Empty(t, reason)
+}
@@ -0,0 +1,59 @@
+package recorders
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/hr3lxphr6j/bililive-go/src/configs"
+	"github.com/hr3lxphr6j/bililive-go/src/pkg/events"
+)
+
+// for test
+var (
+	// the recorder waits for the manager to act on the write failure for this long before retrying
+	writeFailRetryInterval = 30 * time.Second
+)
+
+// isDiskWriteError reports whether the parser failed for writing the file, e.g. the disk is full.
+// ffmpeg only tells it in the message, where "Input/output error" is likely of reading the stream,
+// so only the disk full is recognized from it.
+func isDiskWriteError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, syscall.ENOSPC) || errors.Is(err, syscall.EIO) {
+		return true
+	}
+	return strings.Contains(err.Error(), "No space left on device")
+}
+
+// handleWriteFailure acts on the write failure of the file by the write_fail_policy, the switch
+// policy is taken by the recorder itself, the others are left to the manager by RecordWriteFailed.
+func (r *recorder) handleWriteFailure(file string, err error) {
+	policy := r.config.DiskSpace.WriteFailPolicy
+	if policy == "" {
+		return
+	}
+	param := RecordWriteFailedParam{Live: r.Live, File: file, Err: err, Policy: policy}
+	if policy == configs.WriteFailPolicySwitch {
+		fallback := r.config.DiskSpace.FallbackOutputPath
+		if filepath.Clean(r.OutPutPath) != filepath.Clean(fallback) {
+			r.getLogger().WithError(err).Warnf("failed to write %s, switch to the fallback output path %s", file, fallback)
+			r.OutPutPath, param.OutputPath = fallback, fallback
+			r.ed.DispatchEvent(events.NewEvent(RecordWriteFailed, param))
+			return
+		}
+		// the fallback path fails too
+		param.Policy = configs.WriteFailPolicyStop
+	}
+	r.getLogger().WithError(err).Errorf("failed to write %s, the write_fail_policy %s is taken", file, param.Policy)
+	r.ed.DispatchEvent(events.NewEvent(RecordWriteFailed, param))
+	// don't churn while the manager stops the recorder
+	select {
+	case <-r.stop:
+	case <-time.After(writeFailRetryInterval):
+	}
+}
@@ -0,0 +1,72 @@
+package recorders
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/bluele/gcache"
+	"github.com/golang/mock/gomock"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hr3lxphr6j/bililive-go/src/configs"
+	"github.com/hr3lxphr6j/bililive-go/src/interfaces"
+	"github.com/hr3lxphr6j/bililive-go/src/live/mock"
+	"github.com/hr3lxphr6j/bililive-go/src/pkg/events"
+	evtmock "github.com/hr3lxphr6j/bililive-go/src/pkg/events/mock"
+)
+
+func TestIsDiskWriteError(t *testing.T) {
+	assert.False(t, isDiskWriteError(nil))
+	assert.False(t, isDiskWriteError(errors.New("EOF")))
+	assert.True(t, isDiskWriteError(&os.PathError{Op: "write", Path: "a.flv", Err: syscall.ENOSPC}))
+	assert.True(t, isDiskWriteError(fmt.Errorf("failed to write: %w", syscall.EIO)))
+	assert.True(t, isDiskWriteError(errors.New("exit status 1: av_interleaved_write_frame(): No space left on device")))
+	// likely of reading the stream
+	assert.False(t, isDiskWriteError(errors.New("exit status 1: https://example.com/live.flv: Input/output error")))
+}
+
+func TestHandleWriteFailure(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	backup := writeFailRetryInterval
+	defer func() { writeFailRetryInterval = backup }()
+	writeFailRetryInterval = 10 * time.Millisecond
+
+	l := mock.NewMockLive(ctrl)
+	ed := evtmock.NewMockDispatcher(ctrl)
+	cfg := configs.NewConfig()
+	cfg.DiskSpace.WriteFailPolicy = configs.WriteFailPolicySwitch
+	cfg.DiskSpace.FallbackOutputPath = "/mnt/fallback"
+	r := &recorder{
+		Live:       l,
+		OutPutPath: "/mnt/output",
+		config:     cfg,
+		ed:         ed,
+		cache:      gcache.New(4).LRU().Build(),
+		logger:     &interfaces.Logger{Logger: logrus.New()},
+		stop:       make(chan struct{}),
+	}
+	var params []RecordWriteFailedParam
+	ed.EXPECT().DispatchEvent(gomock.Any()).Do(func(e *events.Event) {
+		assert.Equal(t, RecordWriteFailed, e.Type)
+		params = append(params, e.Object.(RecordWriteFailedParam))
+	}).Times(2)
+
+	r.handleWriteFailure("/mnt/output/a.flv", syscall.ENOSPC)
+	assert.Equal(t, "/mnt/fallback", r.OutPutPath)
+	// the fallback path fails too
+	r.handleWriteFailure("/mnt/fallback/a.flv", syscall.ENOSPC)
+	assert.Equal(t, []RecordWriteFailedParam{
+		{Live: l, File: "/mnt/output/a.flv", Err: syscall.ENOSPC, Policy: configs.WriteFailPolicySwitch, OutputPath: "/mnt/fallback"},
+		{Live: l, File: "/mnt/fallback/a.flv", Err: syscall.ENOSPC, Policy: configs.WriteFailPolicyStop},
+	}, params)
+
+	// nothing is done without the policy
+	cfg.DiskSpace.WriteFailPolicy = ""
+	r.handleWriteFailure("/mnt/fallback/a.flv", syscall.ENOSPC)
+}
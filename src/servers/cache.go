@@ -0,0 +1,51 @@
+package servers
+
+import (
+	"net/http"
+
+	"github.com/hr3lxphr6j/bililive-go/src/instance"
+	"github.com/hr3lxphr6j/bililive-go/src/live"
+)
+
+type cacheStats struct {
+	Size        int     `json:"size"`
+	HitCount    uint64  `json:"hit_count"`
+	MissCount   uint64  `json:"miss_count"`
+	LookupCount uint64  `json:"lookup_count"`
+	HitRate     float64 `json:"hit_rate"`
+}
+
+// getCacheStats reports the size and the hit stats of the live info cache.
+func getCacheStats(writer http.ResponseWriter, r *http.Request) {
+	cache := instance.GetInstance(r.Context()).Cache
+	writeJSON(writer, cacheStats{
+		Size:        cache.Len(false),
+		HitCount:    cache.HitCount(),
+		MissCount:   cache.MissCount(),
+		LookupCount: cache.LookupCount(),
+		HitRate:     cache.HitRate(),
+	})
+}
+
+// clearCache evicts the info of the live in the id query parameter, or all of them when it's empty,
+// the info is fetched again on the next access. The cache is safe for concurrent use, an in-flight
+// GetInfo may put the info back right after, which is fresh anyway.
+func clearCache(writer http.ResponseWriter, r *http.Request) {
+	inst := instance.GetInstance(r.Context())
+	cleared := 0
+	if id := r.URL.Query().Get("id"); id != "" {
+		l, ok := inst.Lives[live.ID(id)]
+		if !ok {
+			writeLiveNotFound(writer, id)
+			return
+		}
+		if inst.Cache.Remove(l) {
+			cleared++
+		}
+	} else {
+		cleared = inst.Cache.Len(false)
+		inst.Cache.Purge()
+	}
+	inst.Logger.Infof("%d entries are cleared from the live info cache", cleared)
+	writeJSON(writer, map[string]int{"cleared": cleared})
+}
@@ -0,0 +1,69 @@
+package servers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bluele/gcache"
+	"github.com/golang/mock/gomock"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hr3lxphr6j/bililive-go/src/instance"
+	"github.com/hr3lxphr6j/bililive-go/src/interfaces"
+	"github.com/hr3lxphr6j/bililive-go/src/live"
+	"github.com/hr3lxphr6j/bililive-go/src/live/mock"
+)
+
+func TestClearCache(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	l1, l2 := mock.NewMockLive(ctrl), mock.NewMockLive(ctrl)
+	cache := gcache.New(4).LRU().Build()
+	cache.Set(l1, &live.Info{Live: l1, RoomName: "stale"})
+	cache.Set(l2, &live.Info{Live: l2})
+	inst := &instance.Instance{
+		Lives:  map[live.ID]live.Live{"1": l1, "2": l2},
+		Cache:  cache,
+		Logger: &interfaces.Logger{Logger: logrus.New()},
+	}
+	do := func(handler http.HandlerFunc, method, target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(method, target, nil)
+		handler(w, r.WithContext(context.WithValue(r.Context(), instance.Key, inst)))
+		return w
+	}
+
+	w := do(getCacheStats, http.MethodGet, "/api/system/cache")
+	assert.Equal(t, http.StatusOK, w.Code)
+	stats := cacheStats{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &stats))
+	assert.Equal(t, 2, stats.Size)
+
+	w = do(clearCache, http.MethodPost, "/api/system/cache/clear?id=1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"cleared":1}`, w.Body.String())
+	assert.False(t, cache.Has(l1))
+	assert.True(t, cache.Has(l2))
+
+	// fetched again on the next access
+	l1.EXPECT().GetInfo().Return(&live.Info{Live: l1, RoomName: "fresh"}, nil)
+	info, err := live.GetCachedInfo(cache, l1)
+	assert.NoError(t, err)
+	assert.Equal(t, "fresh", info.RoomName)
+	assert.True(t, cache.Has(l1))
+
+	w = do(clearCache, http.MethodPost, "/api/system/cache/clear?id=3")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = do(clearCache, http.MethodPost, "/api/system/cache/clear")
+	assert.JSONEq(t, `{"cleared":2}`, w.Body.String())
+	assert.Equal(t, 0, cache.Len(false))
+	l2.EXPECT().GetInfo().Return(nil, errors.New("network error"))
+	_, err = live.GetCachedInfo(cache, l2)
+	assert.Error(t, err)
+}
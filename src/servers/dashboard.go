@@ -0,0 +1,108 @@
+package servers
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/hr3lxphr6j/bililive-go/src/instance"
+)
+
+// for test
+var storageUsageTTL = time.Minute
+
+// storageUsage is the usage of the out put path, the files are attributed to the day
+// by their modification time, which is when the recording of them ended.
+type storageUsage struct {
+	Path          string    `json:"path"`
+	TotalBytes    int64     `json:"total_bytes"`
+	FileCount     int       `json:"file_count"`
+	TodayBytes    int64     `json:"today_bytes"`
+	ThisWeekBytes int64     `json:"this_week_bytes"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// storageUsageCache caches the walk of the out put path, it's expensive with many files.
+type storageUsageCache struct {
+	sync.Mutex
+	usage *storageUsage
+}
+
+var dashboardStorage = new(storageUsageCache)
+
+func (c *storageUsageCache) get(path string, now time.Time) (storageUsage, error) {
+	c.Lock()
+	defer c.Unlock()
+	if c.usage != nil && c.usage.Path == path && now.Sub(c.usage.UpdatedAt) < storageUsageTTL {
+		return *c.usage, nil
+	}
+	usage, err := walkStorageUsage(path, now)
+	if err != nil {
+		return usage, err
+	}
+	c.usage = &usage
+	return usage, nil
+}
+
+func walkStorageUsage(root string, now time.Time) (storageUsage, error) {
+	usage := storageUsage{Path: root, UpdatedAt: now}
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	// weeks start on monday
+	weekStart := today.AddDate(0, 0, -(int(today.Weekday())+6)%7)
+	if _, err := os.Stat(root); err != nil {
+		return usage, err
+	}
+	err := filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
+		if err != nil || fi.IsDir() {
+			// skip the unreadable ones instead of failing the whole walk
+			return nil
+		}
+		usage.TotalBytes += fi.Size()
+		usage.FileCount++
+		if !fi.ModTime().Before(weekStart) {
+			usage.ThisWeekBytes += fi.Size()
+		}
+		if !fi.ModTime().Before(today) {
+			usage.TodayBytes += fi.Size()
+		}
+		return nil
+	})
+	return usage, err
+}
+
+// getDashboardSummary returns the top-line numbers of the home page in one call.
+func getDashboardSummary(writer http.ResponseWriter, r *http.Request) {
+	inst := instance.GetInstance(r.Context())
+	summary := struct {
+		TotalRooms   int           `json:"total_rooms"`
+		Listening    int           `json:"listening"`
+		Live         int           `json:"live"`
+		Recording    int           `json:"recording"`
+		Reconnecting int           `json:"reconnecting"`
+		Storage      *storageUsage `json:"storage,omitempty"`
+		StorageError string        `json:"storage_error,omitempty"`
+	}{TotalRooms: len(inst.Lives)}
+	for _, l := range inst.Lives {
+		info := parseInfo(r.Context(), l)
+		if info.Listening {
+			summary.Listening++
+		}
+		if info.Status {
+			summary.Live++
+		}
+		if info.Recording {
+			summary.Recording++
+		}
+		if info.Reconnecting {
+			summary.Reconnecting++
+		}
+	}
+	if usage, err := dashboardStorage.get(inst.Config.OutPutPath, time.Now()); err != nil {
+		summary.StorageError = err.Error()
+	} else {
+		summary.Storage = &usage
+	}
+	writeJSON(writer, summary)
+}
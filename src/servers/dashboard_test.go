@@ -0,0 +1,52 @@
+package servers
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStorageUsage(t *testing.T) {
+	root, err := ioutil.TempDir("", "dashboard")
+	assert.NoError(t, err)
+	defer os.RemoveAll(root)
+	// a wednesday
+	now := time.Date(2021, 6, 9, 12, 0, 0, 0, time.Local)
+	write := func(name string, size int, modTime time.Time) {
+		file := filepath.Join(root, name)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(file), os.ModePerm))
+		assert.NoError(t, ioutil.WriteFile(file, make([]byte, size), 0644))
+		assert.NoError(t, os.Chtimes(file, modTime, modTime))
+	}
+	write("a/today.flv", 1, now.Add(-time.Hour))
+	write("a/monday.flv", 10, time.Date(2021, 6, 7, 1, 0, 0, 0, time.Local))
+	write("b/last_week.flv", 100, time.Date(2021, 6, 6, 23, 0, 0, 0, time.Local))
+
+	cache := new(storageUsageCache)
+	usage, err := cache.get(root, now)
+	assert.NoError(t, err)
+	assert.Equal(t, storageUsage{
+		Path:          root,
+		TotalBytes:    111,
+		FileCount:     3,
+		TodayBytes:    1,
+		ThisWeekBytes: 11,
+		UpdatedAt:     now,
+	}, usage)
+
+	// cached
+	write("b/new.flv", 1000, now)
+	usage, err = cache.get(root, now.Add(storageUsageTTL/2))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(111), usage.TotalBytes)
+	usage, err = cache.get(root, now.Add(storageUsageTTL))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1111), usage.TotalBytes)
+
+	_, err = cache.get(filepath.Join(root, "not_exist"), now)
+	assert.Error(t, err)
+}
@@ -0,0 +1,148 @@
+package servers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/hr3lxphr6j/bililive-go/src/instance"
+	"github.com/hr3lxphr6j/bililive-go/src/live"
+	"github.com/hr3lxphr6j/bililive-go/src/pkg/events"
+	"github.com/hr3lxphr6j/bililive-go/src/pkg/utils"
+	"github.com/hr3lxphr6j/bililive-go/src/recorders"
+)
+
+// Severities of the problems in the error feed.
+const (
+	SeverityWarning = "warning" // something is degraded, the recording goes on
+	SeverityError   = "error"   // something is lost or stopped, it needs attention
+)
+
+// the recent problems kept, the older ones are dropped
+const errorFeedSize = 200
+
+// problem is an entry of the error feed, Source is the event it's collected from.
+type problem struct {
+	Id       uint64    `json:"id"`
+	Time     time.Time `json:"time"`
+	Severity string    `json:"severity"`
+	Source   string    `json:"source"`
+	LiveId   live.ID   `json:"live_id,omitempty"`
+	Message  string    `json:"message"`
+}
+
+// errorFeed is a bounded ring of the recent problems collected from the events, unlike the
+// logs it's a curated list for the dashboard, the new problems are pushed over sse as well.
+type errorFeed struct {
+	sync.Mutex
+	entries []problem
+	next    int // where the next problem is written when the ring is full
+	lastId  uint64
+	hub     *sseHub
+}
+
+func newErrorFeed(hub *sseHub) *errorFeed {
+	return &errorFeed{entries: make([]problem, 0, errorFeedSize), hub: hub}
+}
+
+func (f *errorFeed) push(severity, source string, liveId live.ID, msg string) {
+	f.Lock()
+	f.lastId++
+	p := problem{
+		Id:       f.lastId,
+		Time:     time.Now(),
+		Severity: severity,
+		Source:   source,
+		LiveId:   liveId,
+		Message:  msg,
+	}
+	if len(f.entries) < errorFeedSize {
+		f.entries = append(f.entries, p)
+	} else {
+		f.entries[f.next] = p
+		f.next = (f.next + 1) % errorFeedSize
+	}
+	f.Unlock()
+	if f.hub != nil {
+		f.hub.broadcast("error_feed", p)
+	}
+}
+
+// list returns the problems of the severity, or all of them when it's empty, the newest first.
+func (f *errorFeed) list(severity string) []problem {
+	f.Lock()
+	defer f.Unlock()
+	problems := make([]problem, 0, len(f.entries))
+	for i := len(f.entries) - 1; i >= 0; i-- {
+		p := f.entries[(f.next+i)%len(f.entries)]
+		if severity == "" || p.Severity == severity {
+			problems = append(problems, p)
+		}
+	}
+	return problems
+}
+
+// the sources of the problems which are not collected from the events
+const (
+	sourcePlatformDegraded  = "PlatformDegraded"
+	sourcePlatformRecovered = "PlatformRecovered"
+)
+
+// registryListener collects the problems from the events of the dispatcher,
+// and the transitions of the circuit breakers of the platforms.
+func (f *errorFeed) registryListener(ctx context.Context) {
+	live.OnPlatformStatusChanged(func(s live.PlatformStatus) {
+		if s.Degraded {
+			f.push(SeverityError, sourcePlatformDegraded, "", fmt.Sprintf("the requests to %s keep failing, paused until %s: %s",
+				s.Platform, s.RetryAt.Format("2006-01-02 15:04:05"), s.LastError))
+		} else {
+			f.push(SeverityWarning, sourcePlatformRecovered, "", fmt.Sprintf("the requests to %s succeed again", s.Platform))
+		}
+	})
+	ed, ok := instance.GetInstance(ctx).EventDispatcher.(events.Dispatcher)
+	if !ok {
+		return
+	}
+	ed.AddEventListener(recorders.RecorderStreamUnsupported, events.NewEventListener(func(event *events.Event) {
+		param := event.Object.(recorders.StreamUnsupportedParam)
+		f.push(SeverityWarning, string(event.Type), param.Live.GetLiveId(), "the stream can not be recorded: "+param.Reason)
+	}))
+	ed.AddEventListener(recorders.ParserExited, events.NewEventListener(func(event *events.Event) {
+		param := event.Object.(recorders.ParserExitedParam)
+		f.push(SeverityWarning, string(event.Type), param.Live.GetLiveId(), fmt.Sprintf("the parser exited: %v", param.Err))
+	}))
+	ed.AddEventListener(recorders.RecordFileSuspect, events.NewEventListener(func(event *events.Event) {
+		param := event.Object.(recorders.RecordFileSuspectParam)
+		f.push(SeverityWarning, string(event.Type), param.Live.GetLiveId(), fmt.Sprintf("%s is likely corrupt: %s", param.File, param.Reason))
+	}))
+	ed.AddEventListener(recorders.RecordFileMirrored, events.NewEventListener(func(event *events.Event) {
+		param := event.Object.(recorders.RecordFileMirroredParam)
+		for _, result := range param.Results {
+			if result.Err != nil {
+				f.push(SeverityError, string(event.Type), param.Live.GetLiveId(), fmt.Sprintf("failed to mirror %s to %s: %v", param.File, result.File, result.Err))
+			}
+		}
+	}))
+	ed.AddEventListener(recorders.RecordWriteFailed, events.NewEventListener(func(event *events.Event) {
+		param := event.Object.(recorders.RecordWriteFailedParam)
+		f.push(SeverityError, string(event.Type), param.Live.GetLiveId(), fmt.Sprintf("failed to write %s, %s is taken: %v", param.File, param.Policy, param.Err))
+	}))
+	ed.AddEventListener(utils.DiskFull, events.NewEventListener(func(event *events.Event) {
+		space := event.Object.(utils.DiskSpace)
+		f.push(SeverityError, string(event.Type), "", fmt.Sprintf("the free space of %s is %d MB, all the recorders are paused", space.Path, space.FreeBytes/1024/1024))
+	}))
+}
+
+// getErrors lists the recent problems, only the ones of the severity query parameter when it's set.
+func (f *errorFeed) getErrors(writer http.ResponseWriter, r *http.Request) {
+	severity := r.URL.Query().Get("severity")
+	switch severity {
+	case "", SeverityWarning, SeverityError:
+	default:
+		writeError(writer, http.StatusBadRequest, ErrCodeInvalidParam, "invalid severity: "+severity)
+		return
+	}
+	writeJSON(writer, f.list(severity))
+}
@@ -0,0 +1,73 @@
+package servers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hr3lxphr6j/bililive-go/src/instance"
+	"github.com/hr3lxphr6j/bililive-go/src/live"
+	"github.com/hr3lxphr6j/bililive-go/src/live/mock"
+	"github.com/hr3lxphr6j/bililive-go/src/pkg/events"
+	"github.com/hr3lxphr6j/bililive-go/src/recorders"
+)
+
+func TestErrorFeed(t *testing.T) {
+	feed := newErrorFeed(nil)
+	for i := 0; i < errorFeedSize+5; i++ {
+		severity := SeverityWarning
+		if i%2 == 0 {
+			severity = SeverityError
+		}
+		feed.push(severity, "test", "id", fmt.Sprint(i))
+	}
+	problems := feed.list("")
+	assert.Len(t, problems, errorFeedSize)
+	// the newest first, the oldest ones are dropped
+	assert.Equal(t, fmt.Sprint(errorFeedSize+4), problems[0].Message)
+	assert.Equal(t, "5", problems[len(problems)-1].Message)
+	assert.Equal(t, uint64(errorFeedSize+5), problems[0].Id)
+
+	w := httptest.NewRecorder()
+	feed.getErrors(w, httptest.NewRequest(http.MethodGet, "/api/system/errors?severity=warning", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	var warnings []problem
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &warnings))
+	assert.Len(t, warnings, errorFeedSize/2)
+	for _, p := range warnings {
+		assert.Equal(t, SeverityWarning, p.Severity)
+	}
+
+	w = httptest.NewRecorder()
+	feed.getErrors(w, httptest.NewRequest(http.MethodGet, "/api/system/errors?severity=fatal", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestErrorFeedParserExited(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.WithValue(context.Background(), instance.Key, &instance.Instance{})
+	ed := events.NewDispatcher(ctx)
+	feed := newErrorFeed(nil)
+	feed.registryListener(ctx)
+
+	l := mock.NewMockLive(ctrl)
+	l.EXPECT().GetLiveId().Return(live.ID("1")).AnyTimes()
+	ed.DispatchEvent(events.NewEvent(recorders.ParserExited, recorders.ParserExitedParam{
+		Live: l,
+		Err:  errors.New("exit status 1: Connection reset by peer"),
+	}))
+	assert.Eventually(t, func() bool { return len(feed.list("")) == 1 }, time.Second, 10*time.Millisecond)
+	p := feed.list("")[0]
+	assert.Equal(t, string(recorders.ParserExited), p.Source)
+	assert.Equal(t, live.ID("1"), p.LiveId)
+	assert.Contains(t, p.Message, "Connection reset by peer")
+}
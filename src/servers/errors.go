@@ -0,0 +1,83 @@
+package servers
+
+import (
+	"net/http"
+
+	"github.com/hr3lxphr6j/bililive-go/src/configs"
+	"github.com/hr3lxphr6j/bililive-go/src/listeners"
+	"github.com/hr3lxphr6j/bililive-go/src/live"
+	"github.com/hr3lxphr6j/bililive-go/src/recorders"
+)
+
+// Error codes returned in commonResp.ErrCode, they are stable and can be used
+// by clients to tell the errors apart, while the ErrMsg is only for human.
+const (
+	ErrCodeInternal          = "INTERNAL_ERROR"
+	ErrCodeInvalidBody       = "INVALID_BODY"
+	ErrCodeInvalidAction     = "INVALID_ACTION"
+	ErrCodeLiveNotFound      = "LIVE_NOT_FOUND"
+	ErrCodeRoomNotFound      = "ROOM_NOT_FOUND"
+	ErrCodeUrlNotSupported   = "URL_NOT_SUPPORTED"
+	ErrCodeUrlInvalid        = "URL_INVALID"
+	ErrCodeListenerExist     = "LISTENER_EXIST"
+	ErrCodeListenerNotExist  = "LISTENER_NOT_EXIST"
+	ErrCodeRecorderExist     = "RECORDER_EXIST"
+	ErrCodeRecorderNotExist  = "RECORDER_NOT_EXIST"
+	ErrCodeStreamUrlNotFound = "STREAM_URL_NOT_FOUND"
+	ErrCodeConfigInvalid     = "CONFIG_INVALID"
+	ErrCodeConfigSaveFailed  = "CONFIG_SAVE_FAILED"
+	ErrCodePathInvalid       = "PATH_INVALID"
+	ErrCodeFileNotFound      = "FILE_NOT_FOUND"
+	ErrCodeLiveNotStreaming  = "LIVE_NOT_STREAMING"
+	ErrCodeStreamNotHLS      = "STREAM_NOT_HLS"
+	ErrCodeUpstreamFailed    = "UPSTREAM_FAILED"
+	ErrCodeTooManyRequests   = "TOO_MANY_REQUESTS"
+	ErrCodeLoginNotSupported = "LOGIN_NOT_SUPPORTED"
+	ErrCodeUnauthorized      = "UNAUTHORIZED"
+	ErrCodePlatformNotFound  = "PLATFORM_NOT_FOUND"
+	ErrCodeSelfTestUrlNeeded = "SELF_TEST_URL_NEEDED"
+	ErrCodeInvalidParam      = "INVALID_PARAM"
+	ErrCodeProfileNotFound   = "PROFILE_NOT_FOUND"
+	ErrCodePlatformDegraded  = "PLATFORM_DEGRADED"
+	ErrCodeFormatUnsupported = "FORMAT_UNSUPPORTED"
+	ErrCodeFormatMismatch    = "FORMAT_MISMATCH"
+	ErrCodeRateLimited       = "RATE_LIMITED"
+)
+
+var errCodes = map[error]string{
+	live.ErrNotSupportUrl:          ErrCodeUrlNotSupported,
+	live.ErrRoomUrlIncorrect:       ErrCodeUrlInvalid,
+	live.ErrRoomNotExist:           ErrCodeRoomNotFound,
+	live.ErrLoginNotSupport:        ErrCodeLoginNotSupported,
+	live.ErrPlatformNotExist:       ErrCodePlatformNotFound,
+	live.ErrNoSelfTestUrl:          ErrCodeSelfTestUrlNeeded,
+	live.ErrPlatformDegraded:       ErrCodePlatformDegraded,
+	live.ErrPlatformLimited:        ErrCodeRateLimited,
+	configs.ErrProfileNotExist:     ErrCodeProfileNotFound,
+	configs.ErrFormatNotSupported:  ErrCodeFormatUnsupported,
+	listeners.ErrListenerExist:     ErrCodeListenerExist,
+	listeners.ErrListenerNotExist:  ErrCodeListenerNotExist,
+	recorders.ErrRecorderExist:     ErrCodeRecorderExist,
+	recorders.ErrRecorderNotExist:  ErrCodeRecorderNotExist,
+	recorders.ErrStreamUrlNotFound: ErrCodeStreamUrlNotFound,
+}
+
+// errCodeOf returns the error code of the known errors, or the fallback one.
+func errCodeOf(err error, fallback string) string {
+	if code, ok := errCodes[err]; ok {
+		return code
+	}
+	return fallback
+}
+
+func writeError(w http.ResponseWriter, statusCode int, errCode, errMsg string) {
+	writeJsonWithStatusCode(w, statusCode, commonResp{
+		ErrNo:   statusCode,
+		ErrCode: errCode,
+		ErrMsg:  errMsg,
+	})
+}
+
+func writeLiveNotFound(w http.ResponseWriter, id string) {
+	writeError(w, http.StatusNotFound, ErrCodeLiveNotFound, "live id: "+id+" can not find")
+}
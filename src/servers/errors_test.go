@@ -0,0 +1,31 @@
+package servers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hr3lxphr6j/bililive-go/src/live"
+	"github.com/hr3lxphr6j/bililive-go/src/recorders"
+)
+
+func TestErrCodeOf(t *testing.T) {
+	assert.Equal(t, ErrCodeUrlNotSupported, errCodeOf(live.ErrNotSupportUrl, ErrCodeInternal))
+	assert.Equal(t, ErrCodeRecorderExist, errCodeOf(recorders.ErrRecorderExist, ErrCodeInternal))
+	assert.Equal(t, ErrCodeInternal, errCodeOf(errors.New("unknown"), ErrCodeInternal))
+}
+
+func TestWriteError(t *testing.T) {
+	w := httptest.NewRecorder()
+	writeLiveNotFound(w, "foo")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	resp := commonResp{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, http.StatusNotFound, resp.ErrNo)
+	assert.Equal(t, ErrCodeLiveNotFound, resp.ErrCode)
+	assert.Equal(t, "live id: foo can not find", resp.ErrMsg)
+}
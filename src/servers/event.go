@@ -0,0 +1,24 @@
+package servers
+
+import (
+	"context"
+
+	"github.com/hr3lxphr6j/bililive-go/src/instance"
+	"github.com/hr3lxphr6j/bililive-go/src/pkg/events"
+)
+
+const ConfigChanged events.EventType = "ConfigChanged"
+
+// ConfigChangedEvent is the object of the ConfigChanged event.
+type ConfigChangedEvent struct {
+	ChangedFields []string
+}
+
+func dispatchConfigChanged(ctx context.Context, changedFields []string) {
+	if len(changedFields) == 0 {
+		return
+	}
+	if ed, ok := instance.GetInstance(ctx).EventDispatcher.(events.Dispatcher); ok {
+		ed.DispatchEvent(events.NewEvent(ConfigChanged, ConfigChangedEvent{ChangedFields: changedFields}))
+	}
+}
@@ -0,0 +1,126 @@
+package servers
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+// the deepest recursive listing of the file browser
+const maxFileListDepth = 5
+
+type jsonFile struct {
+	IsFolder     bool   `json:"is_folder"`
+	Name         string `json:"name"` // relative to the listed directory
+	LastModified int64  `json:"last_modified"`
+	Size         int64  `json:"size"`
+}
+
+// fileListOptions are the query parameters of the file browser.
+type fileListOptions struct {
+	depth  int    // 1 lists the immediate children only
+	sort   string // name, mtime or size
+	desc   bool
+	offset int
+	limit  int // 0 means no limit
+}
+
+func parseFileListOptions(query url.Values) (fileListOptions, error) {
+	opts := fileListOptions{depth: 1, sort: "name"}
+	intParam := func(name string, min, max int, value *int) error {
+		s := query.Get(name)
+		if s == "" {
+			return nil
+		}
+		v, err := strconv.Atoi(s)
+		if err != nil || v < min || v > max {
+			return fmt.Errorf("invalid %s: %s, should be in [%d, %d]", name, s, min, max)
+		}
+		*value = v
+		return nil
+	}
+	if err := intParam("depth", 1, maxFileListDepth, &opts.depth); err != nil {
+		return opts, err
+	}
+	if err := intParam("offset", 0, int(^uint(0)>>1), &opts.offset); err != nil {
+		return opts, err
+	}
+	if err := intParam("limit", 0, int(^uint(0)>>1), &opts.limit); err != nil {
+		return opts, err
+	}
+	switch s := query.Get("sort"); s {
+	case "":
+	case "name", "mtime", "size":
+		opts.sort = s
+	default:
+		return opts, fmt.Errorf("invalid sort: %s, should be name, mtime or size", s)
+	}
+	switch o := query.Get("order"); o {
+	case "", "asc":
+	case "desc":
+		opts.desc = true
+	default:
+		return opts, fmt.Errorf("invalid order: %s, should be asc or desc", o)
+	}
+	return opts, nil
+}
+
+// listFiles lists the files under dir down to depth levels, the unreadable sub directories are skipped.
+func listFiles(dir string, depth int) ([]jsonFile, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]jsonFile, 0, len(files))
+	for _, file := range files {
+		f := jsonFile{
+			IsFolder:     file.IsDir(),
+			Name:         file.Name(),
+			LastModified: file.ModTime().Unix(),
+		}
+		if !file.IsDir() {
+			f.Size = file.Size()
+		}
+		ret = append(ret, f)
+		if !file.IsDir() || depth <= 1 {
+			continue
+		}
+		children, err := listFiles(filepath.Join(dir, file.Name()), depth-1)
+		if err != nil {
+			continue
+		}
+		for _, child := range children {
+			child.Name = file.Name() + "/" + child.Name
+			ret = append(ret, child)
+		}
+	}
+	return ret, nil
+}
+
+// sortAndPageFiles sorts the files as the options, and returns the requested page of them.
+func sortAndPageFiles(files []jsonFile, opts fileListOptions) []jsonFile {
+	less := func(i, j int) bool { return files[i].Name < files[j].Name }
+	switch opts.sort {
+	case "mtime":
+		less = func(i, j int) bool { return files[i].LastModified < files[j].LastModified }
+	case "size":
+		less = func(i, j int) bool { return files[i].Size < files[j].Size }
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		if opts.desc {
+			return less(j, i)
+		}
+		return less(i, j)
+	})
+	if opts.offset >= len(files) {
+		return []jsonFile{}
+	}
+	files = files[opts.offset:]
+	if opts.limit > 0 && opts.limit < len(files) {
+		files = files[:opts.limit]
+	}
+	return files
+}
@@ -0,0 +1,81 @@
+package servers
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFileListOptions(t *testing.T) {
+	opts, err := parseFileListOptions(url.Values{})
+	assert.NoError(t, err)
+	assert.Equal(t, fileListOptions{depth: 1, sort: "name"}, opts)
+
+	opts, err = parseFileListOptions(url.Values{
+		"depth": {"2"}, "sort": {"mtime"}, "order": {"desc"}, "offset": {"10"}, "limit": {"20"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, fileListOptions{depth: 2, sort: "mtime", desc: true, offset: 10, limit: 20}, opts)
+
+	for _, query := range []url.Values{
+		{"depth": {"0"}},
+		{"depth": {"100"}},
+		{"offset": {"-1"}},
+		{"limit": {"a"}},
+		{"sort": {"type"}},
+		{"order": {"random"}},
+	} {
+		_, err := parseFileListOptions(query)
+		assert.Error(t, err, query.Encode())
+	}
+}
+
+func TestListFiles(t *testing.T) {
+	root, err := ioutil.TempDir("", "files")
+	assert.NoError(t, err)
+	defer os.RemoveAll(root)
+	base := time.Now().Add(-time.Hour)
+	write := func(name string, size int, modTime time.Time) {
+		file := filepath.Join(root, name)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(file), os.ModePerm))
+		assert.NoError(t, ioutil.WriteFile(file, make([]byte, size), 0644))
+		assert.NoError(t, os.Chtimes(file, modTime, modTime))
+	}
+	write("a.flv", 3, base)
+	write("b.flv", 1, base.Add(2*time.Minute))
+	write("dir/c.flv", 2, base.Add(time.Minute))
+	write("dir/sub/d.flv", 4, base)
+
+	names := func(files []jsonFile) []string {
+		ret := make([]string, 0, len(files))
+		for _, f := range files {
+			ret = append(ret, f.Name)
+		}
+		return ret
+	}
+	files, err := listFiles(root, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a.flv", "b.flv", "dir"}, names(files))
+	files, err = listFiles(root, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a.flv", "b.flv", "dir", "dir/c.flv", "dir/sub"}, names(files))
+
+	files, _ = listFiles(root, maxFileListDepth)
+	assert.Len(t, files, 6)
+	onlyFiles := make([]jsonFile, 0)
+	for _, f := range files {
+		if !f.IsFolder {
+			onlyFiles = append(onlyFiles, f)
+		}
+	}
+	assert.Equal(t, []string{"dir/sub/d.flv", "a.flv", "dir/c.flv", "b.flv"},
+		names(sortAndPageFiles(onlyFiles, fileListOptions{sort: "size", desc: true})))
+	assert.Equal(t, []string{"dir/c.flv", "b.flv"},
+		names(sortAndPageFiles(onlyFiles, fileListOptions{sort: "mtime", offset: 2, limit: 2})))
+	assert.Empty(t, sortAndPageFiles(onlyFiles, fileListOptions{sort: "name", offset: 10}))
+}
@@ -15,34 +15,58 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/tidwall/gjson"
-	"gopkg.in/yaml.v2"
 
 	"github.com/hr3lxphr6j/bililive-go/src/configs"
 	"github.com/hr3lxphr6j/bililive-go/src/consts"
 	"github.com/hr3lxphr6j/bililive-go/src/instance"
 	"github.com/hr3lxphr6j/bililive-go/src/listeners"
 	"github.com/hr3lxphr6j/bililive-go/src/live"
+	applog "github.com/hr3lxphr6j/bililive-go/src/log"
+	"github.com/hr3lxphr6j/bililive-go/src/pkg/utils"
 	"github.com/hr3lxphr6j/bililive-go/src/recorders"
 )
 
 // FIXME: remove this
 func parseInfo(ctx context.Context, l live.Live) *live.Info {
 	inst := instance.GetInstance(ctx)
-	obj, _ := inst.Cache.Get(l)
-	info := obj.(*live.Info)
+	info, err := live.GetCachedInfo(inst.Cache, l)
+	if err != nil {
+		// the cache has been cleared and the info can't be fetched for now
+		info = &live.Info{Live: l, RoomName: err.Error()}
+	}
 	info.Listening = inst.ListenerManager.(listeners.Manager).HasListener(ctx, l.GetLiveId())
 	info.Recording = inst.RecorderManager.(recorders.Manager).HasRecorder(ctx, l.GetLiveId())
+	info.Reconnecting = inst.RecorderManager.(recorders.Manager).IsReconnecting(ctx, l.GetLiveId())
+	info.RecordingFile, info.RecordingFileSizeBytes, info.RecordingRemaining, info.UnsupportedReason = "", 0, nil, ""
+	info.Tags = inst.Config.GetTags(l.GetRawUrl())
+	if remaining, ok := inst.RecorderManager.(recorders.Manager).RemainingRecordingDuration(ctx, l.GetLiveId()); ok {
+		info.RecordingRemaining = &remaining
+	}
+	if r, err := inst.RecorderManager.(recorders.Manager).GetRecorder(ctx, l.GetLiveId()); err == nil {
+		info.RecordingFile = r.RecordingFile()
+		info.UnsupportedReason = r.UnsupportedReason()
+		if fi, err := os.Stat(info.RecordingFile); err == nil {
+			info.RecordingFileSizeBytes = fi.Size()
+		}
+	}
 	return info
 }
 
+// getAllLives lists the lives, only the ones tagged with the tag query parameter when it's set.
 func getAllLives(writer http.ResponseWriter, r *http.Request) {
 	inst := instance.GetInstance(r.Context())
 	lives := liveSlice(make([]*live.Info, 0, 4))
+	tag := strings.TrimSpace(r.URL.Query().Get("tag"))
 	for _, v := range inst.Lives {
+		if tag != "" {
+			if room, err := inst.Config.GetLiveRoomByUrl(v.GetRawUrl()); err != nil || !room.HasTag(tag) {
+				continue
+			}
+		}
 		lives = append(lives, parseInfo(r.Context(), v))
 	}
 	sort.Sort(lives)
-	writeJSON(writer, lives)
+	writeJSONWithETag(writer, r, lives)
 }
 
 func getLive(writer http.ResponseWriter, r *http.Request) {
@@ -50,61 +74,165 @@ func getLive(writer http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	live, ok := inst.Lives[live.ID(vars["id"])]
 	if !ok {
-		writeJsonWithStatusCode(writer, http.StatusNotFound, commonResp{
-			ErrNo:  http.StatusNotFound,
-			ErrMsg: fmt.Sprintf("live id: %s can not find", vars["id"]),
-		})
+		writeLiveNotFound(writer, vars["id"])
 		return
 	}
-	writeJSON(writer, parseInfo(r.Context(), live))
+	// copy it, the md5 should not leak to the shared info in the cache
+	info := *parseInfo(r.Context(), live)
+	if r.URL.Query().Get("include_md5") == "true" && info.RecordingFile != "" {
+		if rec, err := inst.RecorderManager.(recorders.Manager).GetRecorder(r.Context(), live.GetLiveId()); err == nil {
+			md5, err := rec.RecordingFileMD5()
+			if err != nil {
+				writeError(writer, http.StatusInternalServerError, ErrCodeInternal, err.Error())
+				return
+			}
+			info.RecordingFileMD5 = md5
+		}
+	}
+	writeJSONWithETag(writer, r, &info)
 }
 
 func parseLiveAction(writer http.ResponseWriter, r *http.Request) {
 	inst := instance.GetInstance(r.Context())
 	vars := mux.Vars(r)
-	resp := commonResp{}
 	live, ok := inst.Lives[live.ID(vars["id"])]
 	if !ok {
-		resp.ErrNo = http.StatusNotFound
-		resp.ErrMsg = fmt.Sprintf("live id: %s can not find", vars["id"])
-		writeJsonWithStatusCode(writer, http.StatusNotFound, resp)
+		writeLiveNotFound(writer, vars["id"])
 		return
 	}
 	room, err := inst.Config.GetLiveRoomByUrl(live.GetRawUrl())
 	if err != nil {
-		resp.ErrNo = http.StatusNotFound
-		resp.ErrMsg = fmt.Sprintf("room : %s can not find", live.GetRawUrl())
-		writeJsonWithStatusCode(writer, http.StatusNotFound, resp)
+		writeError(writer, http.StatusNotFound, ErrCodeRoomNotFound, fmt.Sprintf("room : %s can not find", live.GetRawUrl()))
 		return
 	}
 	switch vars["action"] {
 	case "start":
 		if err := startListening(r.Context(), live); err != nil {
-			resp.ErrNo = http.StatusBadRequest
-			resp.ErrMsg = err.Error()
-			writeJsonWithStatusCode(writer, http.StatusBadRequest, resp)
+			writeError(writer, http.StatusBadRequest, errCodeOf(err, ErrCodeInternal), err.Error())
 			return
 		} else {
 			room.IsListening = true
 		}
 	case "stop":
 		if err := stopListening(r.Context(), live.GetLiveId()); err != nil {
-			resp.ErrNo = http.StatusBadRequest
-			resp.ErrMsg = err.Error()
-			writeJsonWithStatusCode(writer, http.StatusBadRequest, resp)
+			writeError(writer, http.StatusBadRequest, errCodeOf(err, ErrCodeInternal), err.Error())
 			return
 		} else {
 			room.IsListening = false
 		}
 	default:
-		resp.ErrNo = http.StatusBadRequest
-		resp.ErrMsg = fmt.Sprintf("invalid Action: %s", vars["action"])
-		writeJsonWithStatusCode(writer, http.StatusBadRequest, resp)
+		writeError(writer, http.StatusBadRequest, ErrCodeInvalidAction, fmt.Sprintf("invalid Action: %s", vars["action"]))
 		return
 	}
 	writeJSON(writer, parseInfo(r.Context(), live))
 }
 
+// parseRecordAction starts or stops recording a live manually, regardless of the listener.
+func parseRecordAction(writer http.ResponseWriter, r *http.Request) {
+	inst := instance.GetInstance(r.Context())
+	vars := mux.Vars(r)
+	live, ok := inst.Lives[live.ID(vars["id"])]
+	if !ok {
+		writeLiveNotFound(writer, vars["id"])
+		return
+	}
+	rm := inst.RecorderManager.(recorders.Manager)
+	var err error
+	switch vars["action"] {
+	case "start":
+		err = rm.StartManualRecorder(r.Context(), live)
+	case "stop":
+		err = rm.RemoveRecorder(r.Context(), live.GetLiveId())
+	default:
+		writeError(writer, http.StatusBadRequest, ErrCodeInvalidAction, fmt.Sprintf("invalid Action: %s", vars["action"]))
+		return
+	}
+	if err != nil {
+		writeError(writer, http.StatusBadRequest, errCodeOf(err, ErrCodeInternal), err.Error())
+		return
+	}
+	writeJSON(writer, parseInfo(r.Context(), live))
+}
+
+/*
+Put data example
+
+	{
+		"nickname": "My VTuber"
+	}
+*/
+func putNickName(writer http.ResponseWriter, r *http.Request) {
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeError(writer, http.StatusBadRequest, ErrCodeInvalidBody, err.Error())
+		return
+	}
+	setNickName(writer, r, strings.TrimSpace(gjson.GetBytes(b, "nickname").String()))
+}
+
+func deleteNickName(writer http.ResponseWriter, r *http.Request) {
+	setNickName(writer, r, "")
+}
+
+func setNickName(writer http.ResponseWriter, r *http.Request, nickName string) {
+	inst := instance.GetInstance(r.Context())
+	vars := mux.Vars(r)
+	l, ok := inst.Lives[live.ID(vars["id"])]
+	if !ok {
+		writeLiveNotFound(writer, vars["id"])
+		return
+	}
+	room, err := inst.Config.GetLiveRoomByUrl(l.GetRawUrl())
+	if err != nil {
+		writeError(writer, http.StatusNotFound, ErrCodeRoomNotFound, fmt.Sprintf("room : %s can not find", l.GetRawUrl()))
+		return
+	}
+	room.NickName = nickName
+	if wrapped, ok := l.(*live.WrappedLive); ok {
+		wrapped.SetNickName(nickName)
+	}
+	writeJSON(writer, parseInfo(r.Context(), l))
+}
+
+/*
+Put data example, an empty list clears the tags
+
+	{
+		"tags": ["gaming", "vtuber"]
+	}
+*/
+func putTags(writer http.ResponseWriter, r *http.Request) {
+	inst := instance.GetInstance(r.Context())
+	vars := mux.Vars(r)
+	l, ok := inst.Lives[live.ID(vars["id"])]
+	if !ok {
+		writeLiveNotFound(writer, vars["id"])
+		return
+	}
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeError(writer, http.StatusBadRequest, ErrCodeInvalidBody, err.Error())
+		return
+	}
+	tags := gjson.GetBytes(b, "tags")
+	if !tags.IsArray() {
+		writeError(writer, http.StatusBadRequest, ErrCodeInvalidBody, "tags must be an array")
+		return
+	}
+	room, err := inst.Config.GetLiveRoomByUrl(l.GetRawUrl())
+	if err != nil {
+		writeError(writer, http.StatusNotFound, ErrCodeRoomNotFound, fmt.Sprintf("room : %s can not find", l.GetRawUrl()))
+		return
+	}
+	newTags := make([]string, 0, 4)
+	for _, tag := range tags.Array() {
+		newTags = append(newTags, tag.String())
+	}
+	room.Tags = configs.NormalizeTags(newTags)
+	dispatchConfigChanged(r.Context(), []string{"live_rooms"})
+	writeJSON(writer, parseInfo(r.Context(), l))
+}
+
 func startListening(ctx context.Context, live live.Live) error {
 	inst := instance.GetInstance(ctx)
 	return inst.ListenerManager.(listeners.Manager).AddListener(ctx, live)
@@ -145,7 +273,12 @@ func addLives(writer http.ResponseWriter, r *http.Request) {
 	gjson.ParseBytes(b).ForEach(func(key, value gjson.Result) bool {
 		isListen := value.Get("listen").Bool()
 		urlStr := strings.Trim(value.Get("url").String(), " ")
-		if retInfo, err := addLiveImpl(r.Context(), urlStr, isListen); err != nil {
+		tags := make([]string, 0)
+		for _, tag := range value.Get("tags").Array() {
+			tags = append(tags, tag.String())
+		}
+		room := configs.LiveRoom{Url: urlStr, IsListening: isListen, Tags: configs.NormalizeTags(tags)}
+		if retInfo, err := addLiveImpl(r.Context(), room); err != nil {
 			msg := urlStr + ": " + err.Error()
 			inst.Logger.Error(msg)
 			errorMessages = append(errorMessages, msg)
@@ -155,12 +288,17 @@ func addLives(writer http.ResponseWriter, r *http.Request) {
 		}
 		return true
 	})
+	if len(info) > 0 {
+		dispatchConfigChanged(r.Context(), []string{"live_rooms"})
+	}
 	sort.Sort(info)
 	// TODO return error messages too
 	writeJSON(writer, info)
 }
 
-func addLiveImpl(ctx context.Context, urlStr string, isListen bool) (info *live.Info, err error) {
+// addLiveImpl adds the room if its live is not added yet, otherwise a nil info is returned.
+func addLiveImpl(ctx context.Context, room configs.LiveRoom) (info *live.Info, err error) {
+	urlStr := room.Url
 	if !strings.HasPrefix(urlStr, "http://") && !strings.HasPrefix(urlStr, "https://") {
 		urlStr = "https://" + urlStr
 	}
@@ -173,45 +311,143 @@ func addLiveImpl(ctx context.Context, urlStr string, isListen bool) (info *live.
 	if v, ok := inst.Config.Cookies[u.Host]; ok {
 		opts = append(opts, live.WithKVStringCookies(u, v))
 	}
-	newLive, err := live.New(u, inst.Cache, opts...)
+	if v, ok := inst.Config.Headers[u.Host]; ok {
+		opts = append(opts, live.WithHeaders(v))
+	}
+	opts = append(opts, live.WithQuality(room.Quality))
+	opts = append(opts, live.WithAudioOnly(room.AudioOnly))
+	opts = append(opts, live.WithNickName(room.NickName))
+	opts = append(opts, live.WithInitRetry(inst.Config.LiveInit.RetryCount, inst.Config.LiveInit.RetryInterval))
+	opts = append(opts, live.WithMaxInflight(inst.Config.MaxInflightInfoRequests[u.Host]))
+	newLive, err := live.New(ctx, u, inst.Cache, opts...)
 	if err != nil {
 		return nil, err
 	}
 	if _, ok := inst.Lives[newLive.GetLiveId()]; !ok {
 		inst.Lives[newLive.GetLiveId()] = newLive
-		if isListen {
+		if room.IsListening {
 			inst.ListenerManager.(listeners.Manager).AddListener(ctx, newLive)
 		}
 		info = parseInfo(ctx, newLive)
 
-		liveRoom := configs.LiveRoom{
-			Url:         u.String(),
-			IsListening: isListen,
-			LiveId:      newLive.GetLiveId(),
-		}
-		inst.Config.LiveRooms = append(inst.Config.LiveRooms, liveRoom)
+		room.Url = u.String()
+		room.LiveId = newLive.GetLiveId()
+		inst.Config.LiveRooms = append(inst.Config.LiveRooms, room)
 	}
 	return info, nil
 }
 
+/*
+	Post data example, a config file in yaml or json, only live_rooms is used
+
+live_rooms:
+  - url: https://live.bilibili.com/493
+    is_listening: true
+*/
+func importLiveRooms(writer http.ResponseWriter, r *http.Request) {
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeError(writer, http.StatusBadRequest, ErrCodeInvalidBody, err.Error())
+		return
+	}
+	imported, err := configs.NewConfigWithBytes(b)
+	if err != nil {
+		writeError(writer, http.StatusBadRequest, ErrCodeConfigInvalid, err.Error())
+		return
+	}
+	addImportedLiveRooms(writer, r, imported.LiveRooms, nil)
+}
+
+/*
+Post data example, the room list in the config of another recorder, data is the content
+of the config file as a string, or the json object of it
+
+	{
+	  "format": "blrec",
+	  "data": "[[tasks]]\nroom_id = 493\nenable_recorder = true\n"
+	}
+*/
+func importExternalLiveRooms(writer http.ResponseWriter, r *http.Request) {
+	req := struct {
+		Format string          `json:"format"`
+		Data   json.RawMessage `json:"data"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(writer, http.StatusBadRequest, ErrCodeInvalidBody, err.Error())
+		return
+	}
+	data := []byte(req.Data)
+	var content string
+	if err := json.Unmarshal(req.Data, &content); err == nil {
+		data = []byte(content)
+	}
+	rooms, unmapped, err := configs.ParseExternalLiveRooms(req.Format, data)
+	if err != nil {
+		writeError(writer, http.StatusBadRequest, errCodeOf(err, ErrCodeInvalidBody), err.Error())
+		return
+	}
+	addImportedLiveRooms(writer, r, rooms, unmapped)
+}
+
+// addImportedLiveRooms adds the rooms not in the config yet and saves it, unmapped is reported as is
+// when it's not nil, which are the entries of another recorder that can not be converted.
+func addImportedLiveRooms(writer http.ResponseWriter, r *http.Request, rooms []configs.LiveRoom, unmapped []string) {
+	ctx := r.Context()
+	inst := instance.GetInstance(ctx)
+	newRooms, duplicates := inst.Config.FilterNewLiveRooms(rooms)
+	added := 0
+	errorMessages := make([]string, 0)
+	for _, room := range newRooms {
+		info, err := addLiveImpl(ctx, room)
+		if err != nil {
+			msg := room.Url + ": " + err.Error()
+			inst.Logger.Error(msg)
+			errorMessages = append(errorMessages, msg)
+			continue
+		}
+		if info == nil {
+			// the same live is added by another url
+			duplicates = append(duplicates, room.Url)
+			continue
+		}
+		added++
+	}
+	inst.Config.RefreshLiveRoomIndexCache()
+	if added > 0 {
+		dispatchConfigChanged(ctx, []string{"live_rooms"})
+	}
+	if added > 0 && inst.Config.File != "" {
+		if err := inst.Config.Marshal(); err != nil {
+			writeError(writer, http.StatusInternalServerError, ErrCodeConfigSaveFailed, err.Error())
+			return
+		}
+	}
+	resp := map[string]interface{}{
+		"added":      added,
+		"skipped":    len(duplicates) + len(errorMessages),
+		"duplicates": duplicates,
+		"errors":     errorMessages,
+	}
+	if unmapped != nil {
+		resp["skipped"] = len(duplicates) + len(errorMessages) + len(unmapped)
+		resp["unmapped"] = unmapped
+	}
+	writeJSON(writer, resp)
+}
+
 func removeLive(writer http.ResponseWriter, r *http.Request) {
 	inst := instance.GetInstance(r.Context())
 	vars := mux.Vars(r)
 	live, ok := inst.Lives[live.ID(vars["id"])]
 	if !ok {
-		writeJsonWithStatusCode(writer, http.StatusNotFound, commonResp{
-			ErrNo:  http.StatusNotFound,
-			ErrMsg: fmt.Sprintf("live id: %s can not find", vars["id"]),
-		})
+		writeLiveNotFound(writer, vars["id"])
 		return
 	}
 	if err := removeLiveImpl(r.Context(), live); err != nil {
-		writeJsonWithStatusCode(writer, http.StatusBadRequest, commonResp{
-			ErrNo:  http.StatusBadRequest,
-			ErrMsg: err.Error(),
-		})
+		writeError(writer, http.StatusBadRequest, errCodeOf(err, ErrCodeInternal), err.Error())
 		return
 	}
+	dispatchConfigChanged(r.Context(), []string{"live_rooms"})
 	writeJSON(writer, commonResp{
 		Data: "OK",
 	})
@@ -231,17 +467,18 @@ func removeLiveImpl(ctx context.Context, live live.Live) error {
 }
 
 func getConfig(writer http.ResponseWriter, r *http.Request) {
-	writeJSON(writer, instance.GetInstance(r.Context()).Config)
+	writeJSONWithETag(writer, r, instance.GetInstance(r.Context()).Config)
 }
 
 func putConfig(writer http.ResponseWriter, r *http.Request) {
 	config := instance.GetInstance(r.Context()).Config
 	config.RefreshLiveRoomIndexCache()
+	if err := config.Verify(); err != nil {
+		writeValidationError(writer, err)
+		return
+	}
 	if err := config.Marshal(); err != nil {
-		writeJsonWithStatusCode(writer, http.StatusBadRequest, commonResp{
-			ErrNo:  http.StatusBadRequest,
-			ErrMsg: err.Error(),
-		})
+		writeError(writer, http.StatusBadRequest, ErrCodeConfigSaveFailed, err.Error())
 		return
 	}
 	writeJsonWithStatusCode(writer, http.StatusOK, commonResp{
@@ -250,116 +487,356 @@ func putConfig(writer http.ResponseWriter, r *http.Request) {
 }
 
 func getRawConfig(writer http.ResponseWriter, r *http.Request) {
-	b, err := yaml.Marshal(instance.GetInstance(r.Context()).Config)
+	// the secrets stay on the server, the placeholders are filled again when it's put back
+	b, err := instance.GetInstance(r.Context()).Config.MarshalPublic()
 	if err != nil {
-		writeJsonWithStatusCode(writer, http.StatusInternalServerError, commonResp{
-			ErrNo:  http.StatusBadRequest,
-			ErrMsg: err.Error(),
-		})
+		writeError(writer, http.StatusInternalServerError, ErrCodeInternal, err.Error())
 		return
 	}
-	writeJSON(writer, map[string]string{
+	writeJSONWithETag(writer, r, map[string]string{
 		"config": string(b),
 	})
 }
 
+// putRawConfig replaces the config with the posted one, with ?dry_run=true only what would be changed is returned.
 func putRawConfig(writer http.ResponseWriter, r *http.Request) {
 	b, err := ioutil.ReadAll(r.Body)
 	if err != nil {
-		writeJsonWithStatusCode(writer, http.StatusBadRequest, commonResp{
-			ErrNo:  http.StatusBadRequest,
-			ErrMsg: err.Error(),
-		})
+		writeError(writer, http.StatusBadRequest, ErrCodeInvalidBody, err.Error())
 		return
 	}
 	ctx := r.Context()
 	inst := instance.GetInstance(ctx)
 	var jsonBody map[string]interface{}
 	json.Unmarshal(b, &jsonBody)
-	configPath, err := inst.Config.GetFilePath()
-	if err != nil {
-		writeJsonWithStatusCode(writer, http.StatusInternalServerError, commonResp{
-			ErrNo:  http.StatusInternalServerError,
-			ErrMsg: err.Error(),
-		})
+	if _, err := inst.Config.GetFilePath(); err != nil {
+		writeError(writer, http.StatusInternalServerError, ErrCodeConfigSaveFailed, err.Error())
 		return
 	}
 	newConfig, err := configs.NewConfigWithBytes([]byte(jsonBody["config"].(string)))
 	if err != nil {
-		writeJsonWithStatusCode(writer, http.StatusInternalServerError, commonResp{
-			ErrNo:  http.StatusInternalServerError,
-			ErrMsg: err.Error(),
-		})
+		writeError(writer, http.StatusInternalServerError, ErrCodeConfigInvalid, err.Error())
+		return
+	}
+	if err := newConfig.Verify(); err != nil {
+		writeValidationError(writer, err)
 		return
 	}
 	oldConfig := inst.Config
 	newConfig.File = oldConfig.File
-	if err := applyLiveRoomsByConfig(ctx, newConfig.LiveRooms); err != nil {
+	if err := newConfig.FillSecrets(); err != nil {
+		writeError(writer, http.StatusInternalServerError, ErrCodeConfigInvalid, err.Error())
+		return
+	}
+	changedFields := configs.ChangedFields(oldConfig, newConfig)
+	if r.URL.Query().Get("dry_run") == "true" {
+		// report what would be done, nothing is applied nor saved
 		writeJSON(writer, map[string]interface{}{
-			"error": err.Error(),
+			"changed_fields": changedFields,
+			"live_rooms":     planLiveRooms(oldConfig, newConfig.LiveRooms),
 		})
 		return
 	}
+	if err := applyLiveRoomsByConfig(ctx, newConfig.LiveRooms); err != nil {
+		writeError(writer, http.StatusBadRequest, errCodeOf(err, ErrCodeInternal), err.Error())
+		return
+	}
 	newConfig.LiveRooms = oldConfig.LiveRooms
-	ioutil.WriteFile(configPath, []byte(jsonBody["config"].(string)), os.ModePerm)
 	inst.Config = newConfig
 	newConfig.RefreshLiveRoomIndexCache()
+	if newConfig.Log != oldConfig.Log {
+		if err := applog.Reload(ctx, newConfig.Log); err != nil {
+			inst.Logger.WithError(err).Error("failed to reload the log settings, the old ones are kept")
+		}
+	}
+	dispatchConfigChanged(ctx, changedFields)
+	// saved the same way as putConfig, so that the secrets go into the secrets file
+	if err := newConfig.Marshal(); err != nil {
+		writeError(writer, http.StatusInternalServerError, ErrCodeConfigSaveFailed, err.Error())
+		return
+	}
 	writeJSON(writer, commonResp{
 		Data: "OK",
 	})
 }
 
-func applyLiveRoomsByConfig(ctx context.Context, newLiveRooms []configs.LiveRoom) error {
+/*
+Post data example, the running rooms are reconciled with the ones of the profile before switching
+
+	{
+		"name": "archival"
+	}
+*/
+func switchProfile(writer http.ResponseWriter, r *http.Request) {
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeError(writer, http.StatusBadRequest, ErrCodeInvalidBody, err.Error())
+		return
+	}
+	ctx := r.Context()
 	inst := instance.GetInstance(ctx)
-	currentConfig := inst.Config
+	config := inst.Config
+	name := strings.TrimSpace(gjson.GetBytes(b, "name").String())
+	if _, ok := config.Profiles[name]; !ok {
+		writeError(writer, http.StatusNotFound, ErrCodeProfileNotFound, fmt.Sprintf("profile: %s can not find", name))
+		return
+	}
+	plan := planLiveRooms(config, config.Profiles[name].LiveRooms)
+	// kept to roll back, the switch either completes or leaves the rooms and profiles as they were
+	oldRooms := append([]configs.LiveRoom(nil), config.LiveRooms...)
+	oldActiveProfile := config.ActiveProfile
+	oldProfiles := make(map[string]configs.Profile, len(config.Profiles))
+	for k, v := range config.Profiles {
+		oldProfiles[k] = v
+	}
+	if config.Profiles == nil {
+		oldProfiles = nil
+	}
+	rollback := func() {
+		if err := applyLiveRoomsByConfig(ctx, oldRooms); err != nil {
+			inst.Logger.WithError(err).Errorf("failed to roll back the rooms after switching to profile: %s", name)
+		}
+		// the ids of the re-added rooms are taken from the reconciled ones
+		liveIds := make(map[string]live.ID, len(config.LiveRooms))
+		for _, room := range config.LiveRooms {
+			liveIds[room.Url] = room.LiveId
+		}
+		for i := range oldRooms {
+			if id, ok := liveIds[oldRooms[i].Url]; ok {
+				oldRooms[i].LiveId = id
+			}
+		}
+		config.LiveRooms = oldRooms
+		config.ActiveProfile = oldActiveProfile
+		config.Profiles = oldProfiles
+		config.RefreshLiveRoomIndexCache()
+	}
+	if stored := config.StoreActiveProfile(); stored != oldActiveProfile {
+		inst.Logger.Infof("the rooms are saved into profile: %s before switching to profile: %s", stored, name)
+	}
+	if err := applyLiveRoomsByConfig(ctx, config.Profiles[name].LiveRooms); err != nil {
+		inst.Logger.WithError(err).Errorf("failed to switch to profile: %s (%+v), rolling back", name, plan)
+		rollback()
+		writeError(writer, http.StatusBadRequest, errCodeOf(err, ErrCodeInternal), err.Error())
+		return
+	}
+	if err := config.UseProfile(name); err != nil {
+		rollback()
+		writeError(writer, http.StatusBadRequest, errCodeOf(err, ErrCodeInternal), err.Error())
+		return
+	}
+	dispatchConfigChanged(ctx, []string{"live_rooms", "on_record_finished", "active_profile"})
+	if err := config.Marshal(); err != nil {
+		writeError(writer, http.StatusInternalServerError, ErrCodeConfigSaveFailed, err.Error())
+		return
+	}
+	writeJSON(writer, commonResp{
+		Data: "OK",
+	})
+}
+
+// liveRoomsPlan is what applying the live rooms of a new config does to the running rooms, by url.
+type liveRoomsPlan struct {
+	Added          []string `json:"added"`
+	Removed        []string `json:"removed"`
+	StartListening []string `json:"start_listening"`
+	StopListening  []string `json:"stop_listening"`
+}
+
+func planLiveRooms(currentConfig *configs.Config, newLiveRooms []configs.LiveRoom) liveRoomsPlan {
 	currentConfig.RefreshLiveRoomIndexCache()
-	newUrlMap := make(map[string]*configs.LiveRoom)
+	plan := liveRoomsPlan{
+		Added:          make([]string, 0),
+		Removed:        make([]string, 0),
+		StartListening: make([]string, 0),
+		StopListening:  make([]string, 0),
+	}
+	newUrls := make(map[string]struct{}, len(newLiveRooms))
 	for _, newRoom := range newLiveRooms {
-		newUrlMap[newRoom.Url] = &newRoom
-		if room, err := currentConfig.GetLiveRoomByUrl(newRoom.Url); err != nil {
-			// add live
-			if _, err := addLiveImpl(ctx, newRoom.Url, newRoom.IsListening); err != nil {
-				return err
-			}
+		if _, ok := newUrls[newRoom.Url]; ok {
+			continue
+		}
+		newUrls[newRoom.Url] = struct{}{}
+		room, err := currentConfig.GetLiveRoomByUrl(newRoom.Url)
+		switch {
+		case err != nil:
+			plan.Added = append(plan.Added, newRoom.Url)
+		case room.IsListening == newRoom.IsListening:
+		case newRoom.IsListening:
+			plan.StartListening = append(plan.StartListening, newRoom.Url)
+		default:
+			plan.StopListening = append(plan.StopListening, newRoom.Url)
+		}
+	}
+	for _, room := range currentConfig.LiveRooms {
+		if _, ok := newUrls[room.Url]; !ok {
+			plan.Removed = append(plan.Removed, room.Url)
+		}
+	}
+	return plan
+}
+
+func applyLiveRoomsByConfig(ctx context.Context, newLiveRooms []configs.LiveRoom) error {
+	inst := instance.GetInstance(ctx)
+	currentConfig := inst.Config
+	plan := planLiveRooms(currentConfig, newLiveRooms)
+	newRooms := make(map[string]configs.LiveRoom, len(newLiveRooms))
+	for _, room := range newLiveRooms {
+		if _, ok := newRooms[room.Url]; !ok {
+			newRooms[room.Url] = room
+		}
+	}
+	for _, url := range plan.Added {
+		if _, err := addLiveImpl(ctx, newRooms[url]); err != nil {
+			return err
+		}
+	}
+	getLive := func(url string) (*configs.LiveRoom, live.Live, error) {
+		room, err := currentConfig.GetLiveRoomByUrl(url)
+		if err != nil {
+			return nil, nil, err
+		}
+		l, ok := inst.Lives[live.ID(room.LiveId)]
+		if !ok {
+			return nil, nil, errors.New(fmt.Sprintf("live id: %s can not find", room.LiveId))
+		}
+		return room, l, nil
+	}
+	for _, url := range append(plan.StartListening, plan.StopListening...) {
+		room, l, err := getLive(url)
+		if err != nil {
+			return err
+		}
+		listening := newRooms[url].IsListening
+		if listening {
+			err = startListening(ctx, l)
 		} else {
-			live, ok := inst.Lives[live.ID(room.LiveId)]
-			if !ok {
-				return errors.New(fmt.Sprintf("live id: %s can not find", room.LiveId))
-			}
-			if room.IsListening != newRoom.IsListening {
-				if newRoom.IsListening {
-					// start listening
-					if err := startListening(ctx, live); err != nil {
-						return err
-					}
-				} else {
-					// stop listening
-					if err := stopListening(ctx, live.GetLiveId()); err != nil {
-						return err
-					}
-				}
-				room.IsListening = newRoom.IsListening
-			}
+			err = stopListening(ctx, l.GetLiveId())
 		}
+		if err != nil {
+			return err
+		}
+		room.IsListening = listening
 	}
-	loopRooms := currentConfig.LiveRooms
-	for _, room := range loopRooms {
-		if _, ok := newUrlMap[room.Url]; !ok {
-			// remove live
-			live, ok := inst.Lives[live.ID(room.LiveId)]
-			if !ok {
-				return errors.New(fmt.Sprintf("live id: %s can not find", room.LiveId))
-			}
-			removeLiveImpl(ctx, live)
+	for _, url := range plan.Removed {
+		_, l, err := getLive(url)
+		if err != nil {
+			return err
 		}
+		removeLiveImpl(ctx, l)
 	}
 	return nil
 }
 
+/*
+Post data example, the file is relative to the output path, the live_id is taken from
+the path instead for the alias /api/lives/{id}/process-file
+
+	{
+		"live_id": "9c4f1a6d1bbd5ad7d6d9c0f0e7b0b0a1",
+		"file": "哔哩哔哩/host/[2021-01-01 00-00-00][host][room].flv"
+	}
+*/
+func processFile(writer http.ResponseWriter, r *http.Request) {
+	inst := instance.GetInstance(r.Context())
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeError(writer, http.StatusBadRequest, ErrCodeInvalidBody, err.Error())
+		return
+	}
+	id, ok := mux.Vars(r)["id"]
+	if !ok {
+		id = gjson.GetBytes(b, "live_id").String()
+	}
+	l, ok := inst.Lives[live.ID(id)]
+	if !ok {
+		writeLiveNotFound(writer, id)
+		return
+	}
+	base, err := filepath.Abs(inst.Config.OutPutPath)
+	if err != nil {
+		writeError(writer, http.StatusInternalServerError, ErrCodeInternal, err.Error())
+		return
+	}
+	file := filepath.Join(base, gjson.GetBytes(b, "file").String())
+	if !strings.HasPrefix(file, base+string(filepath.Separator)) {
+		writeError(writer, http.StatusBadRequest, ErrCodePathInvalid, "the file must be inside the output path")
+		return
+	}
+	if stat, err := os.Stat(file); err != nil || stat.IsDir() {
+		writeError(writer, http.StatusNotFound, ErrCodeFileNotFound, fmt.Sprintf("file: %s can not find", file))
+		return
+	}
+	// the request context is canceled once the response is sent
+	ctx := context.WithValue(context.Background(), instance.Key, inst)
+	go func() {
+		if err := recorders.PostProcessFile(ctx, l, file); err != nil {
+			inst.Logger.WithError(err).Errorf("failed to post process file: %s", file)
+		}
+	}()
+	writeJsonWithStatusCode(writer, http.StatusAccepted, commonResp{
+		Data: "OK",
+	})
+}
+
 func getInfo(writer http.ResponseWriter, r *http.Request) {
 	writeJSON(writer, consts.AppInfo)
 }
 
+func getDiskSpace(writer http.ResponseWriter, r *http.Request) {
+	m, ok := instance.GetInstance(r.Context()).DiskSpaceManager.(*utils.DiskSpaceManager)
+	if !ok {
+		writeError(writer, http.StatusInternalServerError, ErrCodeInternal, "disk space manager is not initialized")
+		return
+	}
+	space, err := m.Status(r.Context())
+	if err != nil {
+		writeError(writer, http.StatusInternalServerError, ErrCodeInternal, err.Error())
+		return
+	}
+	writeJSON(writer, space)
+}
+
+// getPlatformStatuses reports the platforms which are degraded, as their requests keep failing.
+func getPlatformStatuses(writer http.ResponseWriter, r *http.Request) {
+	writeJSON(writer, live.GetPlatformStatuses())
+}
+
+// selfTestPlatform checks whether the implementation of the platform still works, with the room
+// in the url query parameter, or the one in self_test_urls of the config, or the built-in one.
+func selfTestPlatform(writer http.ResponseWriter, r *http.Request) {
+	platform := mux.Vars(r)["key"]
+	testUrl := r.URL.Query().Get("url")
+	if testUrl == "" {
+		testUrl = instance.GetInstance(r.Context()).Config.SelfTestUrls[platform]
+	}
+	result, err := live.SelfTestWithUrl(platform, testUrl)
+	switch err {
+	case nil:
+		writeJSON(writer, result)
+	case live.ErrPlatformNotExist:
+		writeError(writer, http.StatusNotFound, ErrCodePlatformNotFound, err.Error())
+	default:
+		writeError(writer, http.StatusBadRequest, errCodeOf(err, ErrCodeInternal), err.Error())
+	}
+}
+
+// diagnosePaths does a test write in the directories which bililive-go writes into,
+// to surface the permission problems (e.g. of the docker volumes) before recording.
+func diagnosePaths(writer http.ResponseWriter, r *http.Request) {
+	cfg := instance.GetInstance(r.Context()).Config
+	paths := []utils.PathDiagnosis{utils.DiagnosePath("out_put_path", cfg.OutPutPath)}
+	if cfg.Log.OutPutFolder != "" {
+		paths = append(paths, utils.DiagnosePath("log.out_put_folder", cfg.Log.OutPutFolder))
+	}
+	if file, err := cfg.GetFilePath(); err == nil {
+		paths = append(paths, utils.DiagnosePath("config", filepath.Dir(file)))
+	}
+	writeJSON(writer, map[string]interface{}{"paths": paths})
+}
+
+// getFileInfo lists the files under the path in the out put path, with the optional query
+// parameters depth (recursive listing), sort (name, mtime or size), order (asc or desc), offset and limit.
 func getFileInfo(writer http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	path := vars["path"]
@@ -387,7 +864,12 @@ func getFileInfo(writer http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	files, err := ioutil.ReadDir(absPath)
+	opts, err := parseFileListOptions(r.URL.Query())
+	if err != nil {
+		writeError(writer, http.StatusBadRequest, ErrCodeInvalidParam, err.Error())
+		return
+	}
+	files, err := listFiles(absPath, opts.depth)
 	if err != nil {
 		writeJSON(writer, commonResp{
 			ErrMsg: "获取目录失败",
@@ -395,28 +877,15 @@ func getFileInfo(writer http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	type jsonFile struct {
-		IsFolder     bool   `json:"is_folder"`
-		Name         string `json:"name"`
-		LastModified int64  `json:"last_modified"`
-		Size         int64  `json:"size"`
-	}
-	jsonFiles := make([]jsonFile, len(files))
 	json := struct {
 		Files []jsonFile `json:"files"`
-		Path  string     `json:"path`
+		Total int        `json:"total"` // count of the files before paging
+		Path  string     `json:"path"`
 	}{
-		Path: path,
-	}
-	for i, file := range files {
-		jsonFiles[i].IsFolder = file.IsDir()
-		jsonFiles[i].Name = file.Name()
-		jsonFiles[i].LastModified = file.ModTime().Unix()
-		if !file.IsDir() {
-			jsonFiles[i].Size = file.Size()
-		}
+		Total: len(files),
+		Path:  path,
 	}
-	json.Files = jsonFiles
+	json.Files = sortAndPageFiles(files, opts)
 
 	writeJSON(writer, json)
 }
@@ -0,0 +1,266 @@
+package servers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bluele/gcache"
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hr3lxphr6j/bililive-go/src/configs"
+	"github.com/hr3lxphr6j/bililive-go/src/instance"
+	"github.com/hr3lxphr6j/bililive-go/src/interfaces"
+	"github.com/hr3lxphr6j/bililive-go/src/listeners"
+	"github.com/hr3lxphr6j/bililive-go/src/live"
+	"github.com/hr3lxphr6j/bililive-go/src/live/mock"
+	"github.com/hr3lxphr6j/bililive-go/src/recorders"
+)
+
+func TestPlanLiveRooms(t *testing.T) {
+	current := configs.NewConfig()
+	current.LiveRooms = []configs.LiveRoom{
+		{Url: "https://live.bilibili.com/1", IsListening: true},
+		{Url: "https://live.bilibili.com/2", IsListening: false},
+		{Url: "https://live.bilibili.com/3", IsListening: true},
+		{Url: "https://live.bilibili.com/4", IsListening: true},
+	}
+	plan := planLiveRooms(current, []configs.LiveRoom{
+		{Url: "https://live.bilibili.com/1", IsListening: true},
+		{Url: "https://live.bilibili.com/2", IsListening: true},
+		{Url: "https://live.bilibili.com/3", IsListening: false},
+		{Url: "https://live.bilibili.com/5", IsListening: true},
+		{Url: "https://live.bilibili.com/5", IsListening: false},
+	})
+	assert.Equal(t, liveRoomsPlan{
+		Added:          []string{"https://live.bilibili.com/5"},
+		Removed:        []string{"https://live.bilibili.com/4"},
+		StartListening: []string{"https://live.bilibili.com/2"},
+		StopListening:  []string{"https://live.bilibili.com/3"},
+	}, plan)
+	// nothing is changed by planning
+	assert.Len(t, current.LiveRooms, 4)
+	assert.False(t, current.LiveRooms[1].IsListening)
+}
+
+func TestRawConfigWithSecretsFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "servers")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	config := configs.NewConfig()
+	config.File = filepath.Join(dir, "config.yml")
+	config.OutPutPath = dir
+	config.SecretsFile = "secrets.yml"
+	config.Cookies = map[string]string{"live.bilibili.com": "SESSDATA=foo"}
+	assert.NoError(t, config.Marshal())
+	inst := &instance.Instance{Config: config}
+	ctx := context.WithValue(context.Background(), instance.Key, inst)
+
+	w := httptest.NewRecorder()
+	getRawConfig(w, httptest.NewRequest(http.MethodGet, "/api/raw-config", nil).WithContext(ctx))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "SESSDATA")
+	resp := map[string]string{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Contains(t, resp["config"], configs.SecretPlaceholder)
+
+	// put back as it is, with a new cookie of another platform
+	raw := strings.Replace(resp["config"], "cookies:\n", "cookies:\n  www.douyu.com: acf_uid=1\n", 1)
+	b, _ := json.Marshal(map[string]string{"config": raw})
+	w = httptest.NewRecorder()
+	putRawConfig(w, httptest.NewRequest(http.MethodPut, "/api/raw-config", bytes.NewReader(b)).WithContext(ctx))
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// the running config has the real cookies
+	assert.Equal(t, map[string]string{
+		"live.bilibili.com": "SESSDATA=foo",
+		"www.douyu.com":     "acf_uid=1",
+	}, inst.Config.Cookies)
+	// and the main config file has none of them
+	b, err = ioutil.ReadFile(config.File)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(b), "SESSDATA")
+	assert.NotContains(t, string(b), "acf_uid")
+	saved, err := configs.NewConfigWithFile(config.File)
+	assert.NoError(t, err)
+	assert.Equal(t, inst.Config.Cookies, saved.Cookies)
+}
+
+func TestSwitchProfile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "servers")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	config := configs.NewConfig()
+	config.File = filepath.Join(dir, "config.yml")
+	config.OutPutPath = dir
+	rooms := []configs.LiveRoom{{Url: "https://live.bilibili.com/1", LiveId: "1"}}
+	config.LiveRooms = append([]configs.LiveRoom(nil), rooms...)
+	config.Profiles = map[string]configs.Profile{
+		"archival": {LiveRooms: []configs.LiveRoom{{Url: "https://live.bilibili.com/1"}}},
+		"broken": {LiveRooms: []configs.LiveRoom{
+			{Url: "https://live.bilibili.com/1"},
+			{Url: "https://live.unsupported.example.com/2"},
+		}},
+	}
+	inst := &instance.Instance{
+		Config: config,
+		Logger: &interfaces.Logger{Logger: logrus.New()},
+	}
+	ctx := context.WithValue(context.Background(), instance.Key, inst)
+	do := func(name string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		b, _ := json.Marshal(map[string]string{"name": name})
+		switchProfile(w, httptest.NewRequest(http.MethodPost, "/api/config/profile", bytes.NewReader(b)).WithContext(ctx))
+		return w
+	}
+
+	// nothing is changed when it fails half way
+	w := do("broken")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, rooms, config.LiveRooms)
+	assert.Equal(t, "", config.ActiveProfile)
+	assert.Len(t, config.Profiles, 2)
+	_, err = os.Stat(config.File)
+	assert.True(t, os.IsNotExist(err))
+
+	// the rooms without an active profile are saved into the default one
+	w = do("archival")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "archival", config.ActiveProfile)
+	assert.Equal(t, rooms, config.Profiles[configs.DefaultProfileName].LiveRooms)
+	saved, err := configs.NewConfigWithFile(config.File)
+	assert.NoError(t, err)
+	assert.Equal(t, "archival", saved.ActiveProfile)
+	assert.Contains(t, saved.Profiles, configs.DefaultProfileName)
+
+	w = do("unknown")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestProcessFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "servers")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	outPutPath := filepath.Join(dir, "output")
+	assert.NoError(t, os.MkdirAll(filepath.Join(outPutPath, "host"), 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(outPutPath, "host", "1.flv"), []byte("FLV"), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "outside.flv"), []byte("FLV"), 0644))
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	l := mock.NewMockLive(ctrl)
+	config := configs.NewConfig()
+	config.OutPutPath = outPutPath
+	inst := &instance.Instance{
+		Config: config,
+		Lives:  map[live.ID]live.Live{"1": l},
+		Cache:  gcache.New(4).LRU().Build(),
+		Logger: &interfaces.Logger{Logger: logrus.New()},
+	}
+	ctx := context.WithValue(context.Background(), instance.Key, inst)
+	router := mux.NewRouter()
+	router.HandleFunc("/api/pipeline/process-file", processFile)
+	router.HandleFunc("/api/lives/{id}/process-file", processFile)
+	do := func(target string, body map[string]string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		b, _ := json.Marshal(body)
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, target, bytes.NewReader(b)).WithContext(ctx))
+		return w
+	}
+
+	w := do("/api/pipeline/process-file", map[string]string{"live_id": "2", "file": "host/1.flv"})
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), ErrCodeLiveNotFound)
+	w = do("/api/pipeline/process-file", map[string]string{"live_id": "1", "file": "../outside.flv"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), ErrCodePathInvalid)
+	w = do("/api/pipeline/process-file", map[string]string{"live_id": "1", "file": "host/missing.flv"})
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), ErrCodeFileNotFound)
+	w = do("/api/pipeline/process-file", map[string]string{"live_id": "1", "file": "host"})
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	// the alias takes the live id from the path
+	w = do("/api/lives/1/process-file", map[string]string{"file": "../outside.flv"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), ErrCodePathInvalid)
+}
+
+// fakeBuilder builds the lives of a fake platform, whose ids are the paths of the urls.
+type fakeBuilder struct {
+	ctrl *gomock.Controller
+}
+
+func (b fakeBuilder) Build(u *url.URL, opts ...live.Option) (live.Live, error) {
+	l := mock.NewMockLive(b.ctrl)
+	l.EXPECT().GetLiveId().Return(live.ID(u.Path)).AnyTimes()
+	l.EXPECT().GetRawUrl().Return(u.String()).AnyTimes()
+	l.EXPECT().GetInfo().Return(&live.Info{Live: l, HostName: "host", RoomName: "room"}, nil).AnyTimes()
+	return l, nil
+}
+
+func TestImportLiveRooms(t *testing.T) {
+	dir, err := ioutil.TempDir("", "servers")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	live.Register("import.example.com", fakeBuilder{ctrl: ctrl})
+
+	config := configs.NewConfig()
+	config.File = filepath.Join(dir, "config.yml")
+	config.OutPutPath = dir
+	config.LiveRooms = []configs.LiveRoom{{Url: "https://import.example.com/1", LiveId: "/1"}}
+	inst := &instance.Instance{
+		Config: config,
+		Lives:  map[live.ID]live.Live{},
+		Cache:  gcache.New(4).LRU().Build(),
+		Logger: &interfaces.Logger{Logger: logrus.New()},
+	}
+	ctx := context.WithValue(context.Background(), instance.Key, inst)
+	listeners.NewManager(ctx)
+	recorders.NewManager(ctx)
+
+	w := httptest.NewRecorder()
+	body := "live_rooms:\n" +
+		"  - url: https://import.example.com/1\n    is_listening: false\n" +
+		"  - url: https://import.example.com/2\n    is_listening: false\n" +
+		"  - url: https://import.example.com/3\n    is_listening: false\n"
+	importLiveRooms(w, httptest.NewRequest(http.MethodPost, "/api/lives/import", strings.NewReader(body)).WithContext(ctx))
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	resp := struct {
+		Added      int      `json:"added"`
+		Skipped    int      `json:"skipped"`
+		Duplicates []string `json:"duplicates"`
+		Errors     []string `json:"errors"`
+	}{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, 2, resp.Added)
+	assert.Equal(t, 1, resp.Skipped)
+	assert.Equal(t, []string{"https://import.example.com/1"}, resp.Duplicates)
+	assert.Empty(t, resp.Errors)
+	assert.Len(t, inst.Lives, 2)
+
+	// saved with the new rooms
+	saved, err := configs.NewConfigWithFile(config.File)
+	assert.NoError(t, err)
+	urls := make([]string, 0)
+	for _, room := range saved.LiveRooms {
+		urls = append(urls, room.Url)
+	}
+	assert.Equal(t, []string{
+		"https://import.example.com/1",
+		"https://import.example.com/2",
+		"https://import.example.com/3",
+	}, urls)
+}
@@ -0,0 +1,62 @@
+package servers
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/hr3lxphr6j/bililive-go/src/instance"
+	"github.com/hr3lxphr6j/bililive-go/src/live"
+)
+
+func startLogin(writer http.ResponseWriter, r *http.Request) {
+	assist, err := live.GetLoginAssist(mux.Vars(r)["platform"])
+	if err != nil {
+		writeError(writer, http.StatusNotFound, errCodeOf(err, ErrCodeInternal), err.Error())
+		return
+	}
+	session, err := assist.StartLogin()
+	if err != nil {
+		writeError(writer, http.StatusBadGateway, ErrCodeUpstreamFailed, err.Error())
+		return
+	}
+	writeJSON(writer, session)
+}
+
+// pollLogin saves the cookies to the config once the login is succeeded,
+// they are used by the rooms added afterwards.
+func pollLogin(writer http.ResponseWriter, r *http.Request) {
+	platform := mux.Vars(r)["platform"]
+	assist, err := live.GetLoginAssist(platform)
+	if err != nil {
+		writeError(writer, http.StatusNotFound, errCodeOf(err, ErrCodeInternal), err.Error())
+		return
+	}
+	key := r.URL.Query().Get("key")
+	if key == "" {
+		writeError(writer, http.StatusBadRequest, ErrCodeInvalidBody, "key is required")
+		return
+	}
+	result, err := assist.PollLogin(key)
+	if err != nil {
+		writeError(writer, http.StatusBadGateway, ErrCodeUpstreamFailed, err.Error())
+		return
+	}
+	if result.Status == live.LoginSucceeded {
+		inst := instance.GetInstance(r.Context())
+		if inst.Config.Cookies == nil {
+			inst.Config.Cookies = make(map[string]string)
+		}
+		inst.Config.Cookies[platform] = result.Cookies
+		if inst.Config.File != "" {
+			if err := inst.Config.Marshal(); err != nil {
+				writeError(writer, http.StatusInternalServerError, ErrCodeConfigSaveFailed, err.Error())
+				return
+			}
+		}
+		dispatchConfigChanged(r.Context(), []string{"cookies"})
+	}
+	writeJSON(writer, map[string]interface{}{
+		"status": result.Status,
+	})
+}
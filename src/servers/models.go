@@ -5,9 +5,10 @@ import (
 )
 
 type commonResp struct {
-	ErrNo  int         `json:"err_no"`
-	ErrMsg string      `json:"err_msg"`
-	Data   interface{} `json:"data"`
+	ErrNo   int         `json:"err_no"`
+	ErrCode string      `json:"err_code,omitempty"` // stable reason of the error, see errors.go
+	ErrMsg  string      `json:"err_msg"`
+	Data    interface{} `json:"data"`
 }
 
 type liveSlice []*live.Info
@@ -0,0 +1,96 @@
+package servers
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/hr3lxphr6j/bililive-go/src/instance"
+	"github.com/hr3lxphr6j/bililive-go/src/listeners"
+	"github.com/hr3lxphr6j/bililive-go/src/live"
+	"github.com/hr3lxphr6j/bililive-go/src/pkg/events"
+	"github.com/hr3lxphr6j/bililive-go/src/recorders"
+)
+
+const (
+	osrpVersion       = "0.1.0"
+	osrpVersionHeader = "X-OSRP-Version"
+)
+
+// the internal events and their names in the OSRP event taxonomy
+var osrpEventNames = map[events.EventType]string{
+	recorders.RecorderStart: "task.start",
+	recorders.RecorderStop:  "task.stop",
+	listeners.LiveStart:     "stream.available",
+}
+
+// osrpServer serves the OSRP (open stream recorder protocol) endpoints.
+type osrpServer struct {
+	hub *sseHub
+}
+
+func newOSRPServer(ctx context.Context) *osrpServer {
+	s := &osrpServer{hub: newSSEHub()}
+	ed, ok := instance.GetInstance(ctx).EventDispatcher.(events.Dispatcher)
+	if !ok {
+		return s
+	}
+	for evtType, name := range osrpEventNames {
+		name := name
+		ed.AddEventListener(evtType, events.NewEventListener(func(event *events.Event) {
+			l := event.Object.(live.Live)
+			s.hub.broadcast(name, map[string]interface{}{
+				"live_id":  l.GetLiveId(),
+				"url":      l.GetRawUrl(),
+				"platform": l.GetPlatformCNName(),
+			})
+		}))
+	}
+	return s
+}
+
+// authorized checks the bearer token of the /osrp endpoints, no token is needed when it's not set.
+func (s *osrpServer) authorized(r *http.Request) bool {
+	token := instance.GetInstance(r.Context()).Config.RPC.OSRPToken
+	if token == "" {
+		return true
+	}
+	return bearerTokenMatches(r, token)
+}
+
+// bearerTokenMatches checks the bearer token in the Authorization header, or in the access_token
+// query parameter as EventSource and the players can not set headers.
+func bearerTokenMatches(r *http.Request, token string) bool {
+	got := r.URL.Query().Get("access_token")
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		got = strings.TrimPrefix(auth, "Bearer ")
+	}
+	return subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
+
+// serveSSE streams the events listed in the events query parameter, all of them when it's empty.
+func (s *osrpServer) serveSSE(writer http.ResponseWriter, r *http.Request) {
+	writer.Header().Set(osrpVersionHeader, osrpVersion)
+	if !s.authorized(r) {
+		writeError(writer, http.StatusUnauthorized, ErrCodeUnauthorized, "invalid token")
+		return
+	}
+	var filter map[string]bool
+	if param := r.URL.Query().Get("events"); param != "" {
+		known := make(map[string]bool, len(osrpEventNames))
+		for _, name := range osrpEventNames {
+			known[name] = true
+		}
+		filter = make(map[string]bool)
+		for _, name := range strings.Split(param, ",") {
+			name = strings.TrimSpace(name)
+			if !known[name] {
+				writeError(writer, http.StatusBadRequest, ErrCodeInvalidAction, "unknown event: "+name)
+				return
+			}
+			filter[name] = true
+		}
+	}
+	s.hub.serve(writer, r, filter, nil)
+}
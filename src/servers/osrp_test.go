@@ -0,0 +1,90 @@
+package servers
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hr3lxphr6j/bililive-go/src/configs"
+	"github.com/hr3lxphr6j/bililive-go/src/instance"
+	"github.com/hr3lxphr6j/bililive-go/src/listeners"
+	"github.com/hr3lxphr6j/bililive-go/src/live"
+	livemock "github.com/hr3lxphr6j/bililive-go/src/live/mock"
+	"github.com/hr3lxphr6j/bililive-go/src/pkg/events"
+	"github.com/hr3lxphr6j/bililive-go/src/recorders"
+)
+
+func newOSRPTestServer(token string) (*httptest.Server, events.Dispatcher) {
+	config := configs.NewConfig()
+	config.RPC.OSRPToken = token
+	inst := &instance.Instance{Config: config}
+	ctx := context.WithValue(context.Background(), instance.Key, inst)
+	ed := events.NewDispatcher(ctx)
+	osrp := newOSRPServer(ctx)
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		osrp.serveSSE(w, r.WithContext(context.WithValue(r.Context(), instance.Key, inst)))
+	})), ed
+}
+
+func TestOSRPEventMapping(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	server, ed := newOSRPTestServer("")
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "?events=task.start,stream.available")
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, osrpVersion, resp.Header.Get(osrpVersionHeader))
+
+	l := livemock.NewMockLive(ctrl)
+	l.EXPECT().GetLiveId().Return(live.ID("test")).AnyTimes()
+	l.EXPECT().GetRawUrl().Return("https://live.bilibili.com/1").AnyTimes()
+	l.EXPECT().GetPlatformCNName().Return("哔哩哔哩").AnyTimes()
+	r := bufio.NewReader(resp.Body)
+	readEvent := func() string {
+		line, err := r.ReadString('\n')
+		assert.NoError(t, err)
+		_, err = r.ReadString('\n') // data
+		assert.NoError(t, err)
+		_, err = r.ReadString('\n') // blank line
+		assert.NoError(t, err)
+		return line
+	}
+
+	assert.Equal(t, []string{"retry: 3000"}, readSSEBlock(t, r))
+	// task.stop is not subscribed
+	ed.DispatchEvent(events.NewEvent(recorders.RecorderStop, l))
+	ed.DispatchEvent(events.NewEvent(recorders.RecorderStart, l))
+	assert.Equal(t, "event: task.start\n", readEvent())
+	ed.DispatchEvent(events.NewEvent(listeners.LiveStart, l))
+	assert.Equal(t, "event: stream.available\n", readEvent())
+}
+
+func TestOSRPAuth(t *testing.T) {
+	server, _ := newOSRPTestServer("secret")
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Equal(t, osrpVersion, resp.Header.Get(osrpVersionHeader))
+
+	resp, err = http.Get(server.URL + "?events=unknown&access_token=secret")
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err = http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
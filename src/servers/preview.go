@@ -0,0 +1,258 @@
+package servers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/hr3lxphr6j/bililive-go/src/instance"
+	"github.com/hr3lxphr6j/bililive-go/src/live"
+)
+
+const (
+	previewSegmentCount = 3
+	previewCacheTTL     = 30 * time.Second
+	previewTimeout      = 15 * time.Second
+
+	contentTypeM3U8 = "application/vnd.apple.mpegurl"
+	contentTypeTS   = "video/mp2t"
+)
+
+var (
+	errPreviewNotHLS        = errors.New("stream of this live is not hls, preview is not supported")
+	errPreviewNoSegments    = errors.New("no segment found in the playlist")
+	errPreviewSegmentExpire = errors.New("preview segment not found or expired")
+
+	previews          = &previewCache{entries: make(map[live.ID]*preview)}
+	previewHttpClient = &http.Client{Timeout: previewTimeout}
+)
+
+type previewSegment struct {
+	duration float64
+	data     []byte
+}
+
+type preview struct {
+	segments  []previewSegment
+	clip      []byte // the mp4 of getLivePreview
+	fetchedAt time.Time
+}
+
+type previewCache struct {
+	sync.Mutex
+	entries map[live.ID]*preview
+}
+
+func (c *previewCache) get(id live.ID) (*preview, bool) {
+	c.Lock()
+	defer c.Unlock()
+	p, ok := c.entries[id]
+	if !ok || time.Since(p.fetchedAt) > previewCacheTTL {
+		delete(c.entries, id)
+		return nil, false
+	}
+	return p, true
+}
+
+func (c *previewCache) set(id live.ID, p *preview) {
+	c.Lock()
+	defer c.Unlock()
+	c.entries[id] = p
+}
+
+type playlistEntry struct {
+	uri       *url.URL
+	duration  float64
+	bandwidth int64
+}
+
+// parsePlaylist returns the variants of a master playlist, or the segments of a media playlist.
+func parsePlaylist(base *url.URL, b []byte) (variants, segments []playlistEntry, err error) {
+	var (
+		scanner   = bufio.NewScanner(bytes.NewReader(b))
+		duration  float64
+		bandwidth int64
+		isVariant bool
+	)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "#EXTINF:"):
+			value := strings.SplitN(strings.TrimPrefix(line, "#EXTINF:"), ",", 2)[0]
+			duration, _ = strconv.ParseFloat(value, 64)
+		case strings.HasPrefix(line, "#EXT-X-STREAM-INF:"):
+			isVariant = true
+			bandwidth = 0
+			for _, attr := range strings.Split(strings.TrimPrefix(line, "#EXT-X-STREAM-INF:"), ",") {
+				if kv := strings.SplitN(attr, "=", 2); len(kv) == 2 && kv[0] == "BANDWIDTH" {
+					bandwidth, _ = strconv.ParseInt(kv[1], 10, 64)
+				}
+			}
+		case strings.HasPrefix(line, "#"):
+		default:
+			u, err := base.Parse(line)
+			if err != nil {
+				return nil, nil, err
+			}
+			if isVariant {
+				variants = append(variants, playlistEntry{uri: u, bandwidth: bandwidth})
+			} else {
+				segments = append(segments, playlistEntry{uri: u, duration: duration})
+			}
+			isVariant, duration = false, 0
+		}
+	}
+	return variants, segments, scanner.Err()
+}
+
+func fetchPreviewData(ctx context.Context, u *url.URL, headers map[string]string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := previewHttpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch %s, status code: %d", u, resp.StatusCode)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// fetchPreview downloads the first segments of the hls stream of the live,
+// the variant with the lowest bandwidth is used when the playlist is a master playlist.
+func fetchPreview(ctx context.Context, l live.Live) (*preview, error) {
+	urls, err := l.GetStreamUrls()
+	if err != nil {
+		return nil, err
+	}
+	var playlistUrl *url.URL
+	for _, u := range urls {
+		if strings.Contains(u.Path, "m3u8") {
+			playlistUrl = u
+			break
+		}
+	}
+	if playlistUrl == nil {
+		return nil, errPreviewNotHLS
+	}
+	headers := l.GetHeadersForDownloader()
+	b, err := fetchPreviewData(ctx, playlistUrl, headers)
+	if err != nil {
+		return nil, err
+	}
+	variants, segments, err := parsePlaylist(playlistUrl, b)
+	if err != nil {
+		return nil, err
+	}
+	if len(variants) > 0 {
+		lowest := variants[0]
+		for _, v := range variants[1:] {
+			if v.bandwidth < lowest.bandwidth {
+				lowest = v
+			}
+		}
+		if b, err = fetchPreviewData(ctx, lowest.uri, headers); err != nil {
+			return nil, err
+		}
+		if _, segments, err = parsePlaylist(lowest.uri, b); err != nil {
+			return nil, err
+		}
+	}
+	if len(segments) == 0 {
+		return nil, errPreviewNoSegments
+	}
+	if len(segments) > previewSegmentCount {
+		segments = segments[:previewSegmentCount]
+	}
+	p := &preview{
+		segments:  make([]previewSegment, 0, len(segments)),
+		fetchedAt: time.Now(),
+	}
+	for _, s := range segments {
+		data, err := fetchPreviewData(ctx, s.uri, headers)
+		if err != nil {
+			return nil, err
+		}
+		p.segments = append(p.segments, previewSegment{duration: s.duration, data: data})
+	}
+	return p, nil
+}
+
+// playlist renders a finished m3u8 whose segments are served by getStreamPreviewSegment.
+func (p *preview) playlist() []byte {
+	targetDuration := 1.0
+	for _, s := range p.segments {
+		targetDuration = math.Max(targetDuration, s.duration)
+	}
+	buf := new(bytes.Buffer)
+	fmt.Fprintln(buf, "#EXTM3U")
+	fmt.Fprintln(buf, "#EXT-X-VERSION:3")
+	fmt.Fprintf(buf, "#EXT-X-TARGETDURATION:%d\n", int(math.Ceil(targetDuration)))
+	fmt.Fprintln(buf, "#EXT-X-MEDIA-SEQUENCE:0")
+	for i, s := range p.segments {
+		fmt.Fprintf(buf, "#EXTINF:%.3f,\n", s.duration)
+		fmt.Fprintf(buf, "stream-preview/%d.ts\n", i)
+	}
+	fmt.Fprintln(buf, "#EXT-X-ENDLIST")
+	return buf.Bytes()
+}
+
+func getStreamPreview(writer http.ResponseWriter, r *http.Request) {
+	inst := instance.GetInstance(r.Context())
+	vars := mux.Vars(r)
+	l, ok := inst.Lives[live.ID(vars["id"])]
+	if !ok {
+		writeLiveNotFound(writer, vars["id"])
+		return
+	}
+	p, ok := previews.get(l.GetLiveId())
+	if !ok {
+		if obj, err := inst.Cache.Get(l); err != nil || !obj.(*live.Info).Status {
+			writeError(writer, http.StatusTooEarly, ErrCodeLiveNotStreaming, "the live is not streaming")
+			return
+		}
+		var err error
+		if p, err = fetchPreview(r.Context(), l); err != nil {
+			if err == errPreviewNotHLS {
+				writeError(writer, http.StatusNotImplemented, ErrCodeStreamNotHLS, err.Error())
+			} else {
+				writeError(writer, http.StatusBadGateway, ErrCodeUpstreamFailed, err.Error())
+			}
+			return
+		}
+		previews.set(l.GetLiveId(), p)
+	}
+	writer.Header().Set(contentType, contentTypeM3U8)
+	_, _ = writer.Write(p.playlist())
+}
+
+func getStreamPreviewSegment(writer http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	index, err := strconv.Atoi(vars["index"])
+	p, ok := previews.get(live.ID(vars["id"]))
+	if err != nil || !ok || index < 0 || index >= len(p.segments) {
+		writeError(writer, http.StatusNotFound, ErrCodeFileNotFound, errPreviewSegmentExpire.Error())
+		return
+	}
+	writer.Header().Set(contentType, contentTypeTS)
+	_, _ = writer.Write(p.segments[index].data)
+}
@@ -0,0 +1,111 @@
+package servers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/hr3lxphr6j/bililive-go/src/instance"
+	"github.com/hr3lxphr6j/bililive-go/src/live"
+	"github.com/hr3lxphr6j/bililive-go/src/pkg/utils"
+)
+
+const (
+	previewClipSeconds = 10
+	previewClipHeight  = 360
+	// the ffmpeg processes making clips at the same time, the others get 429
+	previewClipConcurrency = 2
+
+	contentTypeMP4 = "video/mp4"
+)
+
+var (
+	previewClips         = &previewCache{entries: make(map[live.ID]*preview)}
+	previewClipSemaphore = make(chan struct{}, previewClipConcurrency)
+)
+
+// for test
+var (
+	previewClipTimeout = 30 * time.Second
+	makePreviewClip    = func(ctx context.Context, ffmpegPath string, u *url.URL, headers map[string]string) ([]byte, error) {
+		buf, stderr := new(bytes.Buffer), new(bytes.Buffer)
+		cmd := exec.CommandContext(ctx, ffmpegPath, previewClipArgs(u, headers)...)
+		cmd.Stdout, cmd.Stderr = buf, stderr
+		// the preview should never slow the recordings down
+		_ = utils.ApplyProcessPriority(cmd, 19, 0)
+		if err := cmd.Run(); err != nil {
+			return nil, fmt.Errorf("%v: %s", err, strings.TrimSpace(stderr.String()))
+		}
+		return buf.Bytes(), nil
+	}
+)
+
+// previewClipArgs are the args of ffmpeg to transcode the first seconds of the stream into
+// a small fragmented mp4, which can be played by the browsers while it's downloading.
+func previewClipArgs(u *url.URL, headers map[string]string) []string {
+	args := []string{"-hide_banner", "-v", "error", "-nostdin"}
+	args = append(args, utils.FFmpegHeaderArgs(headers)...)
+	return append(args,
+		"-t", fmt.Sprint(previewClipSeconds),
+		"-i", u.String(),
+		"-vf", fmt.Sprintf("scale=-2:%d", previewClipHeight),
+		"-c:v", "libx264", "-preset", "ultrafast", "-b:v", "500k", "-threads", "1",
+		"-c:a", "aac", "-b:a", "64k",
+		"-movflags", "frag_keyframe+empty_moov",
+		"-f", "mp4", "pipe:1",
+	)
+}
+
+// getLivePreview serves a short low resolution mp4 clip of the current stream of the live,
+// unlike getStreamPreview it works with any stream ffmpeg can read. The clip is cached for a while.
+func getLivePreview(writer http.ResponseWriter, r *http.Request) {
+	inst := instance.GetInstance(r.Context())
+	vars := mux.Vars(r)
+	l, ok := inst.Lives[live.ID(vars["id"])]
+	if !ok {
+		writeLiveNotFound(writer, vars["id"])
+		return
+	}
+	p, ok := previewClips.get(l.GetLiveId())
+	if !ok {
+		if obj, err := inst.Cache.Get(l); err != nil || !obj.(*live.Info).Status {
+			writeError(writer, http.StatusTooEarly, ErrCodeLiveNotStreaming, "the live is not streaming")
+			return
+		}
+		select {
+		case previewClipSemaphore <- struct{}{}:
+			defer func() { <-previewClipSemaphore }()
+		default:
+			writeError(writer, http.StatusTooManyRequests, ErrCodeTooManyRequests, "too many previews are being made, try again later")
+			return
+		}
+		ffmpegPath, err := utils.GetFFmpegPath(r.Context())
+		if err != nil {
+			writeError(writer, http.StatusInternalServerError, ErrCodeInternal, err.Error())
+			return
+		}
+		urls, err := l.GetStreamUrls()
+		if err != nil || len(urls) == 0 {
+			writeError(writer, http.StatusBadGateway, ErrCodeStreamUrlNotFound, "failed to get the stream url")
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), previewClipTimeout)
+		defer cancel()
+		data, err := makePreviewClip(ctx, ffmpegPath, urls[0], l.GetHeadersForDownloader())
+		if err != nil {
+			writeError(writer, http.StatusBadGateway, ErrCodeUpstreamFailed, err.Error())
+			return
+		}
+		p = &preview{clip: data, fetchedAt: time.Now()}
+		previewClips.set(l.GetLiveId(), p)
+	}
+	writer.Header().Set(contentType, contentTypeMP4)
+	_, _ = writer.Write(p.clip)
+}
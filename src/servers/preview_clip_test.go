@@ -0,0 +1,71 @@
+package servers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/bluele/gcache"
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hr3lxphr6j/bililive-go/src/configs"
+	"github.com/hr3lxphr6j/bililive-go/src/instance"
+	"github.com/hr3lxphr6j/bililive-go/src/live"
+	"github.com/hr3lxphr6j/bililive-go/src/live/mock"
+)
+
+func TestPreviewClipArgs(t *testing.T) {
+	u, _ := url.Parse("https://example.com/live.flv")
+	args := previewClipArgs(u, map[string]string{"User-Agent": "test", "Referer": "https://example.com", "Cookie": "a=b"})
+	assert.Equal(t, []string{"-hide_banner", "-v", "error", "-nostdin",
+		"-user_agent", "test", "-referer", "https://example.com", "-headers", "Cookie: a=b\r\n",
+		"-t", "10", "-i", "https://example.com/live.flv"}, args[:14])
+	assert.Equal(t, "pipe:1", args[len(args)-1])
+}
+
+func TestGetLivePreview(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	u, _ := url.Parse("https://example.com/live.flv")
+	l := mock.NewMockLive(ctrl)
+	l.EXPECT().GetLiveId().Return(live.ID("test")).AnyTimes()
+	l.EXPECT().GetStreamUrls().Return([]*url.URL{u}, nil)
+	l.EXPECT().GetHeadersForDownloader().Return(nil)
+	cache := gcache.New(4).LRU().Build()
+	cache.Set(l, &live.Info{Live: l, Status: true})
+	cfg := configs.NewConfig()
+	cfg.FfmpegPath = os.Args[0]
+	inst := &instance.Instance{Lives: map[live.ID]live.Live{"test": l}, Cache: cache, Config: cfg}
+
+	backup := makePreviewClip
+	defer func() { makePreviewClip = backup }()
+	calls := 0
+	makePreviewClip = func(_ context.Context, ffmpegPath string, got *url.URL, _ map[string]string) ([]byte, error) {
+		calls++
+		assert.Equal(t, os.Args[0], ffmpegPath)
+		assert.Equal(t, u, got)
+		return []byte("mp4"), nil
+	}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/lives/{id}/preview", getLivePreview)
+	get := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/lives/test/preview", nil)
+		router.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), instance.Key, inst)))
+		return w
+	}
+	for i := 0; i < 2; i++ {
+		w := get()
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, contentTypeMP4, w.Header().Get(contentType))
+		assert.Equal(t, "mp4", w.Body.String())
+	}
+	// cached
+	assert.Equal(t, 1, calls)
+}
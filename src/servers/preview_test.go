@@ -0,0 +1,69 @@
+package servers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hr3lxphr6j/bililive-go/src/live/mock"
+)
+
+func TestFetchPreview(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/master.m3u8", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "test", r.Header.Get("User-Agent"))
+		fmt.Fprint(w, "#EXTM3U\n"+
+			"#EXT-X-STREAM-INF:BANDWIDTH=2000000\nhigh.m3u8\n"+
+			"#EXT-X-STREAM-INF:BANDWIDTH=500000\nlow.m3u8\n")
+	})
+	mux.HandleFunc("/low.m3u8", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "#EXTM3U\n#EXT-X-TARGETDURATION:2\n")
+		for i := 0; i < 5; i++ {
+			fmt.Fprintf(w, "#EXTINF:1.5,\nseg%d.ts\n", i)
+		}
+	})
+	mux.HandleFunc("/high.m3u8", func(w http.ResponseWriter, r *http.Request) {
+		t.Error("the variant with the lowest bandwidth should be used")
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, strings.TrimPrefix(r.URL.Path, "/"))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	u, _ := url.Parse(server.URL + "/master.m3u8")
+	l := mock.NewMockLive(ctrl)
+	l.EXPECT().GetStreamUrls().Return([]*url.URL{u}, nil)
+	l.EXPECT().GetHeadersForDownloader().Return(map[string]string{"User-Agent": "test"})
+
+	p, err := fetchPreview(context.Background(), l)
+	assert.NoError(t, err)
+	assert.Len(t, p.segments, previewSegmentCount)
+	for i, s := range p.segments {
+		assert.Equal(t, fmt.Sprintf("seg%d.ts", i), string(s.data))
+	}
+	playlist := string(p.playlist())
+	assert.Equal(t, previewSegmentCount, strings.Count(playlist, "#EXTINF:"))
+	assert.Contains(t, playlist, "stream-preview/2.ts")
+	assert.Contains(t, playlist, "#EXT-X-ENDLIST")
+}
+
+func TestFetchPreviewNotHLS(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	u, _ := url.Parse("https://example.com/live.flv")
+	l := mock.NewMockLive(ctrl)
+	l.EXPECT().GetStreamUrls().Return([]*url.URL{u}, nil)
+
+	_, err := fetchPreview(context.Background(), l)
+	assert.Equal(t, errPreviewNotHLS, err)
+}
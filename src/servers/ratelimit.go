@@ -0,0 +1,147 @@
+package servers
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hr3lxphr6j/bililive-go/src/configs"
+	"github.com/hr3lxphr6j/bililive-go/src/instance"
+)
+
+// buckets which are full and idle for this duration are dropped
+const rateLimitBucketIdle = 10 * time.Minute
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiter is a per client token bucket limiter, the rate is read from
+// the config on every request so that it follows the config changes.
+type rateLimiter struct {
+	sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+
+	// for test
+	now func() time.Time
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{
+		buckets: make(map[string]*tokenBucket),
+		now:     time.Now,
+	}
+}
+
+func rateOf(cfg configs.RateLimit) (perSecond, burst float64) {
+	burst = float64(cfg.BurstSize)
+	if cfg.BurstSize <= 0 {
+		burst = float64(cfg.RequestsPerMinute)
+	}
+	return float64(cfg.RequestsPerMinute) / 60, burst
+}
+
+// refill must be called with the lock held.
+func (l *rateLimiter) refill(client string, cfg configs.RateLimit, now time.Time) *tokenBucket {
+	perSecond, burst := rateOf(cfg)
+	b, ok := l.buckets[client]
+	if !ok {
+		b = &tokenBucket{tokens: burst, last: now}
+		l.buckets[client] = b
+	}
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*perSecond)
+	b.last = now
+	if now.Sub(l.lastSweep) > rateLimitBucketIdle {
+		for k, v := range l.buckets {
+			if now.Sub(v.last) > rateLimitBucketIdle {
+				delete(l.buckets, k)
+			}
+		}
+		l.lastSweep = now
+	}
+	return b
+}
+
+// allow takes a token of the client, retryAfter is set when no token is left.
+func (l *rateLimiter) allow(client string, cfg configs.RateLimit) (ok bool, retryAfter time.Duration) {
+	l.Lock()
+	defer l.Unlock()
+	b := l.refill(client, cfg, l.now())
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	perSecond, _ := rateOf(cfg)
+	return false, time.Duration((1 - b.tokens) / perSecond * float64(time.Second))
+}
+
+func (l *rateLimiter) remaining(client string, cfg configs.RateLimit) int {
+	l.Lock()
+	defer l.Unlock()
+	return int(l.refill(client, cfg, l.now()).tokens)
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func isRateLimitExempt(cfg configs.RateLimit, ip string) bool {
+	if cfg.RequestsPerMinute <= 0 {
+		return true
+	}
+	if parsed := net.ParseIP(ip); cfg.ExemptLocalhost && parsed != nil && parsed.IsLoopback() {
+		return true
+	}
+	return false
+}
+
+func (l *rateLimiter) middleware(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cfg := instance.GetInstance(r.Context()).Config.RPC.RateLimit
+		ip := clientIP(r)
+		if !isRateLimitExempt(cfg, ip) {
+			if ok, retryAfter := l.allow(ip, cfg); !ok {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+				writeError(w, http.StatusTooManyRequests, ErrCodeTooManyRequests, "too many requests")
+				return
+			}
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+type rateLimitStatus struct {
+	IP                string `json:"ip"`
+	Enabled           bool   `json:"enabled"`
+	Exempt            bool   `json:"exempt"`
+	RequestsPerMinute int    `json:"requests_per_minute"`
+	BurstSize         int    `json:"burst_size,omitempty"`
+	Remaining         *int   `json:"remaining,omitempty"`
+}
+
+func (l *rateLimiter) getClientStatus(writer http.ResponseWriter, r *http.Request) {
+	cfg := instance.GetInstance(r.Context()).Config.RPC.RateLimit
+	ip := clientIP(r)
+	status := rateLimitStatus{
+		IP:                ip,
+		Enabled:           cfg.RequestsPerMinute > 0,
+		Exempt:            isRateLimitExempt(cfg, ip),
+		RequestsPerMinute: cfg.RequestsPerMinute,
+	}
+	if !status.Exempt {
+		_, burst := rateOf(cfg)
+		remaining := l.remaining(ip, cfg)
+		status.BurstSize = int(burst)
+		status.Remaining = &remaining
+	}
+	writeJSON(writer, status)
+}
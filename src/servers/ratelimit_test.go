@@ -0,0 +1,83 @@
+package servers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hr3lxphr6j/bililive-go/src/configs"
+	"github.com/hr3lxphr6j/bililive-go/src/instance"
+)
+
+func newRateLimitTestServer(cfg configs.RateLimit, limiter *rateLimiter) http.Handler {
+	config := configs.NewConfig()
+	config.RPC.RateLimit = cfg
+	inst := &instance.Instance{Config: config}
+	h := limiter.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), instance.Key, inst)))
+	})
+}
+
+func doRequest(h http.Handler, remoteAddr string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, "/api/info", nil)
+	r.RemoteAddr = remoteAddr
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	now := time.Unix(0, 0)
+	limiter := newRateLimiter()
+	limiter.now = func() time.Time { return now }
+	h := newRateLimitTestServer(configs.RateLimit{RequestsPerMinute: 60, BurstSize: 5, ExemptLocalhost: true}, limiter)
+
+	var (
+		wg      sync.WaitGroup
+		allowed int32
+		limited int32
+	)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := doRequest(h, "10.0.0.1:1234")
+			switch w.Code {
+			case http.StatusOK:
+				atomic.AddInt32(&allowed, 1)
+			case http.StatusTooManyRequests:
+				atomic.AddInt32(&limited, 1)
+				assert.Equal(t, "1", w.Header().Get("Retry-After"))
+			}
+		}()
+	}
+	wg.Wait()
+	assert.EqualValues(t, 5, allowed)
+	assert.EqualValues(t, 15, limited)
+
+	// other clients have their own buckets, and localhost is exempt
+	assert.Equal(t, http.StatusOK, doRequest(h, "10.0.0.2:1234").Code)
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, http.StatusOK, doRequest(h, "127.0.0.1:1234").Code)
+	}
+
+	// one token per second is refilled
+	now = now.Add(2 * time.Second)
+	assert.Equal(t, http.StatusOK, doRequest(h, "10.0.0.1:1234").Code)
+	assert.Equal(t, http.StatusOK, doRequest(h, "10.0.0.1:1234").Code)
+	assert.Equal(t, http.StatusTooManyRequests, doRequest(h, "10.0.0.1:1234").Code)
+}
+
+func TestRateLimitDisabled(t *testing.T) {
+	h := newRateLimitTestServer(configs.RateLimit{}, newRateLimiter())
+	for i := 0; i < 100; i++ {
+		assert.Equal(t, http.StatusOK, doRequest(h, "10.0.0.1:1234").Code)
+	}
+}
@@ -38,20 +38,56 @@ func initMux(ctx context.Context) *mux.Router {
 
 	// api router
 	apiRoute := m.PathPrefix(apiRouterPrefix).Subrouter()
-	apiRoute.Use(mux.CORSMethodMiddleware(apiRoute))
+	limiter := newRateLimiter()
+	apiRoute.Use(mux.CORSMethodMiddleware(apiRoute), limiter.middleware)
 	apiRoute.HandleFunc("/info", getInfo).Methods("GET")
+	hub := newSSEHub()
+	hub.registryListener(ctx)
+	feed := newErrorFeed(hub)
+	feed.registryListener(ctx)
+	apiRoute.HandleFunc("/events", hub.serveEvents).Methods("GET")
+	apiRoute.HandleFunc("/system/disk-space", getDiskSpace).Methods("GET")
+	apiRoute.HandleFunc("/dashboard/summary", getDashboardSummary).Methods("GET")
+	apiRoute.HandleFunc("/system/paths/diagnose", diagnosePaths).Methods("GET")
+	apiRoute.HandleFunc("/system/cache", getCacheStats).Methods("GET")
+	apiRoute.HandleFunc("/system/cache/clear", clearCache).Methods("POST")
+	apiRoute.HandleFunc("/system/errors", feed.getErrors).Methods("GET")
+	apiRoute.HandleFunc("/platforms/status", getPlatformStatuses).Methods("GET")
+	apiRoute.HandleFunc("/platforms/{key}/selftest", selfTestPlatform).Methods("GET")
+	apiRoute.HandleFunc("/ratelimit/client-status", limiter.getClientStatus).Methods("GET")
 	apiRoute.HandleFunc("/config", getConfig).Methods("GET")
 	apiRoute.HandleFunc("/config", putConfig).Methods("PUT")
+	apiRoute.HandleFunc("/config/import-rooms", importLiveRooms).Methods("POST")
+	apiRoute.HandleFunc("/config/import-external", importExternalLiveRooms).Methods("POST")
+	apiRoute.HandleFunc("/config/profile", switchProfile).Methods("POST")
 	apiRoute.HandleFunc("/raw-config", getRawConfig).Methods("GET")
 	apiRoute.HandleFunc("/raw-config", putRawConfig).Methods("PUT")
 	apiRoute.HandleFunc("/lives", getAllLives).Methods("GET")
 	apiRoute.HandleFunc("/lives", addLives).Methods("POST")
+	apiRoute.HandleFunc("/lives/login/{platform}/start", startLogin).Methods("POST")
+	apiRoute.HandleFunc("/lives/login/{platform}/poll", pollLogin).Methods("GET")
 	apiRoute.HandleFunc("/lives/{id}", getLive).Methods("GET")
 	apiRoute.HandleFunc("/lives/{id}", removeLive).Methods("DELETE")
+	apiRoute.HandleFunc("/lives/{id}/preview", getLivePreview).Methods("GET")
+	apiRoute.HandleFunc("/lives/{id}/stream-preview", getStreamPreview).Methods("GET")
+	apiRoute.HandleFunc("/lives/{id}/stream-preview/{index:[0-9]+}.ts", getStreamPreviewSegment).Methods("GET")
+	apiRoute.HandleFunc("/lives/{id}/stream.{ext:flv|m3u8}", getStreamProxy).Methods("GET")
+	apiRoute.HandleFunc("/lives/{id}/stream-resource", getStreamResource).Methods("GET")
 	apiRoute.HandleFunc("/lives/{id}/{action}", parseLiveAction).Methods("GET")
+	apiRoute.HandleFunc("/lives/{id}/record/{action}", parseRecordAction).Methods("POST")
+	apiRoute.HandleFunc("/lives/{id}/nickname", putNickName).Methods("PUT")
+	apiRoute.HandleFunc("/lives/{id}/nickname", deleteNickName).Methods("DELETE")
+	apiRoute.HandleFunc("/lives/{id}/tags", putTags).Methods("PUT")
+	apiRoute.HandleFunc("/pipeline/process-file", processFile).Methods("POST")
+	// kept as an alias of /pipeline/process-file
+	apiRoute.HandleFunc("/lives/{id}/process-file", processFile).Methods("POST")
 	apiRoute.HandleFunc("/file/{path:.*}", getFileInfo).Methods("GET")
 	apiRoute.Handle("/metrics", promhttp.Handler())
 
+	// osrp router
+	osrp := newOSRPServer(ctx)
+	m.HandleFunc("/osrp/v1/sse", osrp.serveSSE).Methods("GET")
+
 	m.PathPrefix("/files/").Handler(
 		CORSMiddleware(
 			http.StripPrefix(
@@ -0,0 +1,199 @@
+package servers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hr3lxphr6j/bililive-go/src/instance"
+	"github.com/hr3lxphr6j/bililive-go/src/live"
+	"github.com/hr3lxphr6j/bililive-go/src/pkg/events"
+	"github.com/hr3lxphr6j/bililive-go/src/recorders"
+)
+
+const (
+	contentTypeEventStream = "text/event-stream"
+
+	// messages are dropped for the clients which can not keep up
+	sseClientBufferSize = 16
+	// how long the browsers wait before reconnecting
+	sseRetryMs = 3000
+)
+
+type sseMessage struct {
+	event string
+	data  []byte
+}
+
+// sseHub broadcasts the events to the connected server-sent events clients.
+type sseHub struct {
+	sync.Mutex
+	clients map[chan sseMessage]map[string]bool // client -> the subscribed events, nil means all
+}
+
+func newSSEHub() *sseHub {
+	return &sseHub{clients: make(map[chan sseMessage]map[string]bool)}
+}
+
+// registryListener forwards the events of the dispatcher to the clients.
+func (h *sseHub) registryListener(ctx context.Context) {
+	ed, ok := instance.GetInstance(ctx).EventDispatcher.(events.Dispatcher)
+	if !ok {
+		return
+	}
+	ed.AddEventListener(recorders.RecordFileStarted, events.NewEventListener(func(event *events.Event) {
+		param := event.Object.(recorders.RecordFileStartedParam)
+		h.broadcast(string(event.Type), map[string]interface{}{
+			"live_id":    param.Live.GetLiveId(),
+			"file":       param.File,
+			"stream_url": param.StreamUrl.String(),
+		})
+	}))
+	ed.AddEventListener(recorders.RecorderStreamUnsupported, events.NewEventListener(func(event *events.Event) {
+		param := event.Object.(recorders.StreamUnsupportedParam)
+		h.broadcast("stream_unsupported", map[string]interface{}{
+			"live_id": param.Live.GetLiveId(),
+			"reason":  param.Reason,
+		})
+	}))
+	ed.AddEventListener(recorders.RecordFileSuspect, events.NewEventListener(func(event *events.Event) {
+		param := event.Object.(recorders.RecordFileSuspectParam)
+		h.broadcast("record_file_suspect", map[string]interface{}{
+			"live_id": param.Live.GetLiveId(),
+			"file":    param.File,
+			"reason":  param.Reason,
+		})
+	}))
+	ed.AddEventListener(recorders.RecordWriteFailed, events.NewEventListener(func(event *events.Event) {
+		param := event.Object.(recorders.RecordWriteFailedParam)
+		h.broadcast("record_write_failed", map[string]interface{}{
+			"live_id":     param.Live.GetLiveId(),
+			"file":        param.File,
+			"error":       param.Err.Error(),
+			"policy":      param.Policy,
+			"output_path": param.OutputPath,
+		})
+	}))
+	ed.AddEventListener(recorders.RecordFileDeduped, events.NewEventListener(func(event *events.Event) {
+		param := event.Object.(recorders.RecordFileDedupedParam)
+		h.broadcast("record_file_deduped", map[string]interface{}{
+			"live_id":  param.Live.GetLiveId(),
+			"file":     param.File,
+			"reason":   param.Reason,
+			"action":   param.Action,
+			"original": param.Original,
+		})
+	}))
+	ed.AddEventListener(ConfigChanged, events.NewEventListener(func(event *events.Event) {
+		h.broadcast("config_changed", map[string]interface{}{
+			"changed_fields": event.Object.(ConfigChangedEvent).ChangedFields,
+		})
+	}))
+}
+
+func (h *sseHub) subscribe(filter map[string]bool) chan sseMessage {
+	h.Lock()
+	defer h.Unlock()
+	ch := make(chan sseMessage, sseClientBufferSize)
+	h.clients[ch] = filter
+	return ch
+}
+
+func (h *sseHub) unsubscribe(ch chan sseMessage) {
+	h.Lock()
+	defer h.Unlock()
+	delete(h.clients, ch)
+}
+
+func (h *sseHub) broadcast(event string, data interface{}) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return
+	}
+	h.Lock()
+	defer h.Unlock()
+	for ch, filter := range h.clients {
+		if filter != nil && !filter[event] {
+			continue
+		}
+		select {
+		case ch <- sseMessage{event: event, data: b}:
+		default:
+		}
+	}
+}
+
+// serveEvents streams all the events, after a snapshot event with the current lives,
+// so that a reconnecting client is in sync without fetching them.
+func (h *sseHub) serveEvents(writer http.ResponseWriter, r *http.Request) {
+	h.serve(writer, r, nil, func() (*sseMessage, error) {
+		inst := instance.GetInstance(r.Context())
+		lives := liveSlice(make([]*live.Info, 0, len(inst.Lives)))
+		for _, l := range inst.Lives {
+			lives = append(lives, parseInfo(r.Context(), l))
+		}
+		sort.Sort(lives)
+		b, err := json.Marshal(map[string]interface{}{"lives": lives})
+		if err != nil {
+			return nil, err
+		}
+		return &sseMessage{event: "snapshot", data: b}, nil
+	})
+}
+
+// serve streams the events in filter to the client until it's disconnected, nil filter means all.
+// The snapshot is built after subscribing and sent first if any, so that no event between them is
+// lost, an event may be sent after a snapshot which includes it already.
+func (h *sseHub) serve(writer http.ResponseWriter, r *http.Request, filter map[string]bool, snapshot func() (*sseMessage, error)) {
+	flusher, ok := writer.(http.Flusher)
+	if !ok {
+		writeError(writer, http.StatusInternalServerError, ErrCodeInternal, "streaming is not supported")
+		return
+	}
+	ch := h.subscribe(filter)
+	defer h.unsubscribe(ch)
+	var first *sseMessage
+	if snapshot != nil {
+		var err error
+		if first, err = snapshot(); err != nil {
+			writeError(writer, http.StatusInternalServerError, ErrCodeInternal, err.Error())
+			return
+		}
+	}
+
+	writer.Header().Set(contentType, contentTypeEventStream)
+	writer.Header().Set("Cache-Control", "no-cache")
+	writer.Header().Set("Connection", "keep-alive")
+	writer.WriteHeader(http.StatusOK)
+	fmt.Fprintf(writer, "retry: %d\n\n", sseRetryMs)
+	if first != nil {
+		fmt.Fprintf(writer, "event: %s\ndata: %s\n\n", first.event, first.data)
+	}
+	flusher.Flush()
+	var heartbeat <-chan time.Time
+	if cfg := instance.GetInstance(r.Context()).Config; cfg != nil && cfg.RPC.SSEHeartbeatInterval > 0 {
+		ticker := time.NewTicker(cfg.RPC.SSEHeartbeatInterval)
+		defer ticker.Stop()
+		heartbeat = ticker.C
+	}
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat:
+			if _, err := fmt.Fprint(writer, ": keepalive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case msg := <-ch:
+			if _, err := fmt.Fprintf(writer, "event: %s\ndata: %s\n\n", msg.event, msg.data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package servers
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hr3lxphr6j/bililive-go/src/configs"
+	"github.com/hr3lxphr6j/bililive-go/src/instance"
+	"github.com/hr3lxphr6j/bililive-go/src/live"
+	livemock "github.com/hr3lxphr6j/bililive-go/src/live/mock"
+	"github.com/hr3lxphr6j/bililive-go/src/pkg/events"
+	"github.com/hr3lxphr6j/bililive-go/src/recorders"
+)
+
+func newSSETestServer(hub *sseHub, inst *instance.Instance) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hub.serveEvents(w, r.WithContext(context.WithValue(r.Context(), instance.Key, inst)))
+	}))
+}
+
+// readSSEBlock reads the lines of a message of the event stream, without the blank line ending it.
+func readSSEBlock(t *testing.T, r *bufio.Reader) []string {
+	lines := make([]string, 0, 2)
+	for {
+		line, err := r.ReadString('\n')
+		assert.NoError(t, err)
+		if line == "\n" || err != nil {
+			return lines
+		}
+		lines = append(lines, strings.TrimSuffix(line, "\n"))
+	}
+}
+
+// skipSSEPreamble reads the retry hint and the snapshot sent on connecting.
+func skipSSEPreamble(t *testing.T, r *bufio.Reader) {
+	assert.Equal(t, []string{"retry: 3000"}, readSSEBlock(t, r))
+	assert.Equal(t, "event: snapshot", readSSEBlock(t, r)[0])
+}
+
+func TestSSEHubRecordFileStarted(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	inst := &instance.Instance{}
+	ctx := context.WithValue(context.Background(), instance.Key, inst)
+	ed := events.NewDispatcher(ctx)
+	hub := newSSEHub()
+	hub.registryListener(ctx)
+
+	server := newSSETestServer(hub, inst)
+	defer server.Close()
+	resp, err := http.Get(server.URL)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, contentTypeEventStream, resp.Header.Get(contentType))
+
+	l := livemock.NewMockLive(ctrl)
+	l.EXPECT().GetLiveId().Return(live.ID("test"))
+	u, _ := url.Parse("https://example.com/live.flv")
+	ed.DispatchEvent(events.NewEvent(recorders.RecordFileStarted, recorders.RecordFileStartedParam{
+		Live:      l,
+		File:      "a.flv",
+		StreamUrl: u,
+	}))
+
+	r := bufio.NewReader(resp.Body)
+	skipSSEPreamble(t, r)
+	line, err := r.ReadString('\n')
+	assert.NoError(t, err)
+	assert.Equal(t, "event: RecordFileStarted\n", line)
+	line, err = r.ReadString('\n')
+	assert.NoError(t, err)
+	assert.Equal(t, `data: {"file":"a.flv","live_id":"test","stream_url":"https://example.com/live.flv"}`+"\n", line)
+}
+
+func TestSSEHubConfigChanged(t *testing.T) {
+	inst := &instance.Instance{}
+	ctx := context.WithValue(context.Background(), instance.Key, inst)
+	events.NewDispatcher(ctx)
+	hub := newSSEHub()
+	hub.registryListener(ctx)
+
+	server := newSSETestServer(hub, inst)
+	defer server.Close()
+	resp, err := http.Get(server.URL)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+
+	// nothing changed, nothing dispatched
+	dispatchConfigChanged(ctx, nil)
+	dispatchConfigChanged(ctx, []string{"live_rooms"})
+
+	r := bufio.NewReader(resp.Body)
+	skipSSEPreamble(t, r)
+	line, err := r.ReadString('\n')
+	assert.NoError(t, err)
+	assert.Equal(t, "event: config_changed\n", line)
+	line, err = r.ReadString('\n')
+	assert.NoError(t, err)
+	assert.Equal(t, `data: {"changed_fields":["live_rooms"]}`+"\n", line)
+}
+
+func TestSSEHubSnapshotAndHeartbeat(t *testing.T) {
+	config := configs.NewConfig()
+	config.RPC.SSEHeartbeatInterval = 10 * time.Millisecond
+	inst := &instance.Instance{Config: config}
+	server := newSSETestServer(newSSEHub(), inst)
+	defer server.Close()
+	resp, err := http.Get(server.URL)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+
+	r := bufio.NewReader(resp.Body)
+	assert.Equal(t, []string{"retry: 3000"}, readSSEBlock(t, r))
+	assert.Equal(t, []string{"event: snapshot", `data: {"lives":[]}`}, readSSEBlock(t, r))
+	assert.Equal(t, []string{": keepalive"}, readSSEBlock(t, r))
+}
+
+func TestSSEHubEventDuringSnapshot(t *testing.T) {
+	hub := newSSEHub()
+	inst := &instance.Instance{Config: configs.NewConfig()}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hub.serve(w, r.WithContext(context.WithValue(r.Context(), instance.Key, inst)), nil, func() (*sseMessage, error) {
+			// happens after the snapshot is taken, but before it's sent
+			hub.broadcast("live_update", map[string]string{"live_id": "1"})
+			return &sseMessage{event: "snapshot", data: []byte(`{"lives":[]}`)}, nil
+		})
+	}))
+	defer server.Close()
+	resp, err := http.Get(server.URL)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+
+	r := bufio.NewReader(resp.Body)
+	skipSSEPreamble(t, r)
+	assert.Equal(t, []string{"event: live_update", `data: {"live_id":"1"}`}, readSSEBlock(t, r))
+}
@@ -0,0 +1,355 @@
+package servers
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/hr3lxphr6j/bililive-go/src/instance"
+	"github.com/hr3lxphr6j/bililive-go/src/live"
+	"github.com/hr3lxphr6j/bililive-go/src/recorders"
+)
+
+const (
+	streamFormatFLV  = "flv"
+	streamFormatM3U8 = "m3u8"
+
+	contentTypeFLV = "video/x-flv"
+
+	// the segments and the variants of the m3u8 are fetched through it, relative to stream.m3u8
+	streamResourcePath = "stream-resource"
+)
+
+var (
+	errStreamProxyDisabled = errors.New("the stream proxy is disabled, set rpc.stream_proxy.token to enable it")
+
+	streamProxyClients = &clientCounter{}
+	// the flv stream lasts as long as the player watches, so there is no timeout,
+	// it's ended by the context of the request instead.
+	streamProxyHttpClient = &http.Client{}
+	// the hls players fetch the playlist and the segments request by request,
+	// a player is counted as watching until it has requested nothing for the ttl
+	hlsSessionTTL = 30 * time.Second
+
+	m3u8UriAttr = regexp.MustCompile(`URI="([^"]*)"`)
+)
+
+// clientCounter counts the players watching through the stream proxy, the flv ones by
+// the connections and the hls ones by the sessions which are active within hlsSessionTTL.
+type clientCounter struct {
+	sync.Mutex
+	clients  int
+	sessions map[string]time.Time
+}
+
+// active returns the number of the players, the expired sessions are dropped.
+func (c *clientCounter) active() int {
+	for key, last := range c.sessions {
+		if time.Since(last) >= hlsSessionTTL {
+			delete(c.sessions, key)
+		}
+	}
+	return c.clients + len(c.sessions)
+}
+
+// acquire takes a slot, false when there are max clients already, 0 max means unlimited.
+func (c *clientCounter) acquire(max int) bool {
+	c.Lock()
+	defer c.Unlock()
+	if max > 0 && c.active() >= max {
+		return false
+	}
+	c.clients++
+	return true
+}
+
+// touch keeps the hls session alive, or starts it when there is a free slot.
+func (c *clientCounter) touch(key string, max int) bool {
+	c.Lock()
+	defer c.Unlock()
+	if _, ok := c.sessions[key]; !ok && max > 0 && c.active() >= max {
+		return false
+	}
+	if c.sessions == nil {
+		c.sessions = make(map[string]time.Time)
+	}
+	c.sessions[key] = time.Now()
+	return true
+}
+
+func (c *clientCounter) release() {
+	c.Lock()
+	defer c.Unlock()
+	c.clients--
+}
+
+// streamFormatOf guesses the format of the stream url, the same way as the parsers do.
+func streamFormatOf(u *url.URL) string {
+	if strings.Contains(u.Path, "m3u8") {
+		return streamFormatM3U8
+	}
+	return streamFormatFLV
+}
+
+// proxiedStreamUrl returns the url being recorded, so that the player watches the same stream as
+// the recorder, or a freshly resolved one when the live is not recording.
+func proxiedStreamUrl(r *http.Request, inst *instance.Instance, l live.Live) (*url.URL, error) {
+	if rec, err := inst.RecorderManager.(recorders.Manager).GetRecorder(r.Context(), l.GetLiveId()); err == nil {
+		if u := rec.StreamUrl(); u != nil {
+			return u, nil
+		}
+	}
+	urls, err := l.GetStreamUrls()
+	if err != nil {
+		return nil, err
+	}
+	if len(urls) == 0 {
+		return nil, recorders.ErrStreamUrlNotFound
+	}
+	return urls[0], nil
+}
+
+// hlsSessionKey identifies a hls player by its address and the live it watches.
+func hlsSessionKey(r *http.Request, id string) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return host + "/" + id
+}
+
+// signStreamResource signs the upstream uri with the token, so that only the uris in
+// the playlists served by the proxy are fetched, rather than any url the caller likes.
+func signStreamResource(token, id, uri string) string {
+	mac := hmac.New(sha256.New, []byte(token))
+	mac.Write([]byte(id + "\n" + uri))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// streamResourceUrl returns the uri of the upstream resource through the proxy, relative to the playlist.
+func streamResourceUrl(token, id, uri string) string {
+	return streamResourcePath + "?" + url.Values{
+		"url":          {uri},
+		"sig":          {signStreamResource(token, id, uri)},
+		"access_token": {token},
+	}.Encode()
+}
+
+// rewritePlaylist resolves the uris in the m3u8 against the base and maps them with proxied,
+// so that the player fetches the segments and the variants through the proxy as well.
+func rewritePlaylist(base *url.URL, b []byte, proxied func(string) string) ([]byte, error) {
+	resolve := func(ref string) (string, error) {
+		u, err := base.Parse(ref)
+		if err != nil {
+			return "", err
+		}
+		return proxied(u.String()), nil
+	}
+	buf := new(bytes.Buffer)
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "#"):
+			var err error
+			line = m3u8UriAttr.ReplaceAllStringFunc(line, func(attr string) string {
+				u, e := resolve(m3u8UriAttr.FindStringSubmatch(attr)[1])
+				if e != nil {
+					err = e
+					return attr
+				}
+				return `URI="` + u + `"`
+			})
+			if err != nil {
+				return nil, err
+			}
+		default:
+			u, err := resolve(line)
+			if err != nil {
+				return nil, err
+			}
+			line = u
+		}
+		fmt.Fprintln(buf, line)
+	}
+	return buf.Bytes(), scanner.Err()
+}
+
+// streamProxyLive authorizes the request and returns the streaming live of it,
+// the error response is written when it returns false.
+func streamProxyLive(writer http.ResponseWriter, r *http.Request) (*instance.Instance, live.Live, bool) {
+	inst := instance.GetInstance(r.Context())
+	cfg := inst.Config.RPC.StreamProxy
+	if cfg.Token == "" {
+		writeError(writer, http.StatusForbidden, ErrCodeUnauthorized, errStreamProxyDisabled.Error())
+		return nil, nil, false
+	}
+	if !bearerTokenMatches(r, cfg.Token) {
+		writeError(writer, http.StatusForbidden, ErrCodeUnauthorized, "invalid token")
+		return nil, nil, false
+	}
+	id := mux.Vars(r)["id"]
+	l, ok := inst.Lives[live.ID(id)]
+	if !ok {
+		writeLiveNotFound(writer, id)
+		return nil, nil, false
+	}
+	if info, err := live.GetCachedInfo(inst.Cache, l); err != nil || !info.Status {
+		writeError(writer, http.StatusTooEarly, ErrCodeLiveNotStreaming, "the live is not streaming")
+		return nil, nil, false
+	}
+	return inst, l, true
+}
+
+func writeTooManyPlayers(writer http.ResponseWriter, max int) {
+	writeError(writer, http.StatusTooManyRequests, ErrCodeTooManyRequests,
+		fmt.Sprintf("%d players are watching already", max))
+}
+
+// servePlaylist serves the m3u8 with the uris rewritten to the proxy.
+func servePlaylist(writer http.ResponseWriter, r *http.Request, token string, l live.Live, u *url.URL) {
+	id := string(l.GetLiveId())
+	b, err := fetchPreviewData(r.Context(), u, l.GetHeadersForDownloader())
+	if err == nil {
+		b, err = rewritePlaylist(u, b, func(uri string) string {
+			return streamResourceUrl(token, id, uri)
+		})
+	}
+	if err != nil {
+		writeError(writer, http.StatusBadGateway, ErrCodeUpstreamFailed, err.Error())
+		return
+	}
+	writer.Header().Set(contentType, contentTypeM3U8)
+	_, _ = writer.Write(b)
+}
+
+// getStreamProxy serves the upstream stream of the live to the external players, e.g. vlc or mpv,
+// with the headers and the cookies of the platform which the players can not send themselves.
+// The flv stream is relayed over a separate connection, the uris in the m3u8 playlist are
+// rewritten to getStreamResource, which fetches the segments and the variants the same way.
+func getStreamProxy(writer http.ResponseWriter, r *http.Request) {
+	inst, l, ok := streamProxyLive(writer, r)
+	if !ok {
+		return
+	}
+	cfg := inst.Config.RPC.StreamProxy
+	ext := mux.Vars(r)["ext"]
+	if ext == streamFormatM3U8 {
+		if !streamProxyClients.touch(hlsSessionKey(r, string(l.GetLiveId())), cfg.MaxClients) {
+			writeTooManyPlayers(writer, cfg.MaxClients)
+			return
+		}
+	} else {
+		if !streamProxyClients.acquire(cfg.MaxClients) {
+			writeTooManyPlayers(writer, cfg.MaxClients)
+			return
+		}
+		defer streamProxyClients.release()
+	}
+
+	u, err := proxiedStreamUrl(r, inst, l)
+	if err != nil {
+		writeError(writer, http.StatusBadGateway, ErrCodeUpstreamFailed, err.Error())
+		return
+	}
+	if format := streamFormatOf(u); format != ext {
+		writeError(writer, http.StatusConflict, ErrCodeFormatMismatch,
+			fmt.Sprintf("the stream of this live is %s, not %s", format, ext))
+		return
+	}
+	if ext == streamFormatM3U8 {
+		servePlaylist(writer, r, cfg.Token, l, u)
+		return
+	}
+	relayStream(writer, r, u, l.GetHeadersForDownloader(), contentTypeFLV)
+}
+
+// getStreamResource serves a segment or a variant playlist of the m3u8 served by getStreamProxy.
+func getStreamResource(writer http.ResponseWriter, r *http.Request) {
+	inst, l, ok := streamProxyLive(writer, r)
+	if !ok {
+		return
+	}
+	cfg := inst.Config.RPC.StreamProxy
+	uri, sig := r.URL.Query().Get("url"), r.URL.Query().Get("sig")
+	if !hmac.Equal([]byte(sig), []byte(signStreamResource(cfg.Token, string(l.GetLiveId()), uri))) {
+		writeError(writer, http.StatusForbidden, ErrCodeUnauthorized, "invalid signature")
+		return
+	}
+	u, err := url.Parse(uri)
+	if err != nil {
+		writeError(writer, http.StatusBadRequest, ErrCodeUrlInvalid, err.Error())
+		return
+	}
+	if !streamProxyClients.touch(hlsSessionKey(r, string(l.GetLiveId())), cfg.MaxClients) {
+		writeTooManyPlayers(writer, cfg.MaxClients)
+		return
+	}
+	if streamFormatOf(u) == streamFormatM3U8 {
+		servePlaylist(writer, r, cfg.Token, l, u)
+		return
+	}
+	relayStream(writer, r, u, l.GetHeadersForDownloader(), "")
+}
+
+// relayStream copies the upstream response to the player until either of them ends, the content
+// type of the upstream is kept when the given one is empty.
+func relayStream(writer http.ResponseWriter, r *http.Request, u *url.URL, headers map[string]string, typ string) {
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		writeError(writer, http.StatusBadGateway, ErrCodeUpstreamFailed, err.Error())
+		return
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := streamProxyHttpClient.Do(req.WithContext(r.Context()))
+	if err != nil {
+		writeError(writer, http.StatusBadGateway, ErrCodeUpstreamFailed, err.Error())
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		writeError(writer, http.StatusBadGateway, ErrCodeUpstreamFailed,
+			fmt.Sprintf("failed to fetch the stream, status code: %d", resp.StatusCode))
+		return
+	}
+	if typ == "" {
+		typ = resp.Header.Get(contentType)
+	}
+	if typ != "" {
+		writer.Header().Set(contentType, typ)
+	}
+	writer.WriteHeader(http.StatusOK)
+	flusher, _ := writer.(http.Flusher)
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := resp.Body.Read(buf)
+		if n > 0 {
+			if _, werr := writer.Write(buf[:n]); werr != nil {
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		if err != nil {
+			// io.EOF when the stream ends, or the player is gone
+			return
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package servers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bluele/gcache"
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hr3lxphr6j/bililive-go/src/configs"
+	"github.com/hr3lxphr6j/bililive-go/src/instance"
+	"github.com/hr3lxphr6j/bililive-go/src/live"
+	"github.com/hr3lxphr6j/bililive-go/src/live/mock"
+	"github.com/hr3lxphr6j/bililive-go/src/recorders"
+)
+
+func TestRewritePlaylist(t *testing.T) {
+	base, _ := url.Parse("https://cdn.example.com/live/1/index.m3u8?token=abc")
+	b, err := rewritePlaylist(base, []byte("#EXTM3U\n"+
+		"#EXT-X-MAP:URI=\"init.mp4\"\n"+
+		"#EXTINF:2.000,\n"+
+		"seg1.ts?t=1\n"+
+		"\n"+
+		"#EXTINF:2.000,\n"+
+		"https://other.example.com/seg2.ts\n"), func(uri string) string { return uri })
+	assert.NoError(t, err)
+	assert.Equal(t, "#EXTM3U\n"+
+		"#EXT-X-MAP:URI=\"https://cdn.example.com/live/1/init.mp4\"\n"+
+		"#EXTINF:2.000,\n"+
+		"https://cdn.example.com/live/1/seg1.ts?t=1\n"+
+		"\n"+
+		"#EXTINF:2.000,\n"+
+		"https://other.example.com/seg2.ts\n", string(b))
+
+	b, err = rewritePlaylist(base, []byte("#EXTINF:2.000,\nseg1.ts\n"), func(uri string) string {
+		return streamResourceUrl("secret", "1", uri)
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "#EXTINF:2.000,\n"+streamResourcePath+"?access_token=secret&sig="+
+		signStreamResource("secret", "1", "https://cdn.example.com/live/1/seg1.ts")+
+		"&url="+url.QueryEscape("https://cdn.example.com/live/1/seg1.ts")+"\n", string(b))
+}
+
+func TestClientCounter(t *testing.T) {
+	backup := hlsSessionTTL
+	defer func() { hlsSessionTTL = backup }()
+	hlsSessionTTL = time.Hour
+
+	c := &clientCounter{}
+	assert.True(t, c.touch("a", 2))
+	// the same player again
+	assert.True(t, c.touch("a", 2))
+	assert.True(t, c.acquire(2))
+	assert.False(t, c.touch("b", 2))
+	assert.False(t, c.acquire(2))
+	c.release()
+	assert.True(t, c.touch("b", 2))
+
+	// the sessions are gone after the ttl
+	hlsSessionTTL = 0
+	assert.True(t, c.acquire(1))
+	assert.Equal(t, 1, c.clients)
+}
+
+func TestGetStreamProxy(t *testing.T) {
+	upstreamMux := http.NewServeMux()
+	upstreamMux.HandleFunc("/live.flv", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "https://live.example.com", r.Header.Get("Referer"))
+		fmt.Fprint(w, "FLV data")
+	})
+	upstreamMux.HandleFunc("/hls/index.m3u8", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=1\nlow/index.m3u8\n")
+	})
+	upstreamMux.HandleFunc("/hls/low/index.m3u8", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "#EXTM3U\n#EXTINF:2.000,\nseg1.ts\n")
+	})
+	upstreamMux.HandleFunc("/hls/low/seg1.ts", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "https://live.example.com", r.Header.Get("Referer"))
+		w.Header().Set(contentType, contentTypeTS)
+		fmt.Fprint(w, "TS data")
+	})
+	upstream := httptest.NewServer(upstreamMux)
+	defer upstream.Close()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	l := mock.NewMockLive(ctrl)
+	l.EXPECT().GetLiveId().Return(live.ID("1")).AnyTimes()
+	l.EXPECT().GetHeadersForDownloader().Return(map[string]string{"Referer": "https://live.example.com"}).AnyTimes()
+	cache := gcache.New(4).LRU().Build()
+	cache.Set(l, &live.Info{Live: l, Status: true})
+	config := configs.NewConfig()
+	config.RPC.StreamProxy = configs.StreamProxy{Token: "secret", MaxClients: 1}
+	inst := &instance.Instance{
+		Config: config,
+		Lives:  map[live.ID]live.Live{"1": l},
+		Cache:  cache,
+	}
+	ctx := context.WithValue(context.Background(), instance.Key, inst)
+	recorders.NewManager(ctx)
+	router := mux.NewRouter()
+	router.HandleFunc("/api/lives/{id}/stream.{ext:flv|m3u8}", getStreamProxy)
+	router.HandleFunc("/api/lives/{id}/stream-resource", getStreamResource)
+	// the uri in the playlist, resolved the way the players do
+	resolve := func(playlist, target string) string {
+		base, _ := url.Parse(playlist)
+		lines := strings.Split(strings.TrimSpace(target), "\n")
+		u, err := base.Parse(lines[len(lines)-1])
+		assert.NoError(t, err)
+		return u.String()
+	}
+	do := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		router.ServeHTTP(w, r.WithContext(ctx))
+		return w
+	}
+
+	w := do("/api/lives/1/stream.flv")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = do("/api/lives/1/stream.flv?access_token=wrong")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = do("/api/lives/2/stream.flv?access_token=secret")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	flvUrl, _ := url.Parse(upstream.URL + "/live.flv")
+	l.EXPECT().GetStreamUrls().Return([]*url.URL{flvUrl}, nil).Times(2)
+	w = do("/api/lives/1/stream.flv?access_token=secret")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, contentTypeFLV, w.Header().Get(contentType))
+	assert.Equal(t, "FLV data", w.Body.String())
+	w = do("/api/lives/1/stream.m3u8?access_token=secret")
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), ErrCodeFormatMismatch)
+
+	m3u8Url, _ := url.Parse(upstream.URL + "/hls/index.m3u8")
+	l.EXPECT().GetStreamUrls().Return([]*url.URL{m3u8Url}, nil)
+	w = do("/api/lives/1/stream.m3u8?access_token=secret")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, contentTypeM3U8, w.Header().Get(contentType))
+	// the variant goes through the proxy as well
+	variant := resolve("/api/lives/1/stream.m3u8", w.Body.String())
+	assert.True(t, strings.HasPrefix(variant, "/api/lives/1/"+streamResourcePath+"?"))
+	w = do(variant)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, contentTypeM3U8, w.Header().Get(contentType))
+	segment := resolve(variant, w.Body.String())
+	w = do(segment)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, contentTypeTS, w.Header().Get(contentType))
+	assert.Equal(t, "TS data", w.Body.String())
+	// only the uris signed by the proxy are fetched
+	w = do("/api/lives/1/" + streamResourcePath + "?access_token=secret&sig=bad&url=" + url.QueryEscape(upstream.URL+"/live.flv"))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	// the hls player takes the only slot until its session expires
+	w = do("/api/lives/1/stream.flv?access_token=secret")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	streamProxyClients.Lock()
+	streamProxyClients.sessions = nil
+	streamProxyClients.Unlock()
+
+	// the only slot is taken
+	assert.True(t, streamProxyClients.acquire(1))
+	w = do("/api/lives/1/stream.flv?access_token=secret")
+	streamProxyClients.release()
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+
+	config.RPC.StreamProxy.Token = ""
+	w = do("/api/lives/1/stream.flv?access_token=")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "disabled")
+}
@@ -1,8 +1,13 @@
 package servers
 
 import (
+	"crypto/sha1"
+	"encoding/hex"
 	"encoding/json"
 	"net/http"
+	"strings"
+
+	"github.com/hr3lxphr6j/bililive-go/src/configs"
 )
 
 const (
@@ -25,6 +30,36 @@ func writeJSON(w http.ResponseWriter, obj interface{}) {
 	_, _ = w.Write(b)
 }
 
+// writeJSONWithETag writes obj with the hash of it as the ETag, and a 304 without
+// body when the client already has it, for the endpoints polled by the dashboard.
+func writeJSONWithETag(w http.ResponseWriter, r *http.Request, obj interface{}) {
+	b, err := json.Marshal(obj)
+	if err != nil {
+		writeMsg(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	sum := sha1.Sum(b)
+	etag := `"` + hex.EncodeToString(sum[:]) + `"`
+	w.Header().Set("ETag", etag)
+	if etagMatch(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set(contentType, contentTypeJSON)
+	_, _ = w.Write(b)
+}
+
+// etagMatch reports whether the If-None-Match header matches etag, the weak comparison is used.
+func etagMatch(ifNoneMatch, etag string) bool {
+	for _, tag := range strings.Split(ifNoneMatch, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
 func writeJsonWithStatusCode(w http.ResponseWriter, code int, obj interface{}) {
 	b, err := json.Marshal(obj)
 	if err != nil {
@@ -35,3 +70,17 @@ func writeJsonWithStatusCode(w http.ResponseWriter, code int, obj interface{}) {
 	w.Header().Set(contentType, contentTypeJSON)
 	_, _ = w.Write(b)
 }
+
+// writeValidationError writes the error of configs.Config.Verify, the field level
+// details are put in data so that the frontend can annotate the inputs.
+func writeValidationError(w http.ResponseWriter, err error) {
+	resp := commonResp{
+		ErrNo:   http.StatusBadRequest,
+		ErrCode: ErrCodeConfigInvalid,
+		ErrMsg:  err.Error(),
+	}
+	if errs, ok := err.(configs.ValidationErrors); ok {
+		resp.Data = errs
+	}
+	writeJsonWithStatusCode(w, http.StatusBadRequest, resp)
+}